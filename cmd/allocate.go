@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"goscan/config"
+	"goscan/inventory"
+	"goscan/networkutils"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// errNoFreeAddress is returned when an allocation finds no address left.
+var errNoFreeAddress = errors.New("no free address left")
+
+// maxInUse bounds the addresses an allocation finds answering ARP, though
+// the scan saw them free, before giving up.
+const maxInUse = 8
+
+// allocationRequest is the body of POST /allocate.
+type allocationRequest struct {
+	Interface string `json:"interface"`
+	Subnet    string `json:"subnet"`
+	Owner     string `json:"owner"`
+	Note      string `json:"note"`
+	// TTL is how long the reservation holds; zero means until released.
+	TTL config.Duration `json:"ttl"`
+}
+
+func NewAllocateCmd() *cobra.Command {
+	allocateCmd := &cobra.Command{
+		Use:   "allocate",
+		Short: "Reserve a free address of an interface or subnet",
+		Long: `Scan an interface, pick the first free address (on --subnet if given),
+reserve it in the inventory for --owner and check with fresh ARP requests
+that nothing answers on it. Addresses that answer are skipped.
+
+Reserved addresses are never allocated again, nor listed by free-blocks,
+until they expire (--ttl) or are released with "goscan reservations release".`,
+		Args: cobra.NoArgs,
+		Run:  runAllocate,
+	}
+
+	allocateCmd.Flags().String("subnet", "", "Allocate on this subnet, in CIDR notation, rather than a whole interface")
+	allocateCmd.Flags().String("owner", "", "Who the address is for")
+	allocateCmd.Flags().String("note", "", "What the address is for")
+	allocateCmd.Flags().Duration("ttl", 0, "Release the reservation after this long (default never)")
+	allocateCmd.Flags().StringP("config", "c", "", "JSON configuration file with leaseFiles, dhcpPools and dhcpReservations")
+	allocateCmd.Flags().Bool("json", false, "Print the reservation as JSON")
+	allocateCmd.MarkFlagRequired("owner")
+
+	return allocateCmd
+}
+
+func NewReservationsCmd() *cobra.Command {
+	reservationsCmd := &cobra.Command{
+		Use:   "reservations",
+		Short: "List the addresses reserved with goscan allocate",
+		Args:  cobra.NoArgs,
+		Run:   runReservations,
+	}
+	reservationsCmd.Flags().Bool("json", false, "Print the reservations as JSON")
+
+	releaseCmd := &cobra.Command{
+		Use:   "release <ip>...",
+		Short: "Release reserved addresses",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   runRelease,
+	}
+
+	reservationsCmd.AddCommand(releaseCmd)
+	return reservationsCmd
+}
+
+func runAllocate(cmd *cobra.Command, args []string) {
+	var req allocationRequest
+	req.Subnet, _ = cmd.Flags().GetString("subnet")
+	req.Owner, _ = cmd.Flags().GetString("owner")
+	req.Note, _ = cmd.Flags().GetString("note")
+	ttl, _ := cmd.Flags().GetDuration("ttl")
+	req.TTL = config.Duration(ttl)
+	asJSON, _ := cmd.Flags().GetBool("json")
+	scriptable, _ := cmd.Flags().GetBool("scriptable")
+
+	cfg := loadScanConfig(cmd)
+	req.Interface, _ = cmd.Flags().GetString("interface")
+	if req.Subnet != "" {
+		subnet, err := parseSubnet(req.Subnet)
+		if err != nil {
+			log.Fatal(err)
+		}
+		iface, err := networkutils.DefaultScanner.GetInterfaceForSubnet(subnet)
+		if err != nil {
+			log.Fatal(err)
+		}
+		req.Interface = iface.Name
+		cfg.IncludeInterfaces = []string{iface.Name}
+		cfg.ExcludeInterfaces = nil
+		cfg.InterfaceTypes = nil
+		config.SetServerConfig(cfg)
+	}
+	if req.Interface == "" {
+		log.Fatal("Give an interface with -i or a subnet with --subnet")
+	}
+
+	store, err := inventory.Open(inventoryPath(cmd, cfg))
+	if err != nil {
+		log.Fatal(err)
+	}
+	report := scanSelected(cfg)
+	r, err := allocate(store, networkutils.DefaultScanner, report, req)
+	if err != nil {
+		log.Fatalf("Allocation failed: %v", err)
+	}
+
+	switch {
+	case asJSON:
+		printJSON(r)
+	case scriptable:
+		fmt.Println(r.IP)
+	default:
+		until := "until released"
+		if r.ExpiresAt != nil {
+			until = "until " + r.ExpiresAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("Reserved %s%s%s%s on %s for %s, %s\n", boldText, colorGreen, r.IP, colorReset, r.Interface, r.Owner, until)
+	}
+}
+
+// allocate reserves the first free address of a scan, on the requested
+// subnet if any, and checks with ARP that nothing answers on it. The address
+// is reserved before it is probed, so that a concurrent allocation cannot
+// pick it meanwhile; addresses that answer are released again and skipped.
+func allocate(store *inventory.Store, scanner *networkutils.Scanner, report *networkutils.ScanReport, req allocationRequest) (inventory.Reservation, error) {
+	var subnet *net.IPNet
+	if req.Subnet != "" {
+		var err error
+		if subnet, err = parseSubnet(req.Subnet); err != nil {
+			return inventory.Reservation{}, err
+		}
+	}
+	reserved, err := reservedRanges(config.GetServerConfig())
+	if err != nil {
+		return inventory.Reservation{}, err
+	}
+	held, err := reservationRanges(store)
+	if err != nil {
+		return inventory.Reservation{}, err
+	}
+	reserved = append(reserved, held...)
+
+	inUse := 0
+	for i := range report.Interfaces {
+		iface := &report.Interfaces[i]
+		profile, err := scanner.ProfileForInterface(iface.Name)
+		if err != nil {
+			return inventory.Reservation{}, err
+		}
+		for _, ip := range iface.FreeAddresses(reserved) {
+			if subnet != nil && !subnet.Contains(ip) {
+				continue
+			}
+			r := inventory.Reservation{
+				IP:        ip.String(),
+				Interface: iface.QualifiedName(),
+				Owner:     req.Owner,
+				Note:      req.Note,
+				CreatedAt: time.Now().UTC(),
+			}
+			if req.TTL > 0 {
+				expires := r.CreatedAt.Add(time.Duration(req.TTL))
+				r.ExpiresAt = &expires
+			}
+			err := store.Reserve(r)
+			if errors.Is(err, inventory.ErrReserved) {
+				continue
+			}
+			if err != nil {
+				return inventory.Reservation{}, err
+			}
+
+			host, err := scanner.ConfirmUnused(ip, profile.Timeout)
+			if err != nil || host != nil {
+				if releaseErr := store.Release(r.IP); releaseErr != nil {
+					log.Printf("Failed to release %s: %v", r.IP, releaseErr)
+				}
+			}
+			if err != nil {
+				return inventory.Reservation{}, err
+			}
+			if host != nil {
+				log.Printf("%s answers ARP from %s though the scan found it free, skipping it", ip, host.MAC)
+				if inUse++; inUse >= maxInUse {
+					return inventory.Reservation{}, fmt.Errorf("%d addresses found free answer ARP, scan again", inUse)
+				}
+				continue
+			}
+			return r, nil
+		}
+	}
+	return inventory.Reservation{}, errNoFreeAddress
+}
+
+// parseSubnet reads an IPv4 subnet in CIDR notation.
+func parseSubnet(s string) (*net.IPNet, error) {
+	_, subnet, err := net.ParseCIDR(s)
+	if err != nil || subnet.IP.To4() == nil {
+		return nil, fmt.Errorf("invalid IPv4 subnet %q", s)
+	}
+	return subnet, nil
+}
+
+// reservationRanges returns the addresses held by reservations.
+func reservationRanges(store *inventory.Store) ([]networkutils.AddressRange, error) {
+	reservations, err := store.Reservations(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	ranges := make([]networkutils.AddressRange, 0, len(reservations))
+	for _, r := range reservations {
+		ip := net.ParseIP(r.IP)
+		ranges = append(ranges, networkutils.AddressRange{First: ip, Last: ip})
+	}
+	return ranges, nil
+}
+
+// inventoryPath returns the inventory given with --inventory, or the one of
+// the configuration file, or the default one.
+func inventoryPath(cmd *cobra.Command, cfg config.ServerConfig) string {
+	if path, _ := cmd.Flags().GetString("inventory"); path != "" {
+		return path
+	}
+	if cfg.InventoryPath != "" {
+		return cfg.InventoryPath
+	}
+	return inventory.DefaultPath
+}
+
+func runReservations(cmd *cobra.Command, args []string) {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	scriptable, _ := cmd.Flags().GetBool("scriptable")
+	path := inventoryPath(cmd, config.GetServerConfig())
+	if _, err := os.Stat(path); err != nil {
+		log.Fatalf("No inventory at %s: %v", path, err)
+	}
+	store, err := inventory.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reservations, err := store.Reservations(time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if asJSON {
+		printJSON(reservations)
+		return
+	}
+	expiry := func(r inventory.Reservation) string {
+		if r.ExpiresAt == nil {
+			return ""
+		}
+		return r.ExpiresAt.Format(time.RFC3339)
+	}
+	if scriptable {
+		for _, r := range reservations {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", r.IP, r.Interface, r.Owner, r.CreatedAt.Format(time.RFC3339), expiry(r))
+		}
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"IP", "Interface", "Owner", "Note", "Reserved", "Expires"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.SetColumnSeparator("   ")
+	table.SetAutoWrapText(false)
+	for _, r := range reservations {
+		expires := "never"
+		if r.ExpiresAt != nil {
+			expires = r.ExpiresAt.Local().Format("2006-01-02 15:04")
+		}
+		table.Append([]string{r.IP, r.Interface, r.Owner, r.Note, r.CreatedAt.Local().Format("2006-01-02 15:04"), expires})
+	}
+	table.Render()
+	fmt.Printf("\nReserved addresses: %s%d%s\n", boldText, len(reservations), colorReset)
+}
+
+func runRelease(cmd *cobra.Command, args []string) {
+	store, err := inventory.Open(inventoryPath(cmd, config.GetServerConfig()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	failed := false
+	for _, ip := range args {
+		err := store.Release(ip)
+		if errors.Is(err, inventory.ErrNotFound) {
+			fmt.Printf(colorPurple+"%s is not reserved."+colorReset+"\n", ip)
+			failed = true
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf(colorGreen+"Released %s"+colorReset+"\n", ip)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
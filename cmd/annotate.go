@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"goscan/inventory"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewAnnotateCmd() *cobra.Command {
+	annotateCmd := &cobra.Command{
+		Use:   "annotate <ip>",
+		Short: "Attach an owner, role, tags or notes to a host",
+		Long: `Attach an owner, role, tags or notes to a host in the inventory. Only the
+fields given are changed; without any, the current annotation is shown.
+Annotations are kept across rescans.`,
+		Args: cobra.ExactArgs(1),
+		Run:  runAnnotate,
+	}
+
+	annotateCmd.Flags().String("owner", "", "Person or team owning the host")
+	annotateCmd.Flags().String("role", "", "Expected role of the host (e.g. printer, router)")
+	annotateCmd.Flags().StringSlice("tag", nil, "Replace the tags of the host")
+	annotateCmd.Flags().StringSlice("add-tag", nil, "Add tags to the host")
+	annotateCmd.Flags().StringSlice("remove-tag", nil, "Remove tags from the host")
+	annotateCmd.Flags().String("notes", "", "Free-form notes")
+	annotateCmd.Flags().Bool("clear", false, "Remove the annotation")
+	annotateCmd.Flags().Bool("json", false, "Print the annotation as JSON")
+
+	return annotateCmd
+}
+
+func runAnnotate(cmd *cobra.Command, args []string) {
+	path, _ := cmd.Flags().GetString("inventory")
+	clearAnnotation, _ := cmd.Flags().GetBool("clear")
+	asJSON, _ := cmd.Flags().GetBool("json")
+	if path == "" {
+		path = inventory.DefaultPath
+	}
+	ip := args[0]
+
+	store, err := inventory.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	a, err := store.Annotation(ip)
+	if err != nil && !errors.Is(err, inventory.ErrNotFound) {
+		log.Fatal(err)
+	}
+
+	changed := false
+	flags := cmd.Flags()
+	if flags.Changed("owner") {
+		a.Owner, _ = flags.GetString("owner")
+		changed = true
+	}
+	if flags.Changed("role") {
+		a.Role, _ = flags.GetString("role")
+		changed = true
+	}
+	if flags.Changed("notes") {
+		a.Notes, _ = flags.GetString("notes")
+		changed = true
+	}
+	if flags.Changed("tag") {
+		a.Tags, _ = flags.GetStringSlice("tag")
+		changed = true
+	}
+	if flags.Changed("add-tag") {
+		tags, _ := flags.GetStringSlice("add-tag")
+		a.Tags = append(a.Tags, tags...)
+		changed = true
+	}
+	if flags.Changed("remove-tag") {
+		tags, _ := flags.GetStringSlice("remove-tag")
+		a.Tags = removeTags(a.Tags, tags)
+		changed = true
+	}
+	if clearAnnotation {
+		a = inventory.Annotation{}
+		changed = true
+	}
+
+	if changed {
+		a.UpdatedAt = time.Time{}
+		if err := store.Annotate(ip, a); err != nil {
+			log.Fatal(err)
+		}
+		if a, err = store.Annotation(ip); errors.Is(err, inventory.ErrNotFound) {
+			a = inventory.Annotation{}
+		} else if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if asJSON {
+		printJSON(a)
+		return
+	}
+	if a.Empty() {
+		fmt.Printf(colorPurple+"%s has no annotation."+colorReset+"\n", ip)
+		return
+	}
+	fmt.Printf(boldText+colorCyan+"%s"+colorReset+"\n", ip)
+	fmt.Printf("    Owner: %s\n", a.Owner)
+	fmt.Printf("    Role:  %s\n", a.Role)
+	fmt.Printf("    Tags:  %s\n", strings.Join(a.Tags, ", "))
+	fmt.Printf("    Notes: %s\n", a.Notes)
+}
+
+func removeTags(tags, remove []string) []string {
+	var kept []string
+	for _, tag := range tags {
+		drop := false
+		for _, r := range remove {
+			if strings.EqualFold(tag, strings.TrimSpace(r)) {
+				drop = true
+			}
+		}
+		if !drop {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
@@ -1,9 +1,15 @@
 const GoScan = {
   FETCH_DATA_INTERVAL: 5000,
+  PROGRESS_INTERVAL: 500,
 
   init() {
     document.addEventListener('DOMContentLoaded', () => {
       this.loadingElement = document.querySelector('.loading');
+      this.loadingSpinner = this.loadingElement.querySelector('.fa-spinner');
+      this.loadingText = this.loadingElement.querySelector('.loading-text');
+      this.loadingDetail = this.loadingElement.querySelector('.loading-detail');
+      this.progressElement = this.loadingElement.querySelector('.progress');
+      this.progressBar = this.loadingElement.querySelector('.progress-bar');
       this.containerElement = document.querySelector('.container');
       this.footerElement = document.querySelector('.footer');
       this.headerElement = document.querySelector('.header');
@@ -11,9 +17,17 @@ const GoScan = {
 
       this.lastUpdated = new Date();
       this.activeHosts = {};
+      this.availability = {};
+      this.annotations = {};
+      this.leaseNames = {};
+      this.searchElement = document.querySelector('.search');
+
+      this.containerElement.addEventListener('click', event => this.handleClick(event));
+      this.searchElement.addEventListener('input', () => this.updateDisplay());
 
       this.showLoading();
       this.fetchData();
+      this.pollProgress();
       setInterval(() => this.updateLastUpdated(), 1000);
     });
   },
@@ -22,6 +36,35 @@ const GoScan = {
     this.loadingElement.style.opacity = 1;
   },
 
+  // pollProgress shows the progress of the server's scans until the first
+  // results are in.
+  pollProgress() {
+    if (this.loadingElement.style.opacity === '0') {
+      return;
+    }
+    fetch('/progress')
+      .then(response => (response.ok ? response.json() : null))
+      .then(progress => {
+        if (progress && progress.running) {
+          this.showProgress(progress);
+        }
+      })
+      .catch(() => {})
+      .finally(() => setTimeout(() => this.pollProgress(), this.PROGRESS_INTERVAL));
+  },
+
+  showProgress(progress) {
+    const percent = Math.floor(progress.percent);
+    const eta = Math.round(progress.etaNs / 1e9);
+    this.loadingSpinner.style.display = 'none';
+    this.progressElement.style.display = 'block';
+    this.loadingText.textContent = `Scanning ${percent}%`;
+    this.progressBar.style.width = `${percent}%`;
+    this.loadingDetail.textContent =
+      `${progress.completed}/${progress.total} addresses, ${progress.found} hosts found` +
+      (progress.completed > 0 ? `, about ${eta}s left` : '');
+  },
+
   hideLoading() {
     this.loadingElement.style.opacity = 0;
     this.containerElement.style.opacity = 1;
@@ -68,16 +111,210 @@ const GoScan = {
         networkEl.dataset.interface = networkInterface;
         this.containerElement.appendChild(networkEl);
       }
+      if (networkEl.querySelector('.annotation-editor')) {
+        // Keep an open editor until it is saved or cancelled
+        continue;
+      }
+      const hosts = networkData.activeHosts.filter(ip => this.matchesSearch(ip));
       networkEl.innerHTML = `
         <h2><i class="fas fa-network-wired"></i>: ${networkInterface}</h2>
         <p>MAC Address: <span class="value">${networkData.MACAddress}</span></p>
         <p>Total IPs Scanned: <span class="value">${networkData.TotalIPsScanned}</span></p>
+        <p>Profile: <span class="value">${networkData.profile}</span></p>
         <p>Active Hosts: <span class="host-count">${networkData.activeHosts.length}</span></p>
-        <div class="host-list">${networkData.activeHosts.join('<br/>')}</div>
+        ${this.renderErrors(networkData.errors)}
+        ${this.renderLeaseCheck(networkData.leaseCheck)}
+        <div class="host-list">${hosts.map(ip => this.renderHost(networkInterface, ip)).join('')}</div>
       `;
     }
   },
 
+  escapeHTML(text) {
+    return String(text)
+      .replace(/&/g, '&amp;')
+      .replace(/</g, '&lt;')
+      .replace(/>/g, '&gt;')
+      .replace(/"/g, '&quot;');
+  },
+
+  matchesSearch(ip) {
+    const query = this.searchElement ? this.searchElement.value.trim().toLowerCase() : '';
+    if (!query) {
+      return true;
+    }
+    const a = this.annotations[ip] || {};
+    const text = [ip, a.owner, a.role, a.notes, ...(a.tags || [])].join(' ').toLowerCase();
+    return query.split(/\s+/).every(term => text.includes(term));
+  },
+
+  renderHost(networkInterface, ip) {
+    return `
+      <div class="host" data-ip="${ip}">
+        <span class="host-ip">${ip}</span>
+        ${this.renderLease(networkInterface, ip)}
+        ${this.renderAvailability(networkInterface, ip)}
+        ${this.renderAnnotation(ip)}
+        <i class="fas fa-pen edit-annotation" title="Edit annotation"></i>
+      </div>`;
+  },
+
+  renderLease(networkInterface, ip) {
+    const check = this.activeHosts[networkInterface].leaseCheck;
+    if (check && check.activeUnleased.includes(ip)) {
+      return '<span class="no-lease" title="Responding without a DHCP lease (static or rogue)">no lease</span>';
+    }
+    const hostname = this.leaseNames[ip];
+    return hostname ? `<span class="lease-hostname">${this.escapeHTML(hostname)}</span>` : '';
+  },
+
+  renderLeaseCheck(check) {
+    if (!check) {
+      return '';
+    }
+    const silent = check.leasedInactive.map(l => `${l.ip} ${l.hostname || ''}`.trim());
+    return `
+        <p>DHCP Leases: <span class="value">${check.leases}</span>
+          <span class="lease-warning" title="${this.escapeHTML(silent.join('\n'))}">${check.leasedInactive.length} not responding</span>,
+          <span class="lease-warning" title="${check.activeUnleased.join('\n')}">${check.activeUnleased.length} without lease</span>
+        </p>`;
+  },
+
+  renderAvailability(networkInterface, ip) {
+    const history = this.availability[`${networkInterface}|${ip}`];
+    const day = history && history.windows.find(w => w.window === '24h');
+    if (!day || day.samples === 0) {
+      return '';
+    }
+    const uptime = day.uptime;
+    const title = history.windows
+      .map(w => `${w.window}: ${w.uptime.toFixed(1)}% up, longest outage ${this.formatDuration(w.longestOutageNs)}, ${w.flaps} flaps`)
+      .join('\n');
+    const level = uptime >= 99 ? 'good' : uptime >= 90 ? 'fair' : 'poor';
+    return `
+        <span title="${title}">
+          <span class="availability-bar"><span class="availability-fill ${level}" style="width: ${uptime}%"></span></span>
+          <span class="availability-value">${uptime.toFixed(1)}%</span>
+        </span>`;
+  },
+
+  renderAnnotation(ip) {
+    const a = this.annotations[ip];
+    if (!a) {
+      return '';
+    }
+    const parts = [];
+    if (a.owner) parts.push(`<span class="annotation-owner">${this.escapeHTML(a.owner)}</span>`);
+    if (a.role) parts.push(`<span class="annotation-role">${this.escapeHTML(a.role)}</span>`);
+    for (const tag of a.tags || []) {
+      parts.push(`<span class="annotation-tag">#${this.escapeHTML(tag)}</span>`);
+    }
+    const notes = a.notes ? ` title="${this.escapeHTML(a.notes)}"` : '';
+    return `<span class="annotation"${notes}>${parts.join(' ')}</span>`;
+  },
+
+  openEditor(hostEl) {
+    const ip = hostEl.dataset.ip;
+    const a = this.annotations[ip] || {};
+    const editor = document.createElement('div');
+    editor.className = 'annotation-editor';
+    editor.innerHTML = `
+      <input name="owner" placeholder="owner" value="${this.escapeHTML(a.owner || '')}" />
+      <input name="role" placeholder="role" value="${this.escapeHTML(a.role || '')}" />
+      <input name="tags" placeholder="tags, comma separated" value="${this.escapeHTML((a.tags || []).join(', '))}" />
+      <input name="notes" placeholder="notes" value="${this.escapeHTML(a.notes || '')}" />
+      <button class="save-annotation">Save</button>
+      <button class="cancel-annotation">Cancel</button>
+    `;
+    hostEl.after(editor);
+    editor.querySelector('input').focus();
+  },
+
+  saveAnnotation(editor) {
+    const ip = editor.previousElementSibling.dataset.ip;
+    const value = name => editor.querySelector(`[name="${name}"]`).value;
+    const annotation = {
+      owner: value('owner'),
+      role: value('role'),
+      tags: value('tags').split(',').map(t => t.trim()).filter(t => t),
+      notes: value('notes')
+    };
+    fetch(`/annotations/${ip}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(annotation)
+    })
+      .then(response => {
+        if (!response.ok) {
+          throw new Error('Failed to save annotation');
+        }
+        return response.status === 204 ? null : response.json();
+      })
+      .then(saved => {
+        if (saved) {
+          this.annotations[ip] = saved;
+        } else {
+          delete this.annotations[ip];
+        }
+        editor.remove();
+        this.updateDisplay();
+      })
+      .catch(error => this.showError(error.message));
+  },
+
+  handleClick(event) {
+    const target = event.target;
+    if (target.classList.contains('edit-annotation')) {
+      if (!this.containerElement.querySelector('.annotation-editor')) {
+        this.openEditor(target.closest('.host'));
+      }
+    } else if (target.classList.contains('save-annotation')) {
+      this.saveAnnotation(target.closest('.annotation-editor'));
+    } else if (target.classList.contains('cancel-annotation')) {
+      target.closest('.annotation-editor').remove();
+      this.updateDisplay();
+    }
+  },
+
+  fetchAnnotations() {
+    return fetch('/annotations')
+      .then(response => (response.ok ? response.json() : []))
+      .then(annotations => {
+        this.annotations = {};
+        for (const a of annotations) {
+          this.annotations[a.ip] = a;
+        }
+      })
+      .catch(() => {});
+  },
+
+  formatDuration(ns) {
+    const seconds = Math.round(ns / 1e9);
+    if (seconds < 60) return `${seconds}s`;
+    if (seconds < 3600) return `${Math.round(seconds / 60)}m`;
+    if (seconds < 86400) return `${(seconds / 3600).toFixed(1)}h`;
+    return `${(seconds / 86400).toFixed(1)}d`;
+  },
+
+  fetchAvailability() {
+    return fetch('/availability')
+      .then(response => (response.ok ? response.json() : []))
+      .then(hosts => {
+        this.availability = {};
+        for (const host of hosts) {
+          this.availability[`${host.interface}|${host.ip}`] = host;
+        }
+      })
+      .catch(() => {});
+  },
+
+  renderErrors(errors) {
+    if (!errors || errors.length === 0) {
+      return '';
+    }
+    const lines = errors.map(e => `${this.escapeHTML(e.kind)} (${e.count}): ${this.escapeHTML(e.message)}`);
+    return `<p class="scan-errors"><i class="fas fa-exclamation-triangle"></i> ${lines.join('<br/>')}</p>`;
+  },
+
   fetchData() {
     fetch('/all')
       .then(response => {
@@ -88,17 +325,26 @@ const GoScan = {
       })
       .then(data => {
         this.hideError();
-        for (const [networkInterface, networkData] of Object.entries(data)) {
+        for (const networkData of data.interfaces) {
+          const networkInterface = networkData.namespace
+            ? `${networkData.namespace}/${networkData.name}`
+            : networkData.name;
           if (!this.activeHosts[networkInterface]) {
             this.activeHosts[networkInterface] = {
-              MACAddress: networkData.MACAddress,
-              TotalIPsScanned: networkData.TotalIPsScanned,
+              MACAddress: networkData.macAddress,
+              TotalIPsScanned: networkData.totalIPsScanned,
               activeHosts: []
             };
           }
+          this.activeHosts[networkInterface].profile = networkData.profile;
+          this.activeHosts[networkInterface].errors = networkData.errors;
+          this.activeHosts[networkInterface].leaseCheck = networkData.leaseCheck;
           networkData.activeHosts.forEach(host => {
-            if (!this.activeHosts[networkInterface].activeHosts.includes(host)) {
-              this.activeHosts[networkInterface].activeHosts.push(host);
+            if (host.lease && host.lease.hostname) {
+              this.leaseNames[host.ip] = host.lease.hostname;
+            }
+            if (!this.activeHosts[networkInterface].activeHosts.includes(host.ip)) {
+              this.activeHosts[networkInterface].activeHosts.push(host.ip);
             }
           });
           this.activeHosts[networkInterface].activeHosts.sort((a, b) => {
@@ -111,6 +357,9 @@ const GoScan = {
             return 0;
           });
         }
+        return Promise.all([this.fetchAvailability(), this.fetchAnnotations()]);
+      })
+      .then(() => {
         this.updateDisplay();
         this.lastUpdated = new Date();
         this.updateLastUpdated();
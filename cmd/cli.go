@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"goscan/config"
+	"goscan/export"
+	"goscan/leases"
 	"goscan/networkutils"
 	"log"
 	"net"
@@ -34,6 +37,8 @@ func runCLI(cmd *cobra.Command, args []string) {
 	measureExecutionTime, _ := cmd.Flags().GetBool("measure")
 	showMode, _ := cmd.Flags().GetString("show")
 	scriptable, _ := cmd.Flags().GetBool("scriptable")
+	inventoryPath, _ := cmd.Flags().GetString("inventory")
+	output, _ := cmd.Flags().GetString("output")
 
 	showMode = strings.ToLower(showMode)
 
@@ -48,16 +53,41 @@ func runCLI(cmd *cobra.Command, args []string) {
 		scriptable = true
 	}
 
-	cfg := config.GetServerConfig()
+	output = strings.ToLower(output)
+	if err := export.ValidateFormat(output); err != nil {
+		log.Fatal(err)
+	}
+	// Every format but the table is a document written once all interfaces
+	// are scanned
+	structured := output != export.FormatTable
+
+	cfg := loadScanConfig(cmd)
+	// The CLI timeout applies to profiles without one even when not given
 	cfg.Timeout = time.Duration(timeout) * time.Millisecond
 	config.SetServerConfig(cfg)
 
+	if _, err := networkutils.LookupProfile(cfg.Profile); err != nil {
+		log.Fatalf("Error selecting scan profile: %v", err)
+	}
+
+	dhcpLeases, err := leases.ReadFiles(cfg.LeaseFiles)
+	if err != nil {
+		log.Fatalf("Error reading DHCP leases: %v", err)
+	}
+
 	ifaces, err := networkutils.DiscoverInterfaces()
 	if err != nil {
 		log.Fatalf("Error discovering interfaces: %v", err)
 	}
 
+	bar := newProgressBar()
+	if bar != nil {
+		networkutils.DefaultScanner.Progress = bar.update
+	}
+
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var reports []networkutils.InterfaceReport
 	found := false
 
 	for _, iface := range ifaces {
@@ -65,37 +95,43 @@ func runCLI(cmd *cobra.Command, args []string) {
 			continue
 		}
 		found = true
+		profile, err := networkutils.ProfileForInterface(iface.Name)
+		if err != nil {
+			log.Fatalf("Error selecting scan profile of %s: %v", iface.Name, err)
+		}
+		if cmd.Flags().Changed("timeout") {
+			profile.Timeout = cfg.Timeout
+		}
 		wg.Add(1)
-		go func(iface networkutils.InterfaceDetails) {
+		go func(iface networkutils.InterfaceDetails, profile networkutils.Profile) {
 			defer wg.Done()
-			activeHosts, allHosts, err := networkutils.ProbeHosts(&iface, time.Duration(timeout)*time.Millisecond)
-			if err != nil {
-				fmt.Printf(colorRed+"Error probing hosts on interface %s: %v"+colorReset+"\n", iface.Name, err)
-				return
+			report := networkutils.DefaultScanner.ScanInterface(&iface, profile)
+			if len(dhcpLeases) > 0 {
+				report.ApplyLeases(dhcpLeases, time.Now())
 			}
+			mu.Lock()
+			reports = append(reports, report)
+			mu.Unlock()
+			defer bar.hold()()
 
-			networkutils.SortIPs(activeHosts)
-
-			if len(allHosts) > 0 {
-				// Create a map of inactive hosts (all hosts - active hosts)
-				inactiveMap := make(map[string]bool)
-				for _, host := range allHosts {
-					inactiveMap[host.String()] = true
+			if structured {
+				if len(report.Errors) > 0 {
+					printScanErrors(report.QualifiedName(), report.Errors, true)
 				}
+				return
+			}
 
-				for _, host := range activeHosts {
-					delete(inactiveMap, host.String()) // Remove active hosts from the map
+			if len(report.Errors) > 0 {
+				printScanErrors(report.QualifiedName(), report.Errors, scriptable)
+				if report.TotalIPsScanned == 0 {
+					return
 				}
+			}
 
-				// Extract inactive hosts from the map
-				var inactiveHosts []net.IP
-				for hostStr := range inactiveMap {
-					ip := net.ParseIP(hostStr)
-					if ip != nil {
-						inactiveHosts = append(inactiveHosts, ip)
-					}
-				}
-				networkutils.SortIPs(inactiveHosts)
+			activeHosts := report.ActiveIPs()
+
+			if report.TotalIPsScanned > 0 {
+				inactiveHosts := report.InactiveHosts()
 
 				// For scriptable mode, just print the IPs without any formatting
 				if scriptable {
@@ -112,7 +148,11 @@ func runCLI(cmd *cobra.Command, args []string) {
 					return
 				}
 
-				fmt.Printf(boldText+colorCyan+"Interface: %s [%s]"+colorReset+"\n", iface.Name, iface.MACAddress)
+				fmt.Printf(boldText+colorCyan+"Interface: %s [%s]"+colorReset+" profile: %s", iface.Name, iface.MACAddress, profile.Name)
+				if iface.Namespace != "" {
+					fmt.Printf(" netns: %s", iface.Namespace)
+				}
+				fmt.Println()
 
 				table := tablewriter.NewWriter(os.Stdout)
 
@@ -143,6 +183,13 @@ func runCLI(cmd *cobra.Command, args []string) {
 				table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
 				table.SetAutoWrapText(false)
 
+				leased := make(map[string]bool)
+				if report.LeaseCheck != nil {
+					for _, lease := range report.LeaseCheck.LeasedInactive {
+						leased[lease.IP.String()] = true
+					}
+				}
+
 				activeCount := len(activeHosts)
 				inactiveCount := len(inactiveHosts)
 				totalCount := activeCount + inactiveCount
@@ -179,26 +226,27 @@ func runCLI(cmd *cobra.Command, args []string) {
 						row := []string{"", ""}
 
 						if i < activeCount {
-							row[0] = activeHosts[i].String()
+							row[0] = activeLabel(report.ActiveHosts[i])
 						}
 
 						if i < inactiveCount {
-							row[1] = inactiveHosts[i].String()
+							row[1] = inactiveLabel(inactiveHosts[i], leased)
 						}
 
 						table.Append(row)
 					}
 				case "alive":
-					for _, host := range activeHosts {
-						table.Append([]string{host.String(), ""})
+					for _, host := range report.ActiveHosts {
+						table.Append([]string{activeLabel(host), ""})
 					}
 				case "available":
 					for _, host := range inactiveHosts {
-						table.Append([]string{host.String(), ""})
+						table.Append([]string{inactiveLabel(host, leased), ""})
 					}
 				}
 
 				table.Render()
+				printLeaseCheck(report.LeaseCheck)
 
 				fmt.Printf("\nTotal IPs in subnet: %s%d%s\n", boldText, totalCount, colorReset)
 				fmt.Printf("Hosts responding: %s%s%d%s (%0.1f%%)\n",
@@ -209,10 +257,44 @@ func runCLI(cmd *cobra.Command, args []string) {
 					fmt.Println("    " + colorPurple + "No hosts found on this interface." + colorReset)
 				}
 			}
-		}(iface)
+		}(iface, profile)
 	}
 
 	wg.Wait()
+	bar.finish()
+
+	report := &networkutils.ScanReport{
+		Version:    networkutils.ReportVersion,
+		StartedAt:  initialTime,
+		Elapsed:    time.Since(initialTime),
+		Interfaces: reports,
+	}
+	for _, r := range reports {
+		report.TotalIPsScanned += r.TotalIPsScanned
+	}
+	report = networkutils.MergeReports(report)
+
+	if structured {
+		opts := export.Options{Show: showMode, Args: strings.Join(os.Args, " ")}
+		if err := export.Write(os.Stdout, report, output, opts); err != nil {
+			log.Fatalf("Error writing %s output: %v", output, err)
+		}
+	}
+
+	if inventoryPath != "" {
+		// Only scans of every interface are kept for diffing, as a partial
+		// scan would show the other interfaces' hosts as gone.
+		if err := recordInventory(inventoryPath, report, ifaceName == ""); err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating inventory: %v\n", err)
+		}
+	}
+
+	if structured {
+		if ifaceName != "" && !found {
+			log.Fatalf("No interface found with the name '%s'", ifaceName)
+		}
+		return
+	}
 
 	if ifaceName != "" && !found && !scriptable {
 		fmt.Printf(colorRed+"No interface found with the name '%s'"+colorReset+"\n", ifaceName)
@@ -220,3 +302,64 @@ func runCLI(cmd *cobra.Command, args []string) {
 		fmt.Printf(boldText+colorBlue+"Execution time: %v"+colorReset+"\n", time.Since(initialTime))
 	}
 }
+
+// activeLabel shows an active host with its lease hostname, if any.
+func activeLabel(host networkutils.HostResult) string {
+	if host.Lease != nil && host.Lease.Hostname != "" {
+		return fmt.Sprintf("%s (%s)", host.IP, host.Lease.Hostname)
+	}
+	return host.IP.String()
+}
+
+// inactiveLabel marks silent addresses that are still leased, as they are
+// not actually free.
+func inactiveLabel(ip net.IP, leased map[string]bool) string {
+	if leased[ip.String()] {
+		return fmt.Sprintf("%s %s(leased)%s", ip, colorYellow, colorReset)
+	}
+	return ip.String()
+}
+
+// printLeaseCheck lists the disagreements between DHCP leases and a scan.
+func printLeaseCheck(check *networkutils.LeaseCheck) {
+	if check == nil {
+		return
+	}
+	if len(check.LeasedInactive) > 0 {
+		fmt.Printf("\n%sLeased but not responding: %d%s\n", colorYellow, len(check.LeasedInactive), colorReset)
+		for _, lease := range check.LeasedInactive {
+			fmt.Printf("    %-15s %-17s %s\n", lease.IP, lease.MAC, lease.Hostname)
+		}
+	}
+	if len(check.ActiveUnleased) > 0 {
+		fmt.Printf("\n%sResponding without a lease (static or rogue): %d%s\n", colorRed, len(check.ActiveUnleased), colorReset)
+		for _, ip := range check.ActiveUnleased {
+			fmt.Printf("    %s\n", ip)
+		}
+	}
+}
+
+// printScanErrors reports probe failures so that they are not mistaken for an
+// empty network. In scriptable mode they go to stderr to keep stdout clean.
+func printScanErrors(ifaceName string, summary []networkutils.ErrorSummary, scriptable bool) {
+	if scriptable {
+		for _, s := range summary {
+			fmt.Fprintf(os.Stderr, "%s: %s (%d): %s\n", ifaceName, s.Kind, s.Count, s.Message)
+		}
+		return
+	}
+
+	fmt.Printf(colorRed+"Error probing hosts on interface %s:"+colorReset+"\n", ifaceName)
+	for _, s := range summary {
+		fmt.Printf(colorRed+"    %s (%d probes): %s"+colorReset+"\n", s.Kind, s.Count, s.Message)
+	}
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"goscan/config"
+	"goscan/networkutils"
+	"goscan/networkutils/simnet"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// captureStdout returns what f writes to the standard output.
+func captureStdout(t *testing.T, f func()) []byte {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan []byte)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.Bytes()
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+func TestCLIConfigProfiles(t *testing.T) {
+	n := simnet.New(1)
+	if err := n.AddInterface("eth0", "02:00:00:00:00:01", "192.0.2.10/29"); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.AddInterface("eth1", "02:00:00:00:00:02", "198.51.100.10/29"); err != nil {
+		t.Fatal(err)
+	}
+	mac, _ := net.ParseMAC("02:00:00:00:00:09")
+	n.AddHost(simnet.Host{IP: net.ParseIP("192.0.2.9"), MAC: mac, ARP: true, ICMP: true})
+	n.AddHost(simnet.Host{IP: net.ParseIP("198.51.100.9"), ICMP: true})
+
+	transport := networkutils.DefaultScanner.Transport
+	networkutils.DefaultScanner.Transport = n
+	t.Cleanup(func() { networkutils.DefaultScanner.Transport = transport })
+	saved := config.GetServerConfig()
+	t.Cleanup(func() { config.SetServerConfig(saved) })
+
+	// eth1 is assigned a profile without ICMP, so its host goes unseen
+	path := filepath.Join(t.TempDir(), "goscan.json")
+	err := os.WriteFile(path, []byte(`{
+		"profiles": [
+			{"name": "sim", "extends": "fast", "timeout": "20ms"},
+			{"name": "arp-only", "extends": "sim", "methods": ["arp"]}
+		],
+		"interfaceProfiles": {"eth1": "arp-only"}
+	}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := captureStdout(t, func() {
+		cmd := NewRootCmd()
+		cmd.SetArgs([]string{"-c", path, "--profile", "sim", "-o", "json"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	var report networkutils.ScanReport
+	if err := json.Unmarshal(out, &report); err != nil {
+		t.Fatalf("decoding %q: %v", out, err)
+	}
+	want := map[string]struct {
+		profile string
+		active  int
+	}{
+		"eth0": {"sim", 1},
+		"eth1": {"arp-only", 0},
+	}
+	if len(report.Interfaces) != len(want) {
+		t.Fatalf("scanned %d interfaces, want %d", len(report.Interfaces), len(want))
+	}
+	for _, iface := range report.Interfaces {
+		w := want[iface.Name]
+		if iface.Profile != w.profile || len(iface.ActiveHosts) != w.active {
+			t.Errorf("%s: profile %q, %d active hosts, want %q and %d", iface.Name, iface.Profile, len(iface.ActiveHosts), w.profile, w.active)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"goscan/inventory"
+	"goscan/networkutils"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func NewDiffCmd() *cobra.Command {
+	diffCmd := &cobra.Command{
+		Use:   "diff [old.json new.json]",
+		Short: "Compare two scan reports",
+		Long: `Compare two JSON scan reports and list hosts that appeared, disappeared,
+changed MAC address or changed hostname.
+
+Without arguments, the latest scan saved in the inventory is compared with
+the previous one, or with the baseline when --against baseline is given.
+The exit status is 1 when the reports differ.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 && len(args) != 2 {
+				return fmt.Errorf("expected two report files, got %d", len(args))
+			}
+			return nil
+		},
+		Run: runDiff,
+	}
+
+	diffCmd.Flags().Bool("json", false, "Print the differences as JSON")
+	diffCmd.Flags().String("against", inventory.ReportPrevious, "Saved report to compare the latest scan with: previous or baseline")
+
+	return diffCmd
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	scriptable, _ := cmd.Flags().GetBool("scriptable")
+
+	var before, after *networkutils.ScanReport
+	var err error
+	if len(args) == 2 {
+		if before, err = readReportFile(args[0]); err != nil {
+			log.Fatal(err)
+		}
+		if after, err = readReportFile(args[1]); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		path, _ := cmd.Flags().GetString("inventory")
+		against, _ := cmd.Flags().GetString("against")
+		if path == "" {
+			path = inventory.DefaultPath
+		}
+		if before, after, err = savedReports(path, against); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	diff := networkutils.DiffReports(before, after)
+	if asJSON {
+		printJSON(diff)
+	} else {
+		printDiff(os.Stdout, diff, !scriptable)
+	}
+
+	if !diff.Empty() {
+		os.Exit(1)
+	}
+}
+
+func readReportFile(path string) (*networkutils.ScanReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	report, err := networkutils.ReadReport(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return report, nil
+}
+
+// savedReports loads the latest report from the inventory and the one named
+// by against.
+func savedReports(path, against string) (before, after *networkutils.ScanReport, err error) {
+	if against != inventory.ReportPrevious && against != inventory.ReportBaseline {
+		return nil, nil, fmt.Errorf("unknown report %q (expected previous or baseline)", against)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("no inventory at %s: %w", path, err)
+	}
+	store, err := inventory.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err = store.LoadReport(inventory.ReportLatest)
+	if errors.Is(err, inventory.ErrNoReport) {
+		return nil, nil, errors.New("no scan saved in the inventory yet")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	before, err = store.LoadReport(against)
+	if errors.Is(err, inventory.ErrNoReport) {
+		return nil, nil, fmt.Errorf("no %s scan saved in the inventory", against)
+	}
+	return before, after, err
+}
+
+// printDiff writes a report diff for humans, one host per line: "+" for
+// appeared, "-" for disappeared and "~" for changed hosts.
+func printDiff(w io.Writer, diff *networkutils.ReportDiff, color bool) {
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+	line := func(c, format string, a ...interface{}) {
+		fmt.Fprintln(w, paint(c, strings.TrimRight(fmt.Sprintf(format, a...), " ")))
+	}
+
+	if color {
+		fmt.Fprintf(w, boldText+"Changes from %s to %s"+colorReset+"\n",
+			diff.From.Local().Format("2006-01-02 15:04:05"), diff.To.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	for _, h := range diff.Appeared {
+		line(colorGreen, "+ %-15s %-12s %-17s %s", h.IP, h.Interface, h.MAC, strings.Join(h.Names, ","))
+	}
+	for _, h := range diff.Disappeared {
+		line(colorRed, "- %-15s %-12s %-17s %s", h.IP, h.Interface, h.MAC, strings.Join(h.Names, ","))
+	}
+	for _, h := range diff.MACChanged {
+		line(colorYellow, "~ %-15s %-12s mac %s -> %s", h.IP, h.Interface, h.OldMAC, h.MAC)
+	}
+	for _, h := range diff.NameChanged {
+		line(colorYellow, "~ %-15s %-12s name %s -> %s", h.IP, h.Interface, namesOrNone(h.OldNames), namesOrNone(h.Names))
+	}
+
+	if color {
+		if diff.Empty() {
+			fmt.Fprintln(w, colorPurple+"No changes."+colorReset)
+			return
+		}
+		fmt.Fprintf(w, "\n%s%d appeared%s, %s%d disappeared%s, %s%d changed%s\n",
+			colorGreen, len(diff.Appeared), colorReset,
+			colorRed, len(diff.Disappeared), colorReset,
+			colorYellow, len(diff.MACChanged)+len(diff.NameChanged), colorReset)
+	}
+}
+
+func namesOrNone(names []string) string {
+	if len(names) == 0 {
+		return "(none)"
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"goscan/config"
+	"goscan/events"
+	"goscan/inventory"
+	"goscan/notify"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewEmailCmd() *cobra.Command {
+	emailCmd := &cobra.Command{
+		Use:   "email",
+		Short: "Test the email settings of a configuration file",
+	}
+
+	testCmd := &cobra.Command{
+		Use:   "test",
+		Short: "Send a test alert email",
+		Args:  cobra.NoArgs,
+		Run:   runEmailTest,
+	}
+	testCmd.Flags().StringP("config", "c", "", "JSON configuration file with the email settings")
+	testCmd.MarkFlagRequired("config")
+
+	summaryCmd := &cobra.Command{
+		Use:   "summary",
+		Short: "Send the daily summary of the inventory now",
+		Args:  cobra.NoArgs,
+		Run:   runEmailSummary,
+	}
+	summaryCmd.Flags().StringP("config", "c", "", "JSON configuration file with the email settings")
+	summaryCmd.Flags().Bool("print", false, "Print the summary instead of sending it")
+	summaryCmd.MarkFlagRequired("config")
+
+	emailCmd.AddCommand(testCmd, summaryCmd)
+	return emailCmd
+}
+
+// loadMailer reads the email settings of a configuration file.
+func loadMailer(cmd *cobra.Command) (*notify.Mailer, config.ServerConfig) {
+	configPath, _ := cmd.Flags().GetString("config")
+	if err := config.LoadFile(configPath); err != nil {
+		log.Fatal(err)
+	}
+	cfg := config.GetServerConfig()
+	if cfg.Email.Host == "" {
+		log.Fatalf("No email host set in %s", configPath)
+	}
+	m, err := notify.NewMailer(cfg.Email)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return m, cfg
+}
+
+func runEmailTest(cmd *cobra.Command, args []string) {
+	m, cfg := loadMailer(cmd)
+	e := events.New(events.Test, "Test alert from goscan")
+	if err := m.SendAlert([]events.Event{e}); err != nil {
+		log.Fatalf("Failed to send test email: %v", err)
+	}
+	fmt.Printf(colorGreen+"Test email sent to %s"+colorReset+"\n", strings.Join(cfg.Email.To, ", "))
+}
+
+func runEmailSummary(cmd *cobra.Command, args []string) {
+	m, cfg := loadMailer(cmd)
+	printOnly, _ := cmd.Flags().GetBool("print")
+
+	path, _ := cmd.Flags().GetString("inventory")
+	if path == "" {
+		path = cfg.InventoryPath
+	}
+	if path == "" {
+		path = inventory.DefaultPath
+	}
+	store, err := inventory.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	summary, err := notify.BuildSummary(store, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if printOnly {
+		text, err := m.RenderSummary(summary)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(text)
+		return
+	}
+	if err := m.SendSummary(summary); err != nil {
+		log.Fatalf("Failed to send summary email: %v", err)
+	}
+	fmt.Printf(colorGreen+"Summary sent to %s"+colorReset+"\n", strings.Join(cfg.Email.To, ", "))
+}
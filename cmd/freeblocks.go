@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"goscan/config"
+	"goscan/inventory"
+	"goscan/leases"
+	"goscan/networkutils"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func NewFreeBlocksCmd() *cobra.Command {
+	freeBlocksCmd := &cobra.Command{
+		Use:   "free-blocks",
+		Short: "List runs of consecutive free addresses",
+		Long: `Scan and list the runs of at least --size consecutive addresses that did
+not answer, hold no valid DHCP lease (--leases) and are outside the DHCP
+pools and reservations (--pool and --reserved, or dhcpPools and
+dhcpReservations in the --config file).
+
+With --aligned, list every free block of exactly --size addresses starting
+at a multiple of it, as CIDR blocks: --size 8 --aligned lists free /29s.`,
+		Args: cobra.NoArgs,
+		Run:  runFreeBlocks,
+	}
+
+	freeBlocksCmd.Flags().Int("size", 1, "Least number of consecutive free addresses")
+	freeBlocksCmd.Flags().Bool("aligned", false, "List blocks of exactly --size addresses aligned on their size")
+	freeBlocksCmd.Flags().StringSlice("pool", nil, "DHCP pool to leave out, as an address range or CIDR block")
+	freeBlocksCmd.Flags().StringSlice("reserved", nil, "Reserved addresses to leave out, as addresses, ranges or CIDR blocks")
+	freeBlocksCmd.Flags().StringP("config", "c", "", "JSON configuration file with leaseFiles, dhcpPools and dhcpReservations")
+	freeBlocksCmd.Flags().Bool("json", false, "Print the blocks as JSON")
+
+	return freeBlocksCmd
+}
+
+func runFreeBlocks(cmd *cobra.Command, args []string) {
+	size, _ := cmd.Flags().GetInt("size")
+	aligned, _ := cmd.Flags().GetBool("aligned")
+	pools, _ := cmd.Flags().GetStringSlice("pool")
+	reservations, _ := cmd.Flags().GetStringSlice("reserved")
+	asJSON, _ := cmd.Flags().GetBool("json")
+	scriptable, _ := cmd.Flags().GetBool("scriptable")
+
+	if err := networkutils.ValidateBlockSize(size, aligned); err != nil {
+		log.Fatal(err)
+	}
+	cfg := loadScanConfig(cmd)
+	cfg.DHCPPools = append(cfg.DHCPPools, pools...)
+	cfg.DHCPReservations = append(cfg.DHCPReservations, reservations...)
+	reserved, err := reservedRanges(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Addresses handed out by goscan allocate are not free either
+	path := inventoryPath(cmd, cfg)
+	if _, err := os.Stat(path); err == nil {
+		store, err := inventory.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		held, err := reservationRanges(store)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reserved = append(reserved, held...)
+	}
+
+	report := scanSelected(cfg)
+	blocks := report.FreeBlocks(networkutils.BlockOptions{Size: size, Aligned: aligned, Reserved: reserved})
+	if n := unprobedCount(report); n > 0 {
+		fmt.Fprintf(os.Stderr, colorYellow+"%d addresses whose probes failed are left out of the free blocks."+colorReset+"\n", n)
+	}
+
+	if asJSON {
+		printJSON(blocks)
+		return
+	}
+	if scriptable {
+		for _, b := range blocks {
+			if b.CIDR != "" {
+				fmt.Println(b.CIDR)
+			} else {
+				fmt.Printf("%s-%s\n", b.First, b.Last)
+			}
+		}
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Interface", "First", "Last", "Size", "CIDR"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.SetColumnSeparator("   ")
+	table.SetAutoWrapText(false)
+	total := 0
+	for _, b := range blocks {
+		table.Append([]string{b.Interface, b.First.String(), b.Last.String(), strconv.Itoa(b.Size), b.CIDR})
+		total += b.Size
+	}
+	table.Render()
+
+	what := fmt.Sprintf("runs of %d or more", size)
+	if aligned {
+		what = fmt.Sprintf("blocks of %d", size)
+	}
+	fmt.Printf("\nFree %s addresses: %s%s%d%s (%d addresses)\n",
+		what, boldText, colorBlue, len(blocks), colorReset, total)
+}
+
+// unprobedCount returns the number of addresses whose probes failed, which
+// are neither used nor free.
+func unprobedCount(report *networkutils.ScanReport) int {
+	n := 0
+	for _, iface := range report.Interfaces {
+		n += len(iface.Unprobed)
+	}
+	return n
+}
+
+// loadScanConfig reads the configuration file given with --config, if any,
+// applies the scan and interface filter flags to it and enters the
+// namespace given with --netns.
+func loadScanConfig(cmd *cobra.Command) config.ServerConfig {
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath != "" {
+		if err := config.LoadFile(configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	cfg := config.GetServerConfig()
+
+	flags := cmd.Flags()
+	if flags.Changed("include") {
+		cfg.IncludeInterfaces, _ = flags.GetStringSlice("include")
+	}
+	if flags.Changed("exclude") {
+		cfg.ExcludeInterfaces, _ = flags.GetStringSlice("exclude")
+	}
+	if flags.Changed("type") {
+		cfg.InterfaceTypes, _ = flags.GetStringSlice("type")
+		if err := networkutils.ValidateInterfaceTypes(cfg.InterfaceTypes); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if ifaceName, _ := flags.GetString("interface"); ifaceName != "" {
+		// An interface asked for by name is scanned whatever the filters say
+		cfg.IncludeInterfaces = []string{ifaceName}
+		cfg.ExcludeInterfaces = nil
+		cfg.InterfaceTypes = nil
+	}
+	if flags.Changed("timeout") {
+		timeout, _ := flags.GetInt("timeout")
+		cfg.Timeout = time.Duration(timeout) * time.Millisecond
+	}
+	if flags.Changed("profile") {
+		cfg.Profile, _ = flags.GetString("profile")
+	}
+	if flags.Changed("leases") {
+		cfg.LeaseFiles, _ = flags.GetStringSlice("leases")
+	}
+	config.SetServerConfig(cfg)
+
+	if netns, _ := flags.GetString("netns"); netns != "" {
+		scanner, err := networkutils.NewNamespaceScanner(netns)
+		if err != nil {
+			log.Fatalf("Error entering network namespace: %v", err)
+		}
+		networkutils.DefaultScanner = scanner
+	}
+	return cfg
+}
+
+// scanSelected scans the interfaces selected by the configuration and checks
+// the result against its DHCP leases.
+func scanSelected(cfg config.ServerConfig) *networkutils.ScanReport {
+	if _, err := networkutils.LookupProfile(cfg.Profile); err != nil {
+		log.Fatalf("Error selecting scan profile: %v", err)
+	}
+	dhcpLeases, err := leases.ReadFiles(cfg.LeaseFiles)
+	if err != nil {
+		log.Fatalf("Error reading DHCP leases: %v", err)
+	}
+
+	bar := newProgressBar()
+	if bar != nil {
+		networkutils.DefaultScanner.Progress = bar.update
+	}
+	report, err := networkutils.FetchAllNetworkData()
+	bar.finish()
+	if err != nil {
+		log.Fatalf("Error scanning: %v", err)
+	}
+	if len(report.Interfaces) == 0 {
+		log.Fatal("No interface to scan.")
+	}
+	for _, iface := range report.Interfaces {
+		if len(iface.Errors) > 0 {
+			printScanErrors(iface.QualifiedName(), iface.Errors, true)
+		}
+	}
+	if len(dhcpLeases) > 0 {
+		report.ApplyLeases(dhcpLeases, time.Now())
+	}
+	return report
+}
+
+// reservedRanges returns the DHCP pools and reservations of the
+// configuration, which are never offered as free.
+func reservedRanges(cfg config.ServerConfig) ([]networkutils.AddressRange, error) {
+	pools, err := networkutils.ParseAddressRanges(cfg.DHCPPools)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DHCP pool: %w", err)
+	}
+	reservations, err := networkutils.ParseAddressRanges(cfg.DHCPReservations)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DHCP reservation: %w", err)
+	}
+	return append(pools, reservations...), nil
+}
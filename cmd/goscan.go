@@ -2,7 +2,12 @@ package main
 
 import (
 	"fmt"
+	"goscan/config"
+	"goscan/export"
+	"goscan/inventory"
+	"goscan/networkutils"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -34,6 +39,16 @@ Root command without subcommands will run in CLI mode.`,
 	rootCmd.PersistentFlags().BoolP("measure", "m", false, "Measure execution time")
 	rootCmd.PersistentFlags().StringP("show", "s", "all", "Show mode: all, alive, or available")
 	rootCmd.PersistentFlags().BoolP("scriptable", "q", false, "Scriptable output (no headers, no extra text)")
+	rootCmd.PersistentFlags().StringP("output", "o", export.FormatTable, "Output format: "+strings.Join(export.Formats, ", "))
+	rootCmd.PersistentFlags().StringSlice("include", nil, "Only scan interfaces matching these glob patterns")
+	rootCmd.PersistentFlags().StringSlice("exclude", config.DefaultExcludeInterfaces, "Skip interfaces matching these glob patterns")
+	rootCmd.PersistentFlags().StringSlice("type", nil, "Only scan these interface types: "+strings.Join(networkutils.InterfaceTypes, ", "))
+	rootCmd.PersistentFlags().String("netns", "", "Scan inside a network namespace (name or path)")
+	rootCmd.PersistentFlags().String("inventory", "", "Record results in this inventory database (default for the server: "+inventory.DefaultPath+")")
+	rootCmd.PersistentFlags().StringSlice("leases", nil, "DHCP lease files to check results against, as [dnsmasq|dhcpd|kea:]path")
+	rootCmd.PersistentFlags().String("profile", networkutils.DefaultProfileName, "Scan profile: "+strings.Join(networkutils.ProfileNames(), ", "))
+	// Subcommands declare their own --config, for the settings they read
+	rootCmd.Flags().StringP("config", "c", "", "JSON configuration file (profiles, per-interface settings, leaseFiles)")
 
 	aliveCmd := &cobra.Command{
 		Use:     "alive",
@@ -60,14 +75,30 @@ Root command without subcommands will run in CLI mode.`,
 	aliveCmd.PersistentFlags().IntP("timeout", "t", 500, "Timeout in milliseconds")
 	aliveCmd.PersistentFlags().StringP("interface", "i", "", "Specify network interface name")
 	aliveCmd.PersistentFlags().BoolP("measure", "m", false, "Measure execution time")
+	aliveCmd.Flags().StringP("config", "c", "", "JSON configuration file (profiles, per-interface settings, leaseFiles)")
 
 	availableCmd.PersistentFlags().IntP("timeout", "t", 500, "Timeout in milliseconds")
 	availableCmd.PersistentFlags().StringP("interface", "i", "", "Specify network interface name")
 	availableCmd.PersistentFlags().BoolP("measure", "m", false, "Measure execution time")
+	availableCmd.Flags().StringP("config", "c", "", "JSON configuration file (profiles, per-interface settings, leaseFiles)")
 
 	rootCmd.AddCommand(aliveCmd)
 	rootCmd.AddCommand(availableCmd)
 	rootCmd.AddCommand(NewServerCmd())
+	rootCmd.AddCommand(NewInventoryCmd())
+	rootCmd.AddCommand(NewDiffCmd())
+	rootCmd.AddCommand(NewAnnotateCmd())
+	rootCmd.AddCommand(NewImportCmd())
+	rootCmd.AddCommand(NewWebhookCmd())
+	rootCmd.AddCommand(NewEmailCmd())
+	rootCmd.AddCommand(NewMQTTCmd())
+	rootCmd.AddCommand(NewFreeBlocksCmd())
+	rootCmd.AddCommand(NewAllocateCmd())
+	rootCmd.AddCommand(NewReservationsCmd())
+	rootCmd.AddCommand(NewWatchCmd())
+	rootCmd.AddCommand(NewTUICmd())
+	rootCmd.AddCommand(NewHostCmd())
+	rootCmd.AddCommand(NewInterfacesCmd())
 
 	return rootCmd
 }
@@ -85,6 +116,8 @@ func NewServerCmd() *cobra.Command {
 	serverCmd.Flags().String("ssl-cert", "", "SSL certificate file")
 	serverCmd.Flags().String("ssl-key", "", "SSL key file")
 	serverCmd.Flags().Int("max-subnet-size", 1024, "Maximum subnet size to scan")
+	serverCmd.Flags().Duration("scan-interval", 0, "Scan all interfaces at this interval and record host availability (0 to disable)")
+	serverCmd.Flags().StringP("config", "c", "", "JSON configuration file (profiles, per-interface settings)")
 
 	return serverCmd
 }
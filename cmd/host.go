@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"goscan/networkutils"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func NewHostCmd() *cobra.Command {
+	hostCmd := &cobra.Command{
+		Use:   "host <ip>",
+		Short: "Inspect a single host in depth",
+		Long: `Run every probe against one host and print a consolidated report: its MAC
+address and vendor when it is on a local subnet, ICMP round-trip times and
+TTL, reverse names, the TCP ports open among --ports with the banner of
+each, and the certificate of the TLS services.
+
+With -q, print one tab-separated line per open port: port, service and
+banner.`,
+		Args: cobra.ExactArgs(1),
+		Run:  runHost,
+	}
+
+	hostCmd.Flags().IntSlice("ports", networkutils.CommonPorts, "TCP ports to sweep")
+	hostCmd.Flags().Int("pings", 5, "Number of ICMP echo requests to send")
+	hostCmd.Flags().StringP("config", "c", "", "JSON configuration file")
+	hostCmd.Flags().Bool("json", false, "Print the report as JSON")
+
+	return hostCmd
+}
+
+func runHost(cmd *cobra.Command, args []string) {
+	ports, _ := cmd.Flags().GetIntSlice("ports")
+	pings, _ := cmd.Flags().GetInt("pings")
+	timeout, _ := cmd.Flags().GetInt("timeout")
+	asJSON, _ := cmd.Flags().GetBool("json")
+	scriptable, _ := cmd.Flags().GetBool("scriptable")
+
+	ip := net.ParseIP(args[0])
+	if ip == nil {
+		log.Fatalf("invalid IP address %q", args[0])
+	}
+	if err := validatePorts(ports); err != nil {
+		log.Fatal(err)
+	}
+	if pings < 1 {
+		log.Fatalf("invalid --pings %d (expected at least 1)", pings)
+	}
+	loadScanConfig(cmd)
+
+	h := networkutils.DefaultScanner.InspectHost(ip, networkutils.InspectOptions{
+		Ports:   ports,
+		Timeout: time.Duration(timeout) * time.Millisecond,
+		Pings:   pings,
+	})
+
+	switch {
+	case asJSON:
+		printJSON(h)
+	case scriptable:
+		for _, p := range h.Ports {
+			fmt.Printf("%d\t%s\t%s\n", p.Port, p.Service, p.Banner)
+		}
+	default:
+		printInspection(h)
+	}
+}
+
+// validatePorts checks that ports are valid TCP port numbers.
+func validatePorts(ports []int) error {
+	for _, port := range ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %d (expected 1-65535)", port)
+		}
+	}
+	return nil
+}
+
+// printInspection prints the report of a host inspection.
+func printInspection(h *networkutils.HostInspection) {
+	state := colorRed + "down" + colorReset
+	if h.Up() {
+		state = colorGreen + "up" + colorReset
+	}
+	fmt.Printf("%sHost %s%s is %s   inspected in %s\n\n", boldText, h.IP, colorReset, state, h.Elapsed.Round(time.Millisecond))
+
+	switch {
+	case h.ARP != nil:
+		vendor := ""
+		if h.ARP.Vendor != "" {
+			vendor = " (" + h.ARP.Vendor + ")"
+		}
+		if h.Self {
+			fmt.Printf("  ARP     %s%s, this host's address on %s\n", h.ARP.MAC, vendor, h.Interface)
+		} else {
+			fmt.Printf("  ARP     %s%s, %s via %s\n", h.ARP.MAC, vendor, formatRTT(h.ARP.RTT), h.Interface)
+		}
+	case h.Interface != "":
+		fmt.Printf("  ARP     %sno reply%s via %s\n", colorYellow, colorReset, h.Interface)
+	default:
+		fmt.Printf("  ARP     not on a local subnet\n")
+	}
+
+	if icmp := h.ICMP; icmp != nil {
+		fmt.Printf("  ICMP    %d/%d replies, %.0f%% loss", icmp.Received, icmp.Sent, icmp.Loss)
+		if icmp.Received > 0 {
+			fmt.Printf(", rtt min/avg/max %s/%s/%s", formatRTT(icmp.MinRTT), formatRTT(icmp.AvgRTT), formatRTT(icmp.MaxRTT))
+		}
+		fmt.Println()
+		if icmp.TTL > 0 {
+			fmt.Printf("  TTL     %d, %d hops from %d (%s)\n", icmp.TTL, icmp.Hops, icmp.InitialTTL, icmp.OSHint)
+		}
+	}
+
+	names := strings.Join(h.Names, ", ")
+	if names == "" {
+		names = "none"
+	}
+	fmt.Printf("  Names   %s\n", names)
+	fmt.Printf("  Ports   %s%d%s open of %d swept\n", boldText, len(h.Ports), colorReset, h.PortsSwept)
+
+	if len(h.Ports) > 0 {
+		fmt.Println()
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Port", "Service", "Banner"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetBorder(false)
+		table.SetColumnSeparator("   ")
+		table.SetAutoWrapText(false)
+		for _, p := range h.Ports {
+			table.Append([]string{strconv.Itoa(p.Port) + "/tcp", p.Service, p.Banner})
+		}
+		table.Render()
+	}
+
+	for _, p := range h.Ports {
+		if p.TLS != nil {
+			printCertSummary(p.Port, p.TLS)
+		}
+	}
+
+	for _, e := range h.Errors {
+		fmt.Fprintf(os.Stderr, colorRed+"Error: %s"+colorReset+"\n", e)
+	}
+}
+
+// printCertSummary prints the TLS certificate of a port.
+func printCertSummary(port int, cert *networkutils.CertSummary) {
+	fmt.Printf("\n%sTLS on %d/tcp%s   %s\n", boldText, port, colorReset, cert.Version)
+	fmt.Printf("  Subject   %s\n", cert.Subject)
+	issuer := cert.Issuer
+	if cert.SelfSigned {
+		issuer += colorYellow + " (self-signed)" + colorReset
+	}
+	fmt.Printf("  Issuer    %s\n", issuer)
+	if len(cert.DNSNames) > 0 {
+		fmt.Printf("  Names     %s\n", strings.Join(cert.DNSNames, ", "))
+	}
+	validity := fmt.Sprintf("%s to %s", cert.NotBefore.Format(time.DateOnly), cert.NotAfter.Format(time.DateOnly))
+	switch {
+	case cert.Expired:
+		validity += colorRed + " (expired)" + colorReset
+	case time.Until(cert.NotAfter) < 30*24*time.Hour:
+		validity += colorYellow + " (expires soon)" + colorReset
+	}
+	fmt.Printf("  Valid     %s\n", validity)
+}
+
+// formatRTT rounds a round-trip time for display.
+func formatRTT(rtt time.Duration) string {
+	return rtt.Round(10 * time.Microsecond).String()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"goscan/importer"
+	"goscan/inventory"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func NewImportCmd() *cobra.Command {
+	importCmd := &cobra.Command{
+		Use:   "import <file>...",
+		Short: "Add results of other scanners to the inventory",
+		Long: `Add the hosts found by nmap (-oX) or masscan (-oJ) to the inventory, recording
+the tool that found them. The format is detected from the contents unless
+--format is given.`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  runImport,
+	}
+
+	importCmd.Flags().String("format", "", "Input format: "+strings.Join(importer.Formats, ", "))
+
+	return importCmd
+}
+
+func runImport(cmd *cobra.Command, args []string) {
+	path, _ := cmd.Flags().GetString("inventory")
+	format, _ := cmd.Flags().GetString("format")
+	scriptable, _ := cmd.Flags().GetBool("scriptable")
+	if path == "" {
+		path = inventory.DefaultPath
+	}
+	if format != "" {
+		if err := importer.ValidateFormat(format); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Read every file before recording anything, so that a bad file does
+	// not leave a partial import behind
+	results := make([]*importer.Result, len(args))
+	for i, file := range args {
+		result, err := importer.ReadFile(file, format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		results[i] = result
+	}
+
+	store, err := inventory.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for i, result := range results {
+		stats, err := store.Import(result.Source, result.Sightings)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if scriptable {
+			fmt.Printf("%s\t%s\t%d\t%d\n", args[i], result.Source, stats.Added, stats.Updated)
+			continue
+		}
+		fmt.Printf("Imported %s%d%s hosts from %s (%s): %s%d new%s, %d updated\n",
+			boldText, len(result.Sightings), colorReset, args[i], result.Source,
+			colorGreen, stats.Added, colorReset, stats.Updated)
+	}
+}
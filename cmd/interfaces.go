@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"goscan/export"
+	"goscan/networkutils"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func NewInterfacesCmd() *cobra.Command {
+	interfacesCmd := &cobra.Command{
+		Use:   "interfaces",
+		Short: "List the network interfaces and whether they are scanned",
+		Long: `List every network interface with its IPv4 and IPv6 addresses, the number
+of addresses of its IPv4 subnets, its default gateways, MAC address and link
+state, and whether scans leave it out and why: loopback, down, the
+--include, --exclude and --type filters, no IPv4 address, or subnets larger
+than maxSubnetSize in the --config file.
+
+-o selects the output format as for scans. With -q, print the table rows
+tab-separated, without the header.`,
+		Args: cobra.NoArgs,
+		Run:  runInterfaces,
+	}
+
+	interfacesCmd.Flags().StringP("config", "c", "", "JSON configuration file (maxSubnetSize, interface filters)")
+
+	return interfacesCmd
+}
+
+func runInterfaces(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	scriptable, _ := cmd.Flags().GetBool("scriptable")
+
+	output = strings.ToLower(output)
+	if err := export.ValidateFormat(output); err != nil {
+		log.Fatal(err)
+	}
+	loadScanConfig(cmd)
+
+	ifaces, err := networkutils.DefaultScanner.ListInterfaces()
+	if err != nil {
+		log.Fatalf("Error listing interfaces: %v", err)
+	}
+
+	if output == export.FormatTable && scriptable {
+		for i := range ifaces {
+			fmt.Println(strings.Join(export.InterfaceFields(&ifaces[i]), "\t"))
+		}
+		return
+	}
+	if err := export.WriteInterfaces(os.Stdout, ifaces, output); err != nil {
+		log.Fatalf("Error writing %s output: %v", output, err)
+	}
+	if output == export.FormatTable {
+		scanned := 0
+		for _, iface := range ifaces {
+			if !iface.Excluded {
+				scanned++
+			}
+		}
+		fmt.Printf("\nScanned interfaces: %s%s%d%s of %d\n", boldText, colorBlue, scanned, colorReset, len(ifaces))
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"goscan/inventory"
+	"goscan/networkutils"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+func NewInventoryCmd() *cobra.Command {
+	inventoryCmd := &cobra.Command{
+		Use:   "inventory [ip]",
+		Short: "Show hosts recorded in the inventory",
+		Long: `Show every host seen by previous scans, or the history of a single IP.
+Scans are recorded when --inventory is given; the server always records them.`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  runInventory,
+	}
+
+	inventoryCmd.Flags().Bool("json", false, "Print entries as JSON")
+	inventoryCmd.Flags().String("search", "", "Only show hosts matching this query (terms may be prefixed with owner:, role:, tag:, iface:, mac: or source:)")
+
+	return inventoryCmd
+}
+
+// recordInventory adds the active hosts of a scan to the inventory at path,
+// and saves the report as the latest full scan if full is set.
+func recordInventory(path string, report *networkutils.ScanReport, full bool) error {
+	store, err := inventory.Open(path)
+	if err != nil {
+		return err
+	}
+	if _, err := store.Record(report); err != nil {
+		return err
+	}
+	if full {
+		return store.RotateReport(report)
+	}
+	return nil
+}
+
+func runInventory(cmd *cobra.Command, args []string) {
+	path, _ := cmd.Flags().GetString("inventory")
+	asJSON, _ := cmd.Flags().GetBool("json")
+	scriptable, _ := cmd.Flags().GetBool("scriptable")
+	query, _ := cmd.Flags().GetString("search")
+	if path == "" {
+		path = inventory.DefaultPath
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		log.Fatalf("No inventory at %s: %v", path, err)
+	}
+
+	store, err := inventory.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var hosts []inventory.Host
+	switch {
+	case len(args) == 1:
+		hosts, err = store.Lookup(args[0])
+	case query != "":
+		hosts, err = store.Search(query)
+	default:
+		hosts, err = store.List()
+	}
+	if errors.Is(err, inventory.ErrNotFound) {
+		if !scriptable {
+			fmt.Printf(colorPurple+"%s has never been seen."+colorReset+"\n", args[0])
+		}
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if asJSON {
+		printJSON(hosts)
+		return
+	}
+
+	if scriptable {
+		for _, h := range hosts {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%d\n", h.IP, h.MAC, h.Interface,
+				h.FirstSeen.Format(time.RFC3339), h.LastSeen.Format(time.RFC3339), h.TimesSeen)
+		}
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"IP", "MAC", "Interface", "First Seen", "Last Seen", "Seen", "Methods", "Names", "Sources", "Owner", "Role", "Tags"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.SetColumnSeparator("   ")
+	table.SetAutoWrapText(false)
+
+	for _, h := range hosts {
+		var a inventory.Annotation
+		if h.Annotation != nil {
+			a = *h.Annotation
+		}
+		table.Append([]string{
+			h.IP,
+			h.MAC,
+			h.Interface,
+			h.FirstSeen.Local().Format("2006-01-02 15:04"),
+			h.LastSeen.Local().Format("2006-01-02 15:04"),
+			strconv.Itoa(h.TimesSeen),
+			strings.Join(h.Methods, ","),
+			strings.Join(h.Names, ","),
+			strings.Join(h.Sources, ","),
+			a.Owner,
+			a.Role,
+			strings.Join(a.Tags, ","),
+		})
+	}
+	table.Render()
+
+	if len(args) == 1 && len(hosts) > 0 && hosts[0].Annotation != nil && hosts[0].Annotation.Notes != "" {
+		fmt.Printf("\n%sNotes:%s %s\n", boldText, colorReset, hosts[0].Annotation.Notes)
+	}
+
+	fmt.Printf("\nHosts in inventory: %s%d%s\n", boldText, len(hosts), colorReset)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"goscan/config"
+	"goscan/notify"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+func NewMQTTCmd() *cobra.Command {
+	mqttCmd := &cobra.Command{
+		Use:   "mqtt",
+		Short: "Test the MQTT settings of a configuration file",
+	}
+
+	testCmd := &cobra.Command{
+		Use:   "test",
+		Short: "Connect to the broker and publish a message to <prefix>/test",
+		Args:  cobra.NoArgs,
+		Run:   runMQTTTest,
+	}
+	testCmd.Flags().String("broker", "", "Broker URL (tcp://host:1883 or tls://host:8883)")
+	testCmd.Flags().StringP("config", "c", "", "JSON configuration file with the MQTT settings")
+
+	mqttCmd.AddCommand(testCmd)
+	return mqttCmd
+}
+
+func runMQTTTest(cmd *cobra.Command, args []string) {
+	broker, _ := cmd.Flags().GetString("broker")
+	configPath, _ := cmd.Flags().GetString("config")
+
+	var cfg config.MQTTConfig
+	if configPath != "" {
+		if err := config.LoadFile(configPath); err != nil {
+			log.Fatal(err)
+		}
+		cfg = config.GetServerConfig().MQTT
+	}
+	if broker != "" {
+		cfg.Broker = broker
+	}
+	if cfg.Broker == "" {
+		log.Fatal("Give a broker with --broker or a configuration file with --config")
+	}
+
+	publisher, err := notify.NewMQTT(cfg, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := publisher.Test(); err != nil {
+		log.Fatalf("%s: %v", publisher.Name(), err)
+	}
+	fmt.Printf(colorGreen+"%s: published"+colorReset+"\n", publisher.Name())
+}
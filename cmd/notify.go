@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"goscan/config"
+	"goscan/events"
+	"goscan/inventory"
+	"goscan/networkutils"
+	"goscan/notify"
+	"log"
+)
+
+// eventTracker turns the server's scans into events, which eventBus
+// delivers to the configured sinks.
+var (
+	eventTracker = events.NewTracker(config.EventsConfig{})
+	eventBus     = &events.Bus{}
+	// mailer sends alert and summary emails; nil if email is not set up.
+	mailer *notify.Mailer
+	// mqttPublisher publishes presence to an MQTT broker; nil if not set up.
+	mqttPublisher *notify.MQTT
+)
+
+// setupNotifications creates the event tracker and subscribes the sinks
+// configured in the server settings.
+func setupNotifications(cfg config.ServerConfig) error {
+	eventTracker = events.NewTracker(cfg.Events)
+
+	for _, hook := range cfg.Webhooks {
+		webhook, filter, err := notify.NewWebhook(hook)
+		if err != nil {
+			return err
+		}
+		eventBus.Subscribe(webhook, filter)
+		log.Printf("Sending events to %s", webhook.Name())
+	}
+
+	if cfg.Syslog.Address != "" {
+		syslog, filter, err := notify.NewSyslog(cfg.Syslog)
+		if err != nil {
+			return err
+		}
+		eventBus.Subscribe(syslog, filter)
+		log.Printf("Sending events to %s", syslog.Name())
+	}
+
+	if cfg.Journald.Enabled {
+		journald, filter, err := notify.NewJournald(cfg.Journald)
+		if err != nil {
+			return err
+		}
+		eventBus.Subscribe(journald, filter)
+		log.Printf("Sending events to %s", journald.Name())
+	}
+
+	if cfg.Email.Host != "" {
+		m, err := notify.NewMailer(cfg.Email)
+		if err != nil {
+			return err
+		}
+		alerts, filter, err := notify.NewEmailAlerts(m, cfg.Email)
+		if err != nil {
+			return err
+		}
+		if cfg.Email.SummaryAt != "" {
+			if err := notify.ValidateTimeOfDay(cfg.Email.SummaryAt); err != nil {
+				return fmt.Errorf("email summary: %w", err)
+			}
+		}
+		mailer = m
+		eventBus.Subscribe(alerts, filter)
+		log.Printf("Sending events to %s", alerts.Name())
+	}
+
+	if cfg.MQTT.Broker != "" {
+		publisher, err := notify.NewMQTT(cfg.MQTT, cfg.Events.DownAfter)
+		if err != nil {
+			return err
+		}
+		mqttPublisher = publisher
+		mqttPublisher.Start()
+		log.Printf("Publishing presence to %s", publisher.Name())
+	}
+	return nil
+}
+
+// scheduleSummary starts the daily summary email of the inventory, if
+// configured.
+func scheduleSummary(cfg config.ServerConfig, store *inventory.Store) {
+	if mailer == nil || cfg.Email.SummaryAt == "" {
+		return
+	}
+	log.Printf("Sending a daily summary at %s", cfg.Email.SummaryAt)
+	go notify.ScheduleSummary(mailer, store, cfg.Email.SummaryAt)
+}
+
+// publishScanStarted announces a scan of every interface, or of the one
+// named.
+func publishScanStarted(ifaceName string) {
+	e := events.New(events.ScanStarted, "Scanning all interfaces")
+	if ifaceName != "" {
+		e = events.New(events.ScanStarted, "Scanning "+ifaceName)
+		e.Interface = ifaceName
+	}
+	eventBus.Publish(e)
+}
+
+// publishScanError reports a scan that failed altogether.
+func publishScanError(err error) {
+	e := events.New(events.ScanError, "Scan failed: "+err.Error())
+	e.Error = "scan_failed"
+	eventBus.Publish(e)
+}
+
+// publishEvents derives the events of a scan and hands them to the sinks,
+// and the scan itself to the MQTT publisher.
+func publishEvents(report *networkutils.ScanReport, newHosts []inventory.Host) {
+	eventBus.Publish(eventTracker.Observe(report, newHosts)...)
+	if mqttPublisher != nil {
+		mqttPublisher.Observe(report)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"goscan/networkutils"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressBar draws the progress of the interfaces being scanned on one
+// line of a terminal.
+type progressBar struct {
+	mu    sync.Mutex
+	out   *os.File
+	scans map[string]networkutils.Progress
+	shown bool
+}
+
+// newProgressBar returns a bar drawing on stderr, or nil if stderr is not
+// a terminal. The methods of a nil bar do nothing.
+func newProgressBar() *progressBar {
+	if !isTerminal(os.Stderr) {
+		return nil
+	}
+	return &progressBar{out: os.Stderr, scans: make(map[string]networkutils.Progress)}
+}
+
+// update records the progress of an interface and redraws the bar.
+func (b *progressBar) update(p networkutils.Progress) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scans[p.Interface] = p
+	b.draw()
+}
+
+// hold clears the bar so that other output can be written, and returns a
+// function to call when done. The bar is drawn again at the next update.
+func (b *progressBar) hold() func() {
+	if b == nil {
+		return func() {}
+	}
+	b.mu.Lock()
+	b.clear()
+	return b.mu.Unlock
+}
+
+// finish clears the bar for good.
+func (b *progressBar) finish() {
+	b.hold()()
+}
+
+func (b *progressBar) clear() {
+	if b.shown {
+		fmt.Fprint(b.out, "\r"+clearLine)
+		b.shown = false
+	}
+}
+
+func (b *progressBar) draw() {
+	var total networkutils.Progress
+	var names []string
+	for name, p := range b.scans {
+		if !p.Done {
+			names = append(names, name)
+		}
+		total.Total += p.Total
+		total.Completed += p.Completed
+		total.Found += p.Found
+		if p.ETA > total.ETA {
+			total.ETA = p.ETA
+		}
+	}
+	if len(names) == 0 {
+		b.clear()
+		return
+	}
+	sort.Strings(names)
+
+	width := 80
+	if w, _, err := terminalSize(b.out); err == nil && w > 0 {
+		width = w
+	}
+	percent := total.Percent()
+	stats := fmt.Sprintf(" %3.0f%%  %d/%d  %d found", percent, total.Completed, total.Total, total.Found)
+	if total.Completed > 0 {
+		stats += "  ETA " + formatETA(total.ETA)
+	}
+	label := truncate("Scanning "+strings.Join(names, ", "), width/3)
+	barWidth := width - len([]rune(label)) - len(stats) - 4
+	bar := ""
+	if barWidth >= 10 {
+		filled := int(float64(barWidth) * percent / 100)
+		bar = " [" + colorGreen + strings.Repeat("█", filled) + colorReset + strings.Repeat("░", barWidth-filled) + "]"
+	}
+	fmt.Fprint(b.out, "\r"+colorCyan+label+colorReset+bar+stats+clearLine)
+	b.shown = true
+}
+
+// formatETA shows a time left as m:ss.
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// progressKeep is how long the server lists scans after they end, so that
+// clients polling /progress see them complete.
+const progressKeep = 30 * time.Second
+
+// scanProgress holds the progress of the server's scans.
+var scanProgress = &progressBoard{scans: make(map[string]networkutils.Progress)}
+
+// progressBoard keeps the latest progress of every interface scanned.
+type progressBoard struct {
+	mu    sync.Mutex
+	scans map[string]networkutils.Progress
+}
+
+func (b *progressBoard) update(p networkutils.Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scans[p.Interface] = p
+}
+
+// list returns the running scans and those that ended lately, by
+// interface, forgetting older ones.
+func (b *progressBoard) list(at time.Time) []networkutils.Progress {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	scans := make([]networkutils.Progress, 0, len(b.scans))
+	for name, p := range b.scans {
+		if p.Done && at.Sub(p.StartedAt.Add(p.Elapsed)) > progressKeep {
+			delete(b.scans, name)
+			continue
+		}
+		scans = append(scans, p)
+	}
+	sort.Slice(scans, func(i, j int) bool { return scans[i].Interface < scans[j].Interface })
+	return scans
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"goscan/networkutils"
+	"testing"
+	"time"
+)
+
+func TestProgressBoard(t *testing.T) {
+	now := time.Now()
+	board := &progressBoard{scans: make(map[string]networkutils.Progress)}
+	board.update(networkutils.Progress{Interface: "wlan0", Total: 254, Completed: 10, StartedAt: now.Add(-time.Hour)})
+	board.update(networkutils.Progress{Interface: "eth0", Total: 6, Completed: 6, Done: true, StartedAt: now.Add(-time.Minute), Elapsed: time.Second})
+	board.update(networkutils.Progress{Interface: "eth1", Total: 6, Completed: 6, Done: true, StartedAt: now.Add(-10 * time.Second), Elapsed: time.Second})
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want []string
+	}{
+		// eth0 ended 59s ago, eth1 9s ago; running scans are always listed
+		{"now", now, []string{"eth1", "wlan0"}},
+		{"later", now.Add(time.Minute), []string{"wlan0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, p := range board.list(tt.at) {
+				got = append(got, p.Interface)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("scans = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("scans = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+	if _, ok := board.scans["eth0"]; ok {
+		t.Error("expired scan of eth0 still held")
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0:00"},
+		{1400 * time.Millisecond, "0:01"},
+		{65 * time.Second, "1:05"},
+		{75 * time.Minute, "75:00"},
+	}
+
+	for _, tt := range tests {
+		if got := formatETA(tt.d); got != tt.want {
+			t.Errorf("formatETA(%s) = %s, want %s", tt.d, got, tt.want)
+		}
+	}
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"bytes"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"goscan/cmd/assets"
 	"goscan/config"
+	"goscan/export"
+	"goscan/inventory"
+	"goscan/leases"
+	"goscan/metrics"
 	"goscan/networkutils"
 	"goscan/sslutils"
 	"goscan/stats"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os/user"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
@@ -19,52 +28,187 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// scanners holds one scanner per scanned network namespace. The first one is
+// networkutils.DefaultScanner.
+var scanners = []*networkutils.Scanner{networkutils.DefaultScanner}
+
+// hostInventory records every scan made by the server. It is nil when no
+// inventory is open.
+var hostInventory *inventory.Store
+
+// scanMetrics exposes the results of every scan made by the server.
+var scanMetrics = metrics.NewCollector()
+
+// setupScanners opens the configured network namespaces.
+func setupScanners(cfg config.ServerConfig) error {
+	if cfg.Netns != "" {
+		scanner, err := networkutils.NewNamespaceScanner(cfg.Netns)
+		if err != nil {
+			return err
+		}
+		networkutils.DefaultScanner = scanner
+	}
+
+	scanners = []*networkutils.Scanner{networkutils.DefaultScanner}
+	for _, ns := range cfg.Namespaces {
+		scanner, err := networkutils.NewNamespaceScanner(ns)
+		if err != nil {
+			return err
+		}
+		scanners = append(scanners, scanner)
+	}
+	for _, scanner := range scanners {
+		scanner.Progress = scanProgress.update
+	}
+	return nil
+}
+
+// scannerFor returns the scanner for a namespace label ("" for the primary one).
+func scannerFor(namespace string) *networkutils.Scanner {
+	for _, scanner := range scanners {
+		if scanner.Namespace == namespace {
+			return scanner
+		}
+	}
+	return nil
+}
+
 func runServer(cmd *cobra.Command, args []string) {
+	configPath, _ := cmd.Flags().GetString("config")
 	listenAddress, _ := cmd.Flags().GetString("listen-address")
 	listenPort, _ := cmd.Flags().GetString("listen-port")
 	timeout, _ := cmd.Flags().GetInt("timeout")
 	sslCert, _ := cmd.Flags().GetString("ssl-cert")
 	sslKey, _ := cmd.Flags().GetString("ssl-key")
 	maxSubnetSize, _ := cmd.Flags().GetInt("max-subnet-size")
+	profile, _ := cmd.Flags().GetString("profile")
+	include, _ := cmd.Flags().GetStringSlice("include")
+	exclude, _ := cmd.Flags().GetStringSlice("exclude")
+	types, _ := cmd.Flags().GetStringSlice("type")
+	netns, _ := cmd.Flags().GetString("netns")
+	inventoryPath, _ := cmd.Flags().GetString("inventory")
+	scanInterval, _ := cmd.Flags().GetDuration("scan-interval")
+	leaseFiles, _ := cmd.Flags().GetStringSlice("leases")
+
+	if configPath != "" {
+		if err := config.LoadFile(configPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Without a config file the flags (and their defaults) are authoritative;
+	// with one, only flags given explicitly override the file.
+	useFlag := func(name string) bool {
+		return configPath == "" || cmd.Flags().Changed(name)
+	}
 
 	cfg := config.GetServerConfig()
-	cfg.ListenAddress = listenAddress
-	cfg.ListenPort = listenPort
-	cfg.Timeout = time.Duration(timeout) * time.Millisecond
-	cfg.SSLCertFile = sslCert
-	cfg.SSLKeyFile = sslKey
-	cfg.MaxSubnetSize = maxSubnetSize
+	if useFlag("listen-address") {
+		cfg.ListenAddress = listenAddress
+	}
+	if useFlag("listen-port") {
+		cfg.ListenPort = listenPort
+	}
+	if useFlag("timeout") {
+		cfg.Timeout = time.Duration(timeout) * time.Millisecond
+	}
+	if useFlag("ssl-cert") {
+		cfg.SSLCertFile = sslCert
+	}
+	if useFlag("ssl-key") {
+		cfg.SSLKeyFile = sslKey
+	}
+	if useFlag("max-subnet-size") {
+		cfg.MaxSubnetSize = maxSubnetSize
+	}
+	if useFlag("profile") {
+		cfg.Profile = profile
+	}
+	if useFlag("include") {
+		cfg.IncludeInterfaces = include
+	}
+	if useFlag("exclude") {
+		cfg.ExcludeInterfaces = exclude
+	}
+	if useFlag("type") {
+		cfg.InterfaceTypes = types
+	}
+	if useFlag("netns") {
+		cfg.Netns = netns
+	}
+	if useFlag("inventory") {
+		cfg.InventoryPath = inventoryPath
+	}
+	if useFlag("scan-interval") {
+		cfg.ScanInterval = config.Duration(scanInterval)
+	}
+	if useFlag("leases") {
+		cfg.LeaseFiles = leaseFiles
+	}
+	if cfg.InventoryPath == "" {
+		cfg.InventoryPath = inventory.DefaultPath
+	}
 	config.SetServerConfig(cfg)
 
+	if err := setupScanners(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := networkutils.ValidateInterfaceTypes(cfg.InterfaceTypes); err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := networkutils.LookupProfile(cfg.Profile); err != nil {
+		log.Fatal(err)
+	}
+	for ifaceName := range cfg.InterfaceProfiles {
+		if _, err := networkutils.ProfileForInterface(ifaceName); err != nil {
+			log.Fatalf("Invalid profile for interface %s: %v", ifaceName, err)
+		}
+	}
+
+	if _, err := leases.ReadFiles(cfg.LeaseFiles); err != nil {
+		log.Fatalf("Error reading DHCP leases: %v", err)
+	}
+
+	if _, err := reservedRanges(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := setupNotifications(cfg); err != nil {
+		log.Fatal(err)
+	}
+
 	currentUser, err := user.Current()
 	if err != nil || currentUser.Uid != "0" {
 		log.Fatal("Application requires administrator privileges to perform network scanning.")
 	}
 
+	store, err := inventory.Open(cfg.InventoryPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hostInventory = store
+	scheduleSummary(cfg, store)
+
 	go stats.MonitorRuntimeStats()
 
-	gin.SetMode(gin.ReleaseMode)
+	if cfg.ScanInterval > 0 {
+		go scheduleScans(time.Duration(cfg.ScanInterval))
+	}
 
-	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery())
+	gin.SetMode(gin.ReleaseMode)
 
-	staticFS, err := fs.Sub(assets.Templates, "templates/static")
+	router, err := newRouter()
 	if err != nil {
 		log.Fatal(err)
 	}
-	router.StaticFS("/static", http.FS(staticFS))
-
-	router.GET("/", allNetworksHTMLHandler)
-	router.GET("/networks", listNetworksHandler)
-	router.GET("/network/:iface", networkHandler)
-	router.GET("/all", allNetworksHandler)
-	router.GET("/stats", statsHandler)
 
-	address := fmt.Sprintf("%s:%s", listenAddress, listenPort)
+	address := fmt.Sprintf("%s:%s", cfg.ListenAddress, cfg.ListenPort)
 	log.Printf("Starting server at %s", address)
 
-	if sslCert != "" && sslKey != "" {
-		reloader, err := sslutils.NewCertReloader(sslCert, sslKey)
+	if cfg.SSLCertFile != "" && cfg.SSLKeyFile != "" {
+		reloader, err := sslutils.NewCertReloader(cfg.SSLCertFile, cfg.SSLKeyFile)
 		if err != nil {
 			log.Fatalf("Failed to initialize certificate reloader: %v", err)
 		}
@@ -89,6 +233,46 @@ func runServer(cmd *cobra.Command, args []string) {
 	}
 }
 
+// newRouter builds the HTTP routes. Handlers scan through
+// networkutils.DefaultScanner, so swapping its transport is enough to serve
+// a simulated network.
+func newRouter() (*gin.Engine, error) {
+	router := gin.New()
+	router.Use(gin.Logger(), gin.Recovery())
+
+	staticFS, err := fs.Sub(assets.Templates, "templates/static")
+	if err != nil {
+		return nil, err
+	}
+	router.StaticFS("/static", http.FS(staticFS))
+
+	router.GET("/", allNetworksHTMLHandler)
+	router.GET("/networks", listNetworksHandler)
+	router.GET("/network/:iface", networkHandler)
+	router.GET("/all", allNetworksHandler)
+	router.GET("/stats", statsHandler)
+	router.GET("/progress", progressHandler)
+	router.GET("/metrics", metricsHandler)
+	router.GET("/schema/scan-report.json", schemaHandler)
+	router.GET("/inventory", inventoryHandler)
+	router.GET("/inventory/:ip", inventoryHostHandler)
+	router.GET("/diff", diffHandler)
+	router.GET("/availability", availabilityHandler)
+	router.GET("/annotations", annotationsHandler)
+	router.GET("/annotations/:ip", annotationHandler)
+	router.PUT("/annotations/:ip", updateAnnotationHandler)
+	router.DELETE("/annotations/:ip", deleteAnnotationHandler)
+	router.GET("/availability/:ip", hostAvailabilityHandler)
+	router.POST("/baseline", baselineHandler)
+	router.GET("/free-blocks", freeBlocksHandler)
+	router.POST("/allocate", allocateHandler)
+	router.GET("/reservations", reservationsHandler)
+	router.DELETE("/reservations/:ip", releaseHandler)
+	router.GET("/host/:ip", hostHandler)
+
+	return router, nil
+}
+
 func statsHandler(c *gin.Context) {
 	stats := stats.GetStats()
 
@@ -100,55 +284,698 @@ func statsHandler(c *gin.Context) {
 	})
 }
 
-func listNetworksHandler(c *gin.Context) {
-	ifaces, err := networkutils.DiscoverInterfaces()
-	if err != nil {
+// scanProgressView is the progress of a scan as /progress shows it.
+type scanProgressView struct {
+	networkutils.Progress
+	Percent float64 `json:"percent"`
+}
+
+// progressHandler shows the progress of the running scans, and of those
+// that ended lately, with their sum.
+func progressHandler(c *gin.Context) {
+	scans := scanProgress.list(time.Now())
+	var total networkutils.Progress
+	views := make([]scanProgressView, 0, len(scans))
+	running := false
+	for _, p := range scans {
+		views = append(views, scanProgressView{Progress: p, Percent: p.Percent()})
+		total.Total += p.Total
+		total.Completed += p.Completed
+		total.Found += p.Found
+		if p.ETA > total.ETA {
+			total.ETA = p.ETA
+		}
+		running = running || !p.Done
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"running":   running,
+		"total":     total.Total,
+		"completed": total.Completed,
+		"found":     total.Found,
+		"percent":   total.Percent(),
+		"etaNs":     total.ETA,
+		"scans":     views,
+	})
+}
+
+// metricsHandler serves Prometheus metrics.
+func metricsHandler(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := scanMetrics.Write(&buf); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.Data(http.StatusOK, metrics.ContentType, buf.Bytes())
+}
+
+func listNetworksHandler(c *gin.Context) {
+	var ifaces []networkutils.InterfaceDetails
+	for _, scanner := range scanners {
+		found, err := scanner.DiscoverInterfaces()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ifaces = append(ifaces, found...)
+	}
 
 	c.JSON(http.StatusOK, ifaces)
 }
 
 func networkHandler(c *gin.Context) {
-	ifaceName := c.Param("iface")
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	scan, ok := scanRequestedInterface(c, c.Param("iface"))
+	if !ok {
+		return
+	}
+	report := scan.Interfaces[0]
+
+	status := http.StatusOK
+	if len(report.Errors) > 0 && report.TotalIPsScanned == 0 {
+		status = http.StatusInternalServerError
+	}
+	if format == export.FormatJSON {
+		// The JSON form of this endpoint is the interface report alone
+		c.JSON(status, report)
+		return
+	}
+	writeReport(c, status, scan, format)
+}
+
+// scanRequestedInterface scans and records the named interface of the
+// namespace given with ?netns=. It answers the request itself if the
+// interface cannot be scanned.
+func scanRequestedInterface(c *gin.Context, ifaceName string) (*networkutils.ScanReport, bool) {
 	if ifaceName == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Interface name is required."})
-		return
+		return nil, false
 	}
 
-	iface, err := networkutils.GetInterfaceByName(ifaceName)
+	scanner := scannerFor(c.Query("netns"))
+	if scanner == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Network namespace not found."})
+		return nil, false
+	}
+
+	iface, err := scanner.GetInterfaceByName(ifaceName)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Interface not found."})
+		return nil, false
+	}
+
+	profile, err := scanner.ProfileForInterface(iface.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	publishScanStarted(iface.QualifiedName())
+	startedAt := time.Now()
+	report := scanner.ScanInterface(iface, profile)
+	scan := &networkutils.ScanReport{
+		Version:         networkutils.ReportVersion,
+		StartedAt:       startedAt,
+		Elapsed:         time.Since(startedAt),
+		TotalIPsScanned: report.TotalIPsScanned,
+		Interfaces:      []networkutils.InterfaceReport{report},
+	}
+	applyLeases(scan)
+	recordScan(scan)
+	return scan, true
+}
+
+// scanAll scans every interface of every namespace and records the result
+// as the latest full scan.
+func scanAll() (*networkutils.ScanReport, error) {
+	publishScanStarted("")
+	reports := make([]*networkutils.ScanReport, 0, len(scanners))
+	for _, scanner := range scanners {
+		report, err := scanner.FetchAllNetworkData()
+		if err != nil {
+			publishScanError(err)
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	report := networkutils.MergeReports(reports...)
+	applyLeases(report)
+	recordScan(report)
+	if hostInventory != nil {
+		if err := hostInventory.RotateReport(report); err != nil {
+			log.Printf("Failed to save scan report: %v", err)
+		}
+	}
+	return report, nil
+}
+
+// scheduleScans runs a full scan at every interval and records host
+// availability from it.
+func scheduleScans(interval time.Duration) {
+	log.Printf("Scanning all interfaces every %s", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := scanAll()
+		if err != nil {
+			log.Printf("Scheduled scan failed: %v", err)
+		} else if hostInventory != nil {
+			if err := hostInventory.RecordAvailability(report); err != nil {
+				log.Printf("Failed to record availability: %v", err)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+func allNetworksHandler(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
 		return
 	}
 
-	config := config.GetServerConfig()
-	activeHosts, allHosts, err := networkutils.ProbeHosts(iface, config.Timeout)
+	report, err := scanAll()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error probing hosts: %v", err)})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	networkutils.SortIPs(activeHosts)
-	c.JSON(http.StatusOK, gin.H{
-		"interface":   iface.ToJSON(),
-		"activeHosts": activeHosts,
-		"totalHosts":  len(allHosts),
-	})
+	c.Header("X-Elapsed-Time", report.Elapsed.String())
+	c.Header("X-Total-IPs-Scanned", fmt.Sprintf("%d", report.TotalIPsScanned))
+	if format == export.FormatJSON {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	writeReport(c, http.StatusOK, report, format)
 }
 
-func allNetworksHandler(c *gin.Context) {
-	config := config.GetServerConfig()
-	data, err := networkutils.FetchAllNetworkData(config.Timeout)
+// exportFormat returns the output format asked for with ?format=, json by
+// default. It answers 400 itself if the format is unknown.
+func exportFormat(c *gin.Context) (string, bool) {
+	format := strings.ToLower(c.DefaultQuery("format", export.FormatJSON))
+	if err := export.ValidateFormat(format); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	switch c.DefaultQuery("show", export.ShowAlive) {
+	case export.ShowAll, export.ShowAlive, export.ShowAvailable:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "show must be all, alive or available."})
+		return "", false
+	}
+	return format, true
+}
+
+// writeReport writes a scan report in a non-JSON export format. ?show=
+// selects the addresses listed by the row-based formats.
+func writeReport(c *gin.Context, status int, report *networkutils.ScanReport, format string) {
+	var buf bytes.Buffer
+	opts := export.Options{Show: c.DefaultQuery("show", export.ShowAlive), Args: c.Request.URL.String()}
+	if err := export.Write(&buf, report, format, opts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(status, export.ContentType(format), buf.Bytes())
+}
+
+// applyLeases checks a scan against the configured DHCP lease files, which
+// are read again for every scan as the DHCP server keeps updating them.
+func applyLeases(report *networkutils.ScanReport) {
+	files := config.GetServerConfig().LeaseFiles
+	if len(files) == 0 {
+		return
+	}
+	dhcpLeases, err := leases.ReadFiles(files)
+	if err != nil {
+		log.Printf("Failed to read DHCP leases: %v", err)
+		return
+	}
+	report.ApplyLeases(dhcpLeases, time.Now())
+}
+
+// recordScan adds a scan to the metrics and the inventory, and publishes
+// the events it gives rise to. Failures are logged rather than failing the
+// request, as the scan itself succeeded.
+func recordScan(report *networkutils.ScanReport) {
+	scanMetrics.Observe(report)
+	var newHosts []inventory.Host
+	if hostInventory != nil {
+		added, err := hostInventory.Record(report)
+		if err != nil {
+			log.Printf("Failed to update inventory: %v", err)
+		}
+		newHosts = added
+	}
+	publishEvents(report, newHosts)
+}
+
+func inventoryHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	var hosts []inventory.Host
+	var err error
+	if query := c.Query("q"); query != "" {
+		hosts, err = hostInventory.Search(query)
+	} else {
+		hosts, err = hostInventory.List()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if hosts == nil {
+		hosts = []inventory.Host{}
+	}
+	c.JSON(http.StatusOK, hosts)
+}
+
+func inventoryHostHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	ip := c.Param("ip")
+	if net.ParseIP(ip) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address."})
+		return
+	}
+
+	hosts, err := hostInventory.Lookup(ip)
+	if errors.Is(err, inventory.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Host not found in inventory."})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, hosts)
+}
+
+func annotationsHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	annotations, err := hostInventory.Annotations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, annotations)
+}
+
+func annotationHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	ip := c.Param("ip")
+	if net.ParseIP(ip) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address."})
+		return
+	}
+
+	a, err := hostInventory.Annotation(ip)
+	if errors.Is(err, inventory.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Host has no annotation."})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+// updateAnnotationHandler replaces the annotation of a host with the JSON
+// body ({"owner", "role", "tags", "notes"}).
+func updateAnnotationHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	ip := c.Param("ip")
+	if net.ParseIP(ip) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address."})
+		return
+	}
+
+	var a inventory.Annotation
+	if err := c.ShouldBindJSON(&a); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a.UpdatedAt = time.Time{}
+	if err := hostInventory.Annotate(ip, a); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	a, err := hostInventory.Annotation(ip)
+	if errors.Is(err, inventory.ErrNotFound) {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func deleteAnnotationHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	ip := c.Param("ip")
+	if net.ParseIP(ip) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address."})
+		return
+	}
+	if err := hostInventory.Annotate(ip, inventory.Annotation{}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func availabilityHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	hosts, err := hostInventory.Availability(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hosts)
+}
+
+func hostAvailabilityHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	ip := c.Param("ip")
+	if net.ParseIP(ip) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address."})
+		return
+	}
+
+	hosts, err := hostInventory.HostAvailability(ip, time.Now())
+	if errors.Is(err, inventory.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No availability history for this host."})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hosts)
+}
+
+// diffHandler compares the latest full scan with the previous one, or with
+// the baseline when ?against=baseline. ?format=text returns the same lines
+// as "goscan diff -q".
+func diffHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	against := c.DefaultQuery("against", inventory.ReportPrevious)
+	if against != inventory.ReportPrevious && against != inventory.ReportBaseline {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "against must be previous or baseline."})
+		return
+	}
+
+	latest, err := hostInventory.LoadReport(inventory.ReportLatest)
+	if errors.Is(err, inventory.ErrNoReport) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No scan has been made yet."})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	old, err := hostInventory.LoadReport(against)
+	if errors.Is(err, inventory.ErrNoReport) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No %s scan saved.", against)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	diff := networkutils.DiffReports(old, latest)
+	if c.Query("format") == "text" {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		printDiff(c.Writer, diff, false)
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// baselineHandler saves the latest full scan as the baseline.
+func baselineHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	latest, err := hostInventory.LoadReport(inventory.ReportLatest)
+	if errors.Is(err, inventory.ErrNoReport) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No scan has been made yet."})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := hostInventory.SaveReport(inventory.ReportBaseline, latest); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"baseline": latest.StartedAt})
+}
+
+// freeBlocksHandler scans the interface given with ?interface=, or every
+// interface, and lists the runs of at least ?size= free addresses, or with
+// ?aligned=true the free blocks of exactly that size.
+func freeBlocksHandler(c *gin.Context) {
+	size := 1
+	if s := c.Query("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be a number."})
+			return
+		}
+		size = n
+	}
+	aligned := false
+	if s := c.Query("aligned"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "aligned must be true or false."})
+			return
+		}
+		aligned = b
+	}
+	if err := networkutils.ValidateBlockSize(size, aligned); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reserved, err := reservedRanges(config.GetServerConfig())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if hostInventory != nil {
+		held, err := reservationRanges(hostInventory)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		reserved = append(reserved, held...)
+	}
+
+	var report *networkutils.ScanReport
+	if ifaceName := c.Query("interface"); ifaceName != "" {
+		var ok bool
+		if report, ok = scanRequestedInterface(c, ifaceName); !ok {
+			return
+		}
+	} else if report, err = scanAll(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Addresses whose probes failed are left out of the blocks
+	c.Header("X-Unprobed-IPs", strconv.Itoa(unprobedCount(report)))
+	c.JSON(http.StatusOK, report.FreeBlocks(networkutils.BlockOptions{Size: size, Aligned: aligned, Reserved: reserved}))
+}
+
+func allocateHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	var req allocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Owner) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Owner is required."})
+		return
+	}
+	if req.TTL < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ttl must not be negative."})
+		return
+	}
+
+	scanner := scannerFor(c.Query("netns"))
+	if scanner == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Network namespace not found."})
+		return
+	}
+	if req.Subnet != "" {
+		subnet, err := parseSubnet(req.Subnet)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subnet."})
+			return
+		}
+		iface, err := scanner.GetInterfaceForSubnet(subnet)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No interface on that subnet."})
+			return
+		}
+		if req.Interface != "" && req.Interface != iface.Name {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Subnet is not on that interface."})
+			return
+		}
+		req.Interface = iface.Name
+	}
+
+	report, ok := scanRequestedInterface(c, req.Interface)
+	if !ok {
+		return
+	}
+	r, err := allocate(hostInventory, scanner, report, req)
+	if errors.Is(err, errNoFreeAddress) {
+		c.JSON(http.StatusConflict, gin.H{"error": "No free address left."})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, r)
+}
+
+func reservationsHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	reservations, err := hostInventory.Reservations(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reservations)
+}
+
+func releaseHandler(c *gin.Context) {
+	if hostInventory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Inventory is disabled."})
+		return
+	}
+
+	ip := c.Param("ip")
+	if net.ParseIP(ip) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address."})
+		return
+	}
+	err := hostInventory.Release(ip)
+	if errors.Is(err, inventory.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address is not reserved."})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// hostHandler inspects one host in depth, sweeping the comma-separated
+// ?ports= or the common ports and sending ?pings= echo requests.
+func hostHandler(c *gin.Context) {
+	ip := net.ParseIP(c.Param("ip"))
+	if ip == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address."})
+		return
+	}
+	var ports []int
+	if s := c.Query("ports"); s != "" {
+		for _, field := range strings.Split(s, ",") {
+			port, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ports must be a comma-separated list of numbers."})
+				return
+			}
+			ports = append(ports, port)
+		}
+		if err := validatePorts(ports); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	pings := 5
+	if s := c.Query("pings"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pings must be a number from 1 to 100."})
+			return
+		}
+		pings = n
+	}
+
+	scanner := scannerFor(c.Query("netns"))
+	if scanner == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Network namespace not found."})
+		return
+	}
+	c.JSON(http.StatusOK, scanner.InspectHost(ip, networkutils.InspectOptions{
+		Ports:   ports,
+		Timeout: config.GetServerConfig().Timeout,
+		Pings:   pings,
+	}))
+}
 
-	c.Header("X-Elapsed-Time", data["elapsedTime"].(time.Duration).String())
-	c.Header("X-Total-IPs-Scanned", fmt.Sprintf("%d", data["totalIPsScanned"].(int)))
-	c.JSON(http.StatusOK, data["results"])
+func schemaHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/schema+json", networkutils.ReportSchema)
 }
 
 func allNetworksHTMLHandler(c *gin.Context) {
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"goscan/config"
+	"goscan/inventory"
+	"goscan/metrics"
+	"goscan/networkutils"
+	"goscan/networkutils/simnet"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newTestServer serves a simulated eth0 on 192.0.2.8/29, where 192.0.2.9
+// answers ARP with port 22 open, 192.0.2.11 answers ICMP and every probe of
+// 192.0.2.13 fails with a permission error.
+func newTestServer(t *testing.T) *gin.Engine {
+	t.Helper()
+	n := simnet.New(1)
+	if err := n.AddInterface("eth0", "02:00:00:00:00:01", "192.0.2.10/29"); err != nil {
+		t.Fatal(err)
+	}
+	mac, _ := net.ParseMAC("02:00:00:00:00:09")
+	n.AddHost(simnet.Host{IP: net.ParseIP("192.0.2.9"), MAC: mac, ARP: true, ICMP: true, OpenPorts: []int{22}})
+	n.AddHost(simnet.Host{IP: net.ParseIP("192.0.2.11"), ICMP: true})
+	n.AddHost(simnet.Host{IP: net.ParseIP("192.0.2.13"), Err: &os.SyscallError{Syscall: "sendto", Err: syscall.EPERM}})
+
+	transport := networkutils.DefaultScanner.Transport
+	networkutils.DefaultScanner.Transport = n
+	t.Cleanup(func() { networkutils.DefaultScanner.Transport = transport })
+
+	saved := config.GetServerConfig()
+	cfg := saved
+	cfg.Timeout = 20 * time.Millisecond
+	cfg.Profile = "sim"
+	cfg.Profiles = []config.ProfileConfig{{Name: "sim", Extends: "fast", Timeout: config.Duration(20 * time.Millisecond)}}
+	cfg.InterfaceProfiles = nil
+	config.SetServerConfig(cfg)
+	t.Cleanup(func() { config.SetServerConfig(saved) })
+
+	router, err := newRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+// withInventory opens an inventory for the duration of a test.
+func withInventory(t *testing.T) {
+	t.Helper()
+	store, err := inventory.Open(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostInventory = store
+	t.Cleanup(func() { hostInventory = nil })
+}
+
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body.String(), err)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		check  func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "networks",
+			path:   "/networks",
+			status: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var ifaces []networkutils.InterfaceDetailsJSON
+				decode(t, w, &ifaces)
+				if len(ifaces) != 1 || ifaces[0].Name != "eth0" {
+					t.Errorf("networks = %+v, want eth0 alone", ifaces)
+				}
+			},
+		},
+		{
+			name:   "network",
+			path:   "/network/eth0",
+			status: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var report networkutils.InterfaceReport
+				decode(t, w, &report)
+				if ips := report.ActiveIPs(); len(ips) != 2 || !ips[0].Equal(net.ParseIP("192.0.2.9")) || !ips[1].Equal(net.ParseIP("192.0.2.11")) {
+					t.Errorf("active hosts = %v, want 192.0.2.9 and 192.0.2.11", ips)
+				}
+				if report.TotalIPsScanned != 5 {
+					t.Errorf("totalIPsScanned = %d, want 5", report.TotalIPsScanned)
+				}
+				if len(report.Unprobed) != 1 || !report.Unprobed[0].Equal(net.ParseIP("192.0.2.13")) {
+					t.Errorf("unprobed = %v, want 192.0.2.13", report.Unprobed)
+				}
+				if report.Profile != "sim" {
+					t.Errorf("profile = %q, want sim", report.Profile)
+				}
+				if len(report.Errors) != 1 || report.Errors[0].Kind != networkutils.ErrKindPermission {
+					t.Errorf("errors = %+v, want one permission error", report.Errors)
+				}
+			},
+		},
+		{
+			name:   "network as csv",
+			path:   "/network/eth0?format=csv",
+			status: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+					t.Errorf("Content-Type = %q, want text/csv", ct)
+				}
+				if !strings.Contains(w.Body.String(), "192.0.2.9") {
+					t.Errorf("csv lacks 192.0.2.9:\n%s", w.Body)
+				}
+			},
+		},
+		{
+			name:   "unknown interface",
+			path:   "/network/eth9",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "unknown format",
+			path:   "/network/eth0?format=pdf",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown namespace",
+			path:   "/network/eth0?netns=blue",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "all",
+			path:   "/all",
+			status: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				if got := w.Header().Get("X-Total-IPs-Scanned"); got != "5" {
+					t.Errorf("X-Total-IPs-Scanned = %q, want 5", got)
+				}
+				var report networkutils.ScanReport
+				decode(t, w, &report)
+				if report.ActiveHostCount() != 2 {
+					t.Errorf("%d active hosts, want 2", report.ActiveHostCount())
+				}
+			},
+		},
+		{
+			name:   "free blocks",
+			path:   "/free-blocks?interface=eth0",
+			status: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				if got := w.Header().Get("X-Unprobed-IPs"); got != "1" {
+					t.Errorf("X-Unprobed-IPs = %q, want 1", got)
+				}
+				var blocks []networkutils.FreeBlock
+				decode(t, w, &blocks)
+				var firsts []string
+				for _, b := range blocks {
+					firsts = append(firsts, b.First.String())
+				}
+				if got := strings.Join(firsts, " "); got != "192.0.2.10 192.0.2.12 192.0.2.14" {
+					t.Errorf("free blocks start at %s, want 192.0.2.10 192.0.2.12 192.0.2.14", got)
+				}
+			},
+		},
+		{
+			name:   "free blocks of unaligned size",
+			path:   "/free-blocks?size=3&aligned=true",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "host",
+			path:   "/host/192.0.2.9?ports=22,80&pings=1",
+			status: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var h networkutils.HostInspection
+				decode(t, w, &h)
+				if h.ARP == nil || h.ARP.MAC != "02:00:00:00:00:09" {
+					t.Errorf("arp = %+v, want 02:00:00:00:00:09", h.ARP)
+				}
+				if len(h.Ports) != 1 || h.Ports[0].Port != 22 {
+					t.Errorf("open ports = %+v, want 22", h.Ports)
+				}
+			},
+		},
+		{
+			name:   "host with an invalid address",
+			path:   "/host/bad",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "host with invalid ports",
+			path:   "/host/192.0.2.9?ports=ssh",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "progress",
+			path:   "/progress",
+			status: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var progress struct {
+					Running bool                    `json:"running"`
+					Scans   []networkutils.Progress `json:"scans"`
+				}
+				decode(t, w, &progress)
+				if progress.Running {
+					t.Errorf("running with no scan under way: %+v", progress.Scans)
+				}
+			},
+		},
+		{
+			name:   "metrics",
+			path:   "/metrics",
+			status: http.StatusOK,
+			check: func(t *testing.T, w *httptest.ResponseRecorder) {
+				if ct := w.Header().Get("Content-Type"); ct != metrics.ContentType {
+					t.Errorf("Content-Type = %q, want %q", ct, metrics.ContentType)
+				}
+				if !strings.Contains(w.Body.String(), "# TYPE goscan_host_up gauge") {
+					t.Errorf("metrics lack goscan_host_up:\n%s", w.Body)
+				}
+			},
+		},
+		{name: "inventory disabled", path: "/inventory", status: http.StatusServiceUnavailable},
+		{name: "diff without inventory", path: "/diff", status: http.StatusServiceUnavailable},
+		{name: "availability without inventory", path: "/availability", status: http.StatusServiceUnavailable},
+		{name: "reservations without inventory", path: "/reservations", status: http.StatusServiceUnavailable},
+		{name: "allocate without inventory", method: http.MethodPost, path: "/allocate", body: `{"owner":"ops"}`, status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestServer(t)
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			w := serve(router, method, tt.path, tt.body)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.check != nil {
+				tt.check(t, w)
+			}
+		})
+	}
+}
+
+func TestInventoryHandlers(t *testing.T) {
+	router := newTestServer(t)
+	withInventory(t)
+
+	if w := serve(router, http.MethodGet, "/all", ""); w.Code != http.StatusOK {
+		t.Fatalf("scan status = %d: %s", w.Code, w.Body)
+	}
+
+	w := serve(router, http.MethodGet, "/inventory", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("inventory status = %d: %s", w.Code, w.Body)
+	}
+	var hosts []inventory.Host
+	decode(t, w, &hosts)
+	var ips []string
+	for _, h := range hosts {
+		ips = append(ips, h.IP)
+	}
+	if got := strings.Join(ips, " "); got != "192.0.2.9 192.0.2.11" {
+		t.Errorf("inventory = %s, want 192.0.2.9 192.0.2.11", got)
+	}
+
+	w = serve(router, http.MethodPost, "/allocate", `{"interface":"eth0","owner":"ops"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("allocate status = %d: %s", w.Code, w.Body)
+	}
+	var r inventory.Reservation
+	decode(t, w, &r)
+	switch r.IP {
+	case "192.0.2.9", "192.0.2.11", "192.0.2.13":
+		t.Errorf("allocated %s, which is in use or was not probed", r.IP)
+	}
+
+	w = serve(router, http.MethodGet, "/free-blocks?interface=eth0", "")
+	var blocks []networkutils.FreeBlock
+	decode(t, w, &blocks)
+	for _, b := range blocks {
+		if b.First.String() == r.IP {
+			t.Errorf("reserved %s still listed free", r.IP)
+		}
+	}
+
+	if w := serve(router, http.MethodDelete, "/reservations/"+r.IP, ""); w.Code != http.StatusNoContent {
+		t.Errorf("release status = %d: %s", w.Code, w.Body)
+	}
+	if w := serve(router, http.MethodDelete, "/reservations/"+r.IP, ""); w.Code != http.StatusNotFound {
+		t.Errorf("second release status = %d, want 404", w.Code)
+	}
+
+	w = serve(router, http.MethodGet, "/inventory/192.0.2.9", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("inventory host status = %d: %s", w.Code, w.Body)
+	}
+	decode(t, w, &hosts)
+	if len(hosts) != 1 || hosts[0].MAC != "02:00:00:00:00:09" {
+		t.Errorf("inventory of 192.0.2.9 = %+v, want its MAC", hosts)
+	}
+	if w := serve(router, http.MethodGet, "/inventory/192.0.2.12", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unseen host status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// makeRaw is only supported on Linux.
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errors.New("the terminal interface is only supported on Linux")
+}
+
+// terminalSize is only supported on Linux.
+func terminalSize(f *os.File) (int, int, error) {
+	return 0, 0, errors.New("the terminal interface is only supported on Linux")
+}
+
+// notifyResize is only supported on Linux.
+func notifyResize(c chan<- os.Signal) {}
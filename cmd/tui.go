@@ -0,0 +1,794 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"goscan/export"
+	"goscan/leases"
+	"goscan/networkutils"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// Terminal control sequences of the full-screen interface.
+const (
+	enterAltScreen = "\033[?1049h"
+	leaveAltScreen = "\033[?1049l"
+	reverseVideo   = "\033[7m"
+	dimText        = "\033[2m"
+)
+
+// tuiColumns are the columns the host list sorts by, in the order s goes
+// through them.
+var tuiColumns = []string{"IP", "MAC", "Vendor", "Name", "RTT"}
+
+// tuiExtensions are the file extensions of the export formats.
+var tuiExtensions = map[string]string{
+	export.FormatJSON:     "json",
+	export.FormatCSV:      "csv",
+	export.FormatXML:      "xml",
+	export.FormatYAML:     "yaml",
+	export.FormatMarkdown: "md",
+	export.FormatTable:    "txt",
+}
+
+// Lines of the screen around the host list.
+const (
+	tuiHeaderLines = 4
+	tuiDetailLines = 7
+	tuiFooterLines = tuiDetailLines + 2
+)
+
+func NewTUICmd() *cobra.Command {
+	tuiCmd := &cobra.Command{
+		Use:   "tui",
+		Short: "Browse scan results in a full-screen terminal interface",
+		Long: `Scan every selected interface and browse the results: one tab per
+interface, a host list to sort and filter, and the details of the selected
+host.
+
+Keys:
+  tab, ←/→, 1-9   switch interface        ↑/↓, PgUp/PgDn, g/G   select host
+  s / S           sort by next column / reverse the order
+  /               filter hosts (enter to keep, esc to clear)
+  r               rescan the selected host and sweep its TCP ports
+  R               rescan the interface
+  e               export the interface to a file in the --output format
+  q               quit`,
+		Args: cobra.NoArgs,
+		Run:  runTUI,
+	}
+
+	tuiCmd.Flags().IntSlice("ports", networkutils.CommonPorts, "TCP ports swept when rescanning a host")
+	tuiCmd.Flags().StringP("config", "c", "", "JSON configuration file (profiles, per-interface settings, leaseFiles)")
+
+	return tuiCmd
+}
+
+// tuiTab is an interface and its latest scan.
+type tuiTab struct {
+	iface     networkutils.InterfaceDetails
+	report    *networkutils.InterfaceReport
+	scanning  bool
+	scannedAt time.Time
+}
+
+// tuiHostInfo holds what a host rescan found beyond the scan.
+type tuiHostInfo struct {
+	ports     []int
+	scanning  bool
+	checkedAt time.Time
+}
+
+// tui is the state of the interface. It is only touched by the main loop;
+// scans running in the background hand their results over through updates.
+type tui struct {
+	scanner      *networkutils.Scanner
+	leases       []networkutils.Lease
+	ports        []int
+	exportFormat string
+
+	tabs     []*tuiTab
+	tab      int
+	cursor   int
+	offset   int
+	sortCol  int
+	sortDesc bool
+	filter   string
+	editing  bool
+	status   string
+	info     map[string]*tuiHostInfo
+	width    int
+	height   int
+
+	updates chan func()
+}
+
+func runTUI(cmd *cobra.Command, args []string) {
+	ports, _ := cmd.Flags().GetIntSlice("ports")
+	format, _ := cmd.Flags().GetString("output")
+	if !cmd.Flags().Changed("output") {
+		format = export.FormatJSON
+	}
+	format = strings.ToLower(format)
+	if err := export.ValidateFormat(format); err != nil {
+		log.Fatal(err)
+	}
+	for _, port := range ports {
+		if port < 1 || port > 65535 {
+			log.Fatalf("invalid port %d", port)
+		}
+	}
+
+	cfg := loadScanConfig(cmd)
+	if _, err := networkutils.LookupProfile(cfg.Profile); err != nil {
+		log.Fatalf("Error selecting scan profile: %v", err)
+	}
+	dhcpLeases, err := leases.ReadFiles(cfg.LeaseFiles)
+	if err != nil {
+		log.Fatalf("Error reading DHCP leases: %v", err)
+	}
+	ifaces, err := networkutils.DiscoverInterfaces()
+	if err != nil {
+		log.Fatalf("Error discovering interfaces: %v", err)
+	}
+	if len(ifaces) == 0 {
+		log.Fatal("No interface to scan.")
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		log.Fatal("goscan tui needs a terminal")
+	}
+
+	restore, err := makeRaw(os.Stdin)
+	if err != nil {
+		log.Fatalf("Error setting up the terminal: %v", err)
+	}
+	defer restore()
+	os.Stdout.WriteString(enterAltScreen + hideCursor)
+	defer os.Stdout.WriteString(showCursor + leaveAltScreen)
+
+	t := &tui{
+		scanner:      networkutils.DefaultScanner,
+		leases:       dhcpLeases,
+		ports:        ports,
+		exportFormat: format,
+		info:         make(map[string]*tuiHostInfo),
+		updates:      make(chan func(), 16),
+	}
+	for _, iface := range ifaces {
+		t.tabs = append(t.tabs, &tuiTab{iface: iface})
+	}
+	for _, tab := range t.tabs {
+		t.scanInterface(tab)
+	}
+
+	keys := make(chan []byte)
+	go func() {
+		defer close(keys)
+		for {
+			buf := make([]byte, 64)
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			keys <- buf[:n]
+		}
+	}()
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+
+	for {
+		t.draw()
+		select {
+		case b, ok := <-keys:
+			if !ok || t.handleKeys(b) {
+				return
+			}
+		case update := <-t.updates:
+			update()
+		case <-resize:
+		}
+	}
+}
+
+// scanInterface scans an interface in the background.
+func (t *tui) scanInterface(tab *tuiTab) {
+	if tab.scanning {
+		return
+	}
+	tab.scanning = true
+	iface := tab.iface
+	go func() {
+		startedAt := time.Now()
+		var report networkutils.InterfaceReport
+		profile, err := t.scanner.ProfileForInterface(iface.Name)
+		if err != nil {
+			report = networkutils.InterfaceReport{Name: iface.Name, Namespace: iface.Namespace, ActiveHosts: []networkutils.HostResult{}, Errors: networkutils.SummarizeError(err)}
+		} else {
+			report = t.scanner.ScanInterface(&iface, profile)
+		}
+		if len(t.leases) > 0 {
+			report.ApplyLeases(t.leases, time.Now())
+		}
+		t.updates <- func() {
+			tab.report, tab.scanning, tab.scannedAt = &report, false, startedAt
+		}
+	}()
+}
+
+// rescanHost probes the selected host again and sweeps its ports.
+func (t *tui) rescanHost() {
+	tab := t.tabs[t.tab]
+	hosts := t.visibleHosts()
+	if t.cursor < 0 || t.cursor >= len(hosts) {
+		return
+	}
+	old := hosts[t.cursor]
+	key := tab.iface.QualifiedName() + "/" + old.IP.String()
+	info := t.hostInfo(key)
+	if info.scanning {
+		return
+	}
+	info.scanning = true
+	t.status = "Rescanning " + old.IP.String() + "..."
+
+	iface := tab.iface
+	go func() {
+		var host *networkutils.HostResult
+		var ports []int
+		profile, err := t.scanner.ProfileForInterface(iface.Name)
+		if err == nil {
+			host, err = t.scanner.ProbeHost(&iface, old.IP, profile)
+		}
+		if host != nil {
+			timeout := profile.Timeout
+			if timeout <= 0 {
+				timeout = time.Second
+			}
+			ports = t.scanner.ScanPorts(old.IP, t.ports, timeout)
+		}
+		t.updates <- func() {
+			info.scanning = false
+			switch {
+			case err != nil:
+				t.status = colorRed + fmt.Sprintf("Error rescanning %s: %v", old.IP, err) + colorReset
+			case host == nil:
+				t.status = colorYellow + old.IP.String() + " did not answer" + colorReset
+				tab.removeHost(old.IP.String())
+				delete(t.info, key)
+			default:
+				if host.MAC == "" {
+					host.MAC = old.MAC
+				}
+				if len(host.Names) == 0 {
+					host.Names = old.Names
+				}
+				host.Lease = old.Lease
+				tab.replaceHost(*host)
+				info.ports, info.checkedAt = ports, time.Now()
+				t.status = fmt.Sprintf("%s answered %s in %s, %d open ports", old.IP, host.Method, formatDuration(host.RTT), len(ports))
+			}
+		}
+	}()
+}
+
+// exportTab writes the current interface to a file in the export format.
+func (t *tui) exportTab() {
+	tab := t.tabs[t.tab]
+	if tab.report == nil {
+		t.status = colorYellow + "Nothing to export yet" + colorReset
+		return
+	}
+	report := &networkutils.ScanReport{
+		Version:         networkutils.ReportVersion,
+		StartedAt:       tab.scannedAt,
+		Elapsed:         tab.report.Elapsed,
+		TotalIPsScanned: tab.report.TotalIPsScanned,
+		Interfaces:      []networkutils.InterfaceReport{*tab.report},
+	}
+	name := strings.ReplaceAll(tab.iface.QualifiedName(), "/", "-")
+	path := fmt.Sprintf("goscan-%s-%s.%s", name, tab.scannedAt.Format("20060102-150405"), tuiExtensions[t.exportFormat])
+
+	f, err := os.Create(path)
+	if err == nil {
+		err = export.Write(f, report, t.exportFormat, export.Options{Show: export.ShowAll, Args: strings.Join(os.Args, " ")})
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		t.status = colorRed + "Error exporting: " + err.Error() + colorReset
+		return
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	t.status = colorGreen + "Exported " + tab.iface.QualifiedName() + " to " + path + colorReset
+}
+
+func (t *tui) hostInfo(key string) *tuiHostInfo {
+	info, ok := t.info[key]
+	if !ok {
+		info = &tuiHostInfo{}
+		t.info[key] = info
+	}
+	return info
+}
+
+func (tab *tuiTab) replaceHost(host networkutils.HostResult) {
+	for i := range tab.report.ActiveHosts {
+		if tab.report.ActiveHosts[i].IP.Equal(host.IP) {
+			tab.report.ActiveHosts[i] = host
+			return
+		}
+	}
+	tab.report.ActiveHosts = append(tab.report.ActiveHosts, host)
+}
+
+func (tab *tuiTab) removeHost(ip string) {
+	hosts := tab.report.ActiveHosts[:0]
+	for _, h := range tab.report.ActiveHosts {
+		if h.IP.String() != ip {
+			hosts = append(hosts, h)
+		}
+	}
+	tab.report.ActiveHosts = hosts
+}
+
+// handleKeys applies the keys read from the terminal and reports whether
+// to quit.
+func (t *tui) handleKeys(b []byte) bool {
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if c == 0x03 {
+			// Ctrl-C, as the terminal no longer turns it into a signal
+			return true
+		}
+
+		if c == 0x1b {
+			seq, n := escapeSequence(b[i:])
+			i += n - 1
+			if t.editing && seq != "" {
+				continue
+			}
+			if t.handleSequence(seq) {
+				return true
+			}
+			continue
+		}
+
+		if t.editing {
+			switch {
+			case c == '\r' || c == '\n':
+				t.editing = false
+			case c == 0x7f || c == 0x08:
+				if r := []rune(t.filter); len(r) > 0 {
+					t.filter = string(r[:len(r)-1])
+				}
+			case c >= 0x20:
+				t.filter += string(c)
+			}
+			t.cursor, t.offset = 0, 0
+			continue
+		}
+
+		t.status = ""
+		switch c {
+		case 'q', 'Q':
+			return true
+		case '\t', 'l':
+			t.switchTab(t.tab + 1)
+		case 'h':
+			t.switchTab(t.tab - 1)
+		case 'j':
+			t.cursor++
+		case 'k':
+			t.cursor--
+		case 'g':
+			t.cursor = 0
+		case 'G':
+			t.cursor = len(t.visibleHosts()) - 1
+		case 's':
+			t.sortCol = (t.sortCol + 1) % len(tuiColumns)
+		case 'S':
+			t.sortDesc = !t.sortDesc
+		case '/':
+			t.editing = true
+		case 'r':
+			t.rescanHost()
+		case 'R':
+			t.scanInterface(t.tabs[t.tab])
+		case 'e':
+			t.exportTab()
+		default:
+			if n := int(c - '1'); c >= '1' && c <= '9' && n < len(t.tabs) {
+				t.switchTab(n)
+			}
+		}
+		// Keys read together are applied before the next draw
+		t.clampCursor()
+	}
+	return false
+}
+
+// handleSequence applies a key sent as an escape sequence and reports
+// whether to quit. An empty sequence is the escape key itself.
+func (t *tui) handleSequence(seq string) bool {
+	t.status = ""
+	page := t.listHeight()
+	switch seq {
+	case "":
+		t.editing, t.filter = false, ""
+	case "[A", "OA":
+		t.cursor--
+	case "[B", "OB":
+		t.cursor++
+	case "[C", "OC":
+		t.switchTab(t.tab + 1)
+	case "[D", "OD":
+		t.switchTab(t.tab - 1)
+	case "[Z":
+		t.switchTab(t.tab - 1)
+	case "[5~":
+		t.cursor -= page
+	case "[6~":
+		t.cursor += page
+	case "[H", "OH", "[1~":
+		t.cursor = 0
+	case "[F", "OF", "[4~":
+		t.cursor = len(t.visibleHosts()) - 1
+	}
+	t.clampCursor()
+	return false
+}
+
+// clampCursor keeps the cursor on a visible host, or at 0 if there is none.
+func (t *tui) clampCursor() {
+	if n := len(t.visibleHosts()); t.cursor >= n {
+		t.cursor = n - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+// escapeSequence returns the sequence following an escape byte, without
+// it, and the number of bytes it takes.
+func escapeSequence(b []byte) (string, int) {
+	if len(b) < 2 || (b[1] != '[' && b[1] != 'O') {
+		return "", 1
+	}
+	for j := 2; j < len(b); j++ {
+		if b[j] >= 0x40 && b[j] <= 0x7e {
+			return string(b[1 : j+1]), j + 1
+		}
+	}
+	return "", len(b)
+}
+
+// switchTab selects a tab, wrapping around at both ends.
+func (t *tui) switchTab(i int) {
+	i = (i + len(t.tabs)) % len(t.tabs)
+	if i != t.tab {
+		t.tab, t.cursor, t.offset = i, 0, 0
+	}
+}
+
+// visibleHosts returns the hosts of the current interface that match the
+// filter, in the sort order.
+func (t *tui) visibleHosts() []networkutils.HostResult {
+	tab := t.tabs[t.tab]
+	if tab.report == nil {
+		return nil
+	}
+	filter := strings.ToLower(t.filter)
+	var hosts []networkutils.HostResult
+	for _, h := range tab.report.ActiveHosts {
+		if filter == "" || strings.Contains(strings.ToLower(hostSearchText(h)), filter) {
+			hosts = append(hosts, h)
+		}
+	}
+
+	key := func(h networkutils.HostResult) string {
+		switch tuiColumns[t.sortCol] {
+		case "MAC":
+			return h.MAC
+		case "Vendor":
+			return strings.ToLower(networkutils.LookupVendor(h.MAC))
+		case "Name":
+			return strings.ToLower(hostName(h))
+		}
+		return ""
+	}
+	sort.SliceStable(hosts, func(i, j int) bool {
+		a, b := hosts[i], hosts[j]
+		if t.sortDesc {
+			a, b = b, a
+		}
+		switch tuiColumns[t.sortCol] {
+		case "RTT":
+			if a.RTT != b.RTT {
+				return a.RTT < b.RTT
+			}
+		case "IP":
+		default:
+			if ka, kb := key(a), key(b); ka != kb {
+				return ka < kb
+			}
+		}
+		return bytes.Compare(a.IP.To16(), b.IP.To16()) < 0
+	})
+	return hosts
+}
+
+// hostSearchText is what the filter matches against.
+func hostSearchText(h networkutils.HostResult) string {
+	fields := []string{h.IP.String(), h.MAC, networkutils.LookupVendor(h.MAC), h.Method}
+	fields = append(fields, h.Names...)
+	if h.Lease != nil {
+		fields = append(fields, h.Lease.Hostname)
+	}
+	return strings.Join(fields, " ")
+}
+
+func (t *tui) listHeight() int {
+	if h := t.height - tuiHeaderLines - tuiFooterLines; h > 1 {
+		return h
+	}
+	return 1
+}
+
+// draw redraws the whole screen.
+func (t *tui) draw() {
+	if w, h, err := terminalSize(os.Stdout); err == nil {
+		t.width, t.height = w, h
+	}
+	if t.width < 20 || t.height < tuiHeaderLines+tuiFooterLines+1 {
+		os.Stdout.WriteString(cursorHome + clearBelow + "Terminal too small")
+		return
+	}
+
+	t.clampCursor()
+	hosts := t.visibleHosts()
+	listHeight := t.listHeight()
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+listHeight {
+		t.offset = t.cursor - listHeight + 1
+	}
+
+	tab := t.tabs[t.tab]
+	var lines []string
+	lines = append(lines, t.tabBar())
+	lines = append(lines, t.infoLine(tab))
+
+	switch {
+	case t.editing:
+		lines = append(lines, fit("Filter: "+t.filter+"_", t.width))
+	case t.filter != "":
+		lines = append(lines, fit(fmt.Sprintf("Filter: %s (%d hosts, esc to clear)", t.filter, len(hosts)), t.width))
+	default:
+		lines = append(lines, "")
+	}
+
+	nameWidth := t.width - 16 - 18 - 24 - 10 - 4
+	if nameWidth < 8 {
+		nameWidth = 8
+	}
+	header := []string{"IP", "MAC", "Vendor", "Name", "RTT"}
+	for i := range header {
+		if i == t.sortCol {
+			if t.sortDesc {
+				header[i] += " ▼"
+			} else {
+				header[i] += " ▲"
+			}
+		}
+	}
+	lines = append(lines, boldText+fit(tuiRow(header, nameWidth), t.width)+colorReset)
+
+	for i := 0; i < listHeight; i++ {
+		n := t.offset + i
+		if n >= len(hosts) {
+			switch {
+			case i == 0 && tab.report == nil:
+				lines = append(lines, colorYellow+"  Scanning..."+colorReset)
+			case i == 0:
+				lines = append(lines, colorPurple+"  No hosts found."+colorReset)
+			default:
+				lines = append(lines, "")
+			}
+			continue
+		}
+		h := hosts[n]
+		row := fit(tuiRow([]string{h.IP.String(), h.MAC, networkutils.LookupVendor(h.MAC), hostName(h), formatDuration(h.RTT)}, nameWidth), t.width)
+		if n == t.cursor {
+			row = reverseVideo + row + colorReset
+		}
+		lines = append(lines, row)
+	}
+
+	lines = append(lines, dimText+strings.Repeat("─", t.width)+colorReset)
+	lines = append(lines, t.detailLines(tab, hosts)...)
+
+	if t.status != "" {
+		lines = append(lines, fitColored(t.status, t.width))
+	} else {
+		lines = append(lines, dimText+fit("tab interface  ↑↓ select  s/S sort  / filter  r rescan host  R rescan interface  e export  q quit", t.width)+colorReset)
+	}
+
+	var out strings.Builder
+	out.WriteString(cursorHome)
+	for i, line := range lines {
+		out.WriteString(line + clearLine)
+		if i < len(lines)-1 {
+			out.WriteString("\n")
+		}
+	}
+	out.WriteString(clearBelow)
+	os.Stdout.WriteString(out.String())
+}
+
+func tuiRow(cols []string, nameWidth int) string {
+	return fmt.Sprintf(" %-16s %-18s %-24s %-*s %9s",
+		truncate(cols[0], 16), truncate(cols[1], 18), truncate(cols[2], 24), nameWidth, truncate(cols[3], nameWidth), truncate(cols[4], 9))
+}
+
+// tabBar lists the interfaces with their host counts, the current one
+// highlighted.
+func (t *tui) tabBar() string {
+	var b strings.Builder
+	width := 0
+	for i, tab := range t.tabs {
+		count := "…"
+		if tab.report != nil {
+			count = strconv.Itoa(len(tab.report.ActiveHosts))
+		}
+		label := fmt.Sprintf(" %d:%s (%s) ", i+1, tab.iface.QualifiedName(), count)
+		width += len([]rune(label)) + 1
+		if width > t.width {
+			break
+		}
+		if i == t.tab {
+			b.WriteString(reverseVideo + boldText + label + colorReset)
+		} else {
+			b.WriteString(label)
+		}
+		b.WriteString(" ")
+	}
+	return b.String()
+}
+
+func (t *tui) infoLine(tab *tuiTab) string {
+	info := fmt.Sprintf("%s [%s] %s", tab.iface.QualifiedName(), tab.iface.MACAddress, strings.Join(tab.iface.Addresses(), " "))
+	var state string
+	switch {
+	case tab.scanning:
+		state = colorYellow + "scanning..." + colorReset
+	case tab.report != nil:
+		info += fmt.Sprintf("   profile %s   %d hosts of %d   scanned %s in %s",
+			tab.report.Profile, len(tab.report.ActiveHosts), tab.report.TotalIPsScanned,
+			tab.scannedAt.Format("15:04:05"), tab.report.Elapsed.Round(time.Millisecond))
+		if n := len(tab.report.Errors); n > 0 {
+			state = colorRed + fmt.Sprintf("%d kinds of probe errors", n) + colorReset
+		}
+	}
+	if state == "" {
+		return boldText + colorCyan + fit(info, t.width) + colorReset
+	}
+	return boldText + colorCyan + truncate(info, t.width-20) + colorReset + "  " + state
+}
+
+// detailLines describes the selected host.
+func (t *tui) detailLines(tab *tuiTab, hosts []networkutils.HostResult) []string {
+	lines := make([]string, 0, tuiDetailLines)
+	if len(hosts) == 0 {
+		for len(lines) < tuiDetailLines {
+			lines = append(lines, "")
+		}
+		return lines
+	}
+	h := hosts[t.cursor]
+	field := func(label, value string) {
+		if value == "" {
+			value = "-"
+		}
+		lines = append(lines, fitColored(boldText+fmt.Sprintf("%-8s", label)+colorReset+" "+value, t.width))
+	}
+
+	field("IP", h.IP.String()+" on "+tab.iface.QualifiedName())
+	field("MAC", h.MAC)
+	field("Vendor", networkutils.LookupVendor(h.MAC))
+	field("Names", strings.Join(h.Names, ", "))
+	field("RTT", fmt.Sprintf("%s (%s)", formatDuration(h.RTT), h.Method))
+	lease := ""
+	if h.Lease != nil {
+		lease = fmt.Sprintf("%s %s", h.Lease.Hostname, h.Lease.MAC)
+		if h.Lease.Expires != nil {
+			lease += " until " + h.Lease.Expires.Local().Format("2006-01-02 15:04")
+		}
+	}
+	field("Lease", strings.TrimSpace(lease))
+
+	info := t.info[tab.iface.QualifiedName()+"/"+h.IP.String()]
+	switch {
+	case info != nil && info.scanning:
+		field("Ports", colorYellow+"scanning..."+colorReset)
+	case info != nil && !info.checkedAt.IsZero():
+		ports := make([]string, len(info.ports))
+		for i, p := range info.ports {
+			ports[i] = strconv.Itoa(p)
+		}
+		value := strings.Join(ports, ", ")
+		if value == "" {
+			value = "none"
+		}
+		field("Ports", colorGreen+value+colorReset+dimText+" (swept "+info.checkedAt.Format("15:04:05")+")"+colorReset)
+	default:
+		field("Ports", dimText+"press r to sweep"+colorReset)
+	}
+	return lines
+}
+
+// formatDuration shows a round-trip time, or "" if none was measured.
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return d.Round(10 * time.Microsecond).String()
+}
+
+// truncate shortens s to n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// fit truncates or pads plain text to n runes.
+func fit(s string, n int) string {
+	s = truncate(s, n)
+	if pad := n - len([]rune(s)); pad > 0 {
+		s += strings.Repeat(" ", pad)
+	}
+	return s
+}
+
+// fitColored truncates text holding color sequences to n visible runes.
+func fitColored(s string, n int) string {
+	var b strings.Builder
+	visible := 0
+	for i := 0; i < len(s); {
+		if s[i] == 0x1b {
+			end := strings.IndexByte(s[i:], 'm')
+			if end < 0 {
+				break
+			}
+			b.WriteString(s[i : i+end+1])
+			i += end + 1
+			continue
+		}
+		if visible == n {
+			break
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteRune(r)
+		visible++
+		i += size
+	}
+	return b.String() + colorReset
+}
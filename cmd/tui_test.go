@@ -0,0 +1,113 @@
+package main
+
+import (
+	"goscan/networkutils"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTUI returns an interface with two tabs: eth0 with three hosts and
+// an empty wlan0.
+func newTestTUI() *tui {
+	report := &networkutils.InterfaceReport{
+		Name: "eth0",
+		ActiveHosts: []networkutils.HostResult{
+			{IP: net.ParseIP("192.0.2.10"), MAC: "02:00:00:00:00:0a", RTT: 3 * time.Millisecond, Names: []string{"printer"}},
+			{IP: net.ParseIP("192.0.2.2"), MAC: "02:00:00:00:00:02", RTT: 1 * time.Millisecond, Names: []string{"nas"}},
+			{IP: net.ParseIP("192.0.2.9"), MAC: "02:00:00:00:00:09", RTT: 2 * time.Millisecond},
+		},
+	}
+	return &tui{
+		tabs: []*tuiTab{
+			{iface: networkutils.InterfaceDetails{Name: "eth0"}, report: report},
+			{iface: networkutils.InterfaceDetails{Name: "wlan0"}, report: &networkutils.InterfaceReport{Name: "wlan0"}},
+		},
+		info:   make(map[string]*tuiHostInfo),
+		height: 40,
+	}
+}
+
+func visibleIPs(t *tui) string {
+	var ips []string
+	for _, h := range t.visibleHosts() {
+		ips = append(ips, h.IP.String())
+	}
+	return strings.Join(ips, " ")
+}
+
+func TestTUIHandleKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   string
+		quit   bool
+		tab    int
+		cursor int
+		filter string
+		hosts  string
+	}{
+		{name: "quit", keys: "q", quit: true, hosts: "192.0.2.2 192.0.2.9 192.0.2.10"},
+		{name: "ctrl-c while editing", keys: "/ab\x03", quit: true, filter: "ab", hosts: ""},
+		{name: "down", keys: "jj", cursor: 2, hosts: "192.0.2.2 192.0.2.9 192.0.2.10"},
+		{name: "arrow keys", keys: "\x1b[B\x1b[B\x1b[A", cursor: 1, hosts: "192.0.2.2 192.0.2.9 192.0.2.10"},
+		{name: "last host", keys: "G", cursor: 2, hosts: "192.0.2.2 192.0.2.9 192.0.2.10"},
+		{name: "down stops at the last host", keys: "jjjjjk", cursor: 1, hosts: "192.0.2.2 192.0.2.9 192.0.2.10"},
+		{name: "up stops at the first host", keys: "kkj", cursor: 1, hosts: "192.0.2.2 192.0.2.9 192.0.2.10"},
+		{name: "page down", keys: "\x1b[6~\x1b[A", cursor: 1, hosts: "192.0.2.2 192.0.2.9 192.0.2.10"},
+		{name: "last host of an empty tab", keys: "\tG", tab: 1},
+		{name: "next tab", keys: "j\t", tab: 1},
+		{name: "previous tab wraps", keys: "h", tab: 1},
+		{name: "back tab", keys: "\x1b[Z\x1b[C", tab: 0, hosts: "192.0.2.2 192.0.2.9 192.0.2.10"},
+		{name: "tab by number", keys: "2", tab: 1},
+		{name: "filter", keys: "/NAS\r", filter: "NAS", hosts: "192.0.2.2"},
+		{name: "filter keys are not commands", keys: "/q", filter: "q", hosts: ""},
+		{name: "filter backspace", keys: "/printx\x7f\r", filter: "print", hosts: "192.0.2.10"},
+		{name: "escape clears the filter", keys: "/nas\x1b", hosts: "192.0.2.2 192.0.2.9 192.0.2.10"},
+		{name: "sort by rtt", keys: "ssss", hosts: "192.0.2.2 192.0.2.9 192.0.2.10"},
+		{name: "sort by name descending", keys: "sssS", hosts: "192.0.2.10 192.0.2.2 192.0.2.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ui := newTestTUI()
+
+			quit := ui.handleKeys([]byte(tt.keys))
+
+			if quit != tt.quit {
+				t.Errorf("quit = %v, want %v", quit, tt.quit)
+			}
+			if ui.tab != tt.tab || ui.cursor != tt.cursor {
+				t.Errorf("tab %d, cursor %d, want tab %d, cursor %d", ui.tab, ui.cursor, tt.tab, tt.cursor)
+			}
+			if ui.filter != tt.filter {
+				t.Errorf("filter = %q, want %q", ui.filter, tt.filter)
+			}
+			if got := visibleIPs(ui); got != tt.hosts {
+				t.Errorf("hosts = %s, want %s", got, tt.hosts)
+			}
+		})
+	}
+}
+
+func TestEscapeSequence(t *testing.T) {
+	tests := []struct {
+		in   string
+		seq  string
+		size int
+	}{
+		{"\x1b", "", 1},
+		{"\x1bq", "", 1},
+		{"\x1b[A", "[A", 3},
+		{"\x1bOB", "OB", 3},
+		{"\x1b[5~j", "[5~", 4},
+		{"\x1b[1", "", 3},
+	}
+
+	for _, tt := range tests {
+		seq, n := escapeSequence([]byte(tt.in))
+		if seq != tt.seq || n != tt.size {
+			t.Errorf("escapeSequence(%q) = %q, %d, want %q, %d", tt.in, seq, n, tt.seq, tt.size)
+		}
+	}
+}
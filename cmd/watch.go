@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"goscan/leases"
+	"goscan/networkutils"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// Terminal control sequences for redrawing in place.
+const (
+	cursorHome  = "\033[H"
+	clearLine   = "\033[K"
+	clearBelow  = "\033[J"
+	hideCursor  = "\033[?25l"
+	showCursor  = "\033[?25h"
+	clearScreen = "\033[2J"
+)
+
+func NewWatchCmd() *cobra.Command {
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Rescan at an interval and show hosts coming and going",
+		Long: `Rescan at an interval and redraw the hosts found in place. Hosts that
+appeared at the last scan are highlighted in green, hosts that disappeared
+in red, and the number of hosts up and down and of changes since the start
+are kept.
+
+With -q, or when the output is not a terminal, print one line per change
+instead, starting with the hosts up at the first scan:
+
+    2024-05-01T10:00:00Z	up	eth0	192.168.1.23	aa:bb:cc:dd:ee:ff`,
+		Args: cobra.NoArgs,
+		Run:  runWatch,
+	}
+
+	watchCmd.Flags().Duration("interval", 10*time.Second, "Time between the start of two scans")
+	watchCmd.Flags().Int("down-after", 1, "Consecutive scans a host must miss to be shown down")
+	watchCmd.Flags().StringP("config", "c", "", "JSON configuration file (profiles, per-interface settings, leaseFiles)")
+
+	return watchCmd
+}
+
+// watchedHost is what watch knows of a host.
+type watchedHost struct {
+	iface  string
+	host   networkutils.HostResult
+	up     bool
+	missed int
+	since  time.Time
+	// changed is the scan at which the host appeared or disappeared.
+	changed int
+}
+
+// watchState follows hosts across scans.
+type watchState struct {
+	downAfter   int
+	started     time.Time
+	scans       int
+	hosts       map[string]*watchedHost
+	appeared    int
+	disappeared int
+
+	lastScan    time.Time
+	lastElapsed time.Duration
+	errors      []string
+}
+
+// watchChange is a host that appeared or disappeared.
+type watchChange struct {
+	at   time.Time
+	up   bool
+	host *watchedHost
+}
+
+func runWatch(cmd *cobra.Command, args []string) {
+	interval, _ := cmd.Flags().GetDuration("interval")
+	downAfter, _ := cmd.Flags().GetInt("down-after")
+	scriptable, _ := cmd.Flags().GetBool("scriptable")
+	ifaceName, _ := cmd.Flags().GetString("interface")
+	inventoryPath, _ := cmd.Flags().GetString("inventory")
+
+	if interval <= 0 {
+		log.Fatalf("invalid interval %s (expected more than 0)", interval)
+	}
+	if downAfter < 1 {
+		log.Fatalf("invalid --down-after %d (expected at least 1)", downAfter)
+	}
+	cfg := loadScanConfig(cmd)
+	if _, err := networkutils.LookupProfile(cfg.Profile); err != nil {
+		log.Fatalf("Error selecting scan profile: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	live := !scriptable && isTerminal(os.Stdout)
+	if live {
+		fmt.Print(hideCursor + clearScreen)
+		defer fmt.Print(showCursor)
+	}
+
+	state := &watchState{downAfter: downAfter, started: time.Now(), hosts: make(map[string]*watchedHost)}
+	for {
+		if live {
+			state.draw(interval, true)
+		}
+		startedAt := time.Now()
+		report, err := scanForWatch(cfg.LeaseFiles)
+		if err != nil {
+			state.errors = []string{err.Error()}
+		} else {
+			changes := state.observe(report, startedAt)
+			if !live {
+				printChanges(changes)
+			}
+			if inventoryPath != "" {
+				if err := recordInventory(inventoryPath, report, ifaceName == ""); err != nil {
+					state.errors = append(state.errors, "Error updating inventory: "+err.Error())
+				}
+			}
+		}
+		if live {
+			state.draw(interval, false)
+		} else {
+			for _, e := range state.errors {
+				fmt.Fprintln(os.Stderr, e)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(startedAt.Add(interval))):
+		}
+	}
+}
+
+// scanForWatch scans the selected interfaces and checks the result against
+// the DHCP leases, read again at every scan as they change.
+func scanForWatch(leaseFiles []string) (*networkutils.ScanReport, error) {
+	report, err := networkutils.FetchAllNetworkData()
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Interfaces) == 0 {
+		return nil, fmt.Errorf("no interface to scan")
+	}
+	dhcpLeases, err := leases.ReadFiles(leaseFiles)
+	if err != nil {
+		return report, fmt.Errorf("error reading DHCP leases: %w", err)
+	}
+	if len(dhcpLeases) > 0 {
+		report.ApplyLeases(dhcpLeases, time.Now())
+	}
+	return report, nil
+}
+
+// observe updates the hosts from a scan and returns those that appeared or
+// disappeared. The hosts of the first scan are returned too, but are not
+// counted as appeared.
+func (s *watchState) observe(report *networkutils.ScanReport, at time.Time) []watchChange {
+	s.scans++
+	s.lastScan, s.lastElapsed = at, report.Elapsed
+	s.errors = nil
+
+	var changes []watchChange
+	for i := range report.Interfaces {
+		iface := &report.Interfaces[i]
+		name := iface.QualifiedName()
+		for _, e := range iface.Errors {
+			s.errors = append(s.errors, fmt.Sprintf("%s: %s (%d probes): %s", name, e.Kind, e.Count, e.Message))
+		}
+		if iface.TotalIPsScanned == 0 {
+			// Nothing was probed, so absent hosts are not known to be down
+			continue
+		}
+
+		active := make(map[string]bool, len(iface.ActiveHosts))
+		for _, host := range iface.ActiveHosts {
+			key := name + "/" + host.IP.String()
+			active[key] = true
+			h, ok := s.hosts[key]
+			if !ok {
+				h = &watchedHost{iface: name, since: at}
+				s.hosts[key] = h
+			}
+			if host.MAC == "" && h.host.MAC != "" {
+				host.MAC = h.host.MAC
+			}
+			h.host, h.missed = host, 0
+			if !h.up {
+				h.up, h.since = true, at
+				if s.scans > 1 {
+					h.changed = s.scans
+					s.appeared++
+				}
+				changes = append(changes, watchChange{at: at, up: true, host: h})
+			}
+		}
+		for key, h := range s.hosts {
+			if h.iface != name || active[key] || !h.up {
+				continue
+			}
+			if iface.ProbeFailed(h.host.IP) {
+				// The scan tells nothing of a host whose probes failed
+				continue
+			}
+			h.missed++
+			if h.missed >= s.downAfter {
+				h.up, h.since, h.changed = false, at, s.scans
+				s.disappeared++
+				changes = append(changes, watchChange{at: at, up: false, host: h})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return lessHost(changes[i].host, changes[j].host) })
+	return changes
+}
+
+// sorted returns the hosts by interface and address.
+func (s *watchState) sorted() []*watchedHost {
+	hosts := make([]*watchedHost, 0, len(s.hosts))
+	for _, h := range s.hosts {
+		hosts = append(hosts, h)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return lessHost(hosts[i], hosts[j]) })
+	return hosts
+}
+
+func lessHost(a, b *watchedHost) bool {
+	if a.iface != b.iface {
+		return a.iface < b.iface
+	}
+	return bytes.Compare(a.host.IP.To16(), b.host.IP.To16()) < 0
+}
+
+// draw redraws the view from the top of the screen, clearing what is left
+// of the previous one.
+func (s *watchState) draw(interval time.Duration, scanning bool) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, boldText+colorCyan+"goscan watch"+colorReset+" every %s", interval)
+	switch {
+	case scanning:
+		fmt.Fprintf(&buf, "   %sscanning...%s", colorYellow, colorReset)
+	case s.scans > 0:
+		fmt.Fprintf(&buf, "   scan %d at %s (%s)", s.scans, s.lastScan.Format("15:04:05"), s.lastElapsed.Round(time.Millisecond))
+	}
+	buf.WriteString("   Ctrl-C to quit\n\n")
+
+	table := tablewriter.NewWriter(&buf)
+	table.SetHeader([]string{"Interface", "IP", "MAC", "Name", "RTT", "State", "Since"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.SetColumnSeparator("   ")
+	table.SetAutoWrapText(false)
+
+	up, down := 0, 0
+	for _, h := range s.sorted() {
+		state := colorGreen + "up" + colorReset
+		if !h.up {
+			state = colorRed + "down" + colorReset
+			down++
+		} else {
+			up++
+		}
+		ip := h.host.IP.String()
+		if h.changed == s.scans && s.scans > 1 {
+			// Changed at the last scan
+			if h.up {
+				state = boldText + colorGreen + "new" + colorReset
+				ip = boldText + colorGreen + ip + colorReset
+			} else {
+				state = boldText + colorRed + "gone" + colorReset
+				ip = boldText + colorRed + ip + colorReset
+			}
+		}
+		rtt := ""
+		if h.up && h.host.RTT > 0 {
+			rtt = h.host.RTT.Round(10 * time.Microsecond).String()
+		}
+		table.Append([]string{h.iface, ip, h.host.MAC, hostName(h.host), rtt, state, h.since.Format("15:04:05")})
+	}
+	table.Render()
+
+	fmt.Fprintf(&buf, "\nUp: %s%s%d%s   Down: %s%s%d%s   Appeared: %s%d%s   Disappeared: %s%d%s   since %s\n",
+		boldText, colorGreen, up, colorReset,
+		boldText, colorRed, down, colorReset,
+		colorGreen, s.appeared, colorReset,
+		colorRed, s.disappeared, colorReset,
+		s.started.Format("15:04:05"))
+	for _, e := range s.errors {
+		fmt.Fprintf(&buf, colorRed+"%s"+colorReset+"\n", e)
+	}
+
+	var out strings.Builder
+	out.WriteString(cursorHome)
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		out.WriteString(strings.TrimSuffix(line, "\n"))
+		if strings.HasSuffix(line, "\n") {
+			out.WriteString(clearLine + "\n")
+		}
+	}
+	out.WriteString(clearBelow)
+	os.Stdout.WriteString(out.String())
+}
+
+// hostName returns the first name of a host, or its lease hostname.
+func hostName(host networkutils.HostResult) string {
+	if len(host.Names) > 0 {
+		return host.Names[0]
+	}
+	if host.Lease != nil {
+		return host.Lease.Hostname
+	}
+	return ""
+}
+
+// printChanges writes one tab-separated line per change.
+func printChanges(changes []watchChange) {
+	for _, c := range changes {
+		state := "up"
+		if !c.up {
+			state = "down"
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", c.at.UTC().Format(time.RFC3339), state, c.host.iface, c.host.host.IP, c.host.host.MAC)
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or a pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
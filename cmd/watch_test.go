@@ -0,0 +1,99 @@
+package main
+
+import (
+	"goscan/networkutils"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// watchScan is a scan of eth0 where the hosts given are up, and the probes
+// of those in unprobed failed.
+func watchScan(up []string, unprobed ...string) *networkutils.ScanReport {
+	iface := networkutils.InterfaceReport{Name: "eth0", TotalIPsScanned: 6, ActiveHosts: []networkutils.HostResult{}}
+	for _, ip := range up {
+		iface.ActiveHosts = append(iface.ActiveHosts, networkutils.HostResult{IP: net.ParseIP(ip)})
+	}
+	for _, ip := range unprobed {
+		iface.Unprobed = append(iface.Unprobed, net.ParseIP(ip))
+		iface.TotalIPsScanned--
+	}
+	if len(unprobed) > 0 {
+		iface.Errors = []networkutils.ErrorSummary{{Kind: networkutils.ErrKindPermission, Count: len(unprobed)}}
+	}
+	return &networkutils.ScanReport{Interfaces: []networkutils.InterfaceReport{iface}}
+}
+
+func TestWatchObserve(t *testing.T) {
+	both := []string{"192.0.2.9", "192.0.2.11"}
+
+	tests := []struct {
+		name  string
+		scans []*networkutils.ScanReport
+		// changes lists the changes of the last scan, as +ip or -ip
+		changes string
+		up      string
+	}{
+		{
+			name:    "first scan",
+			scans:   []*networkutils.ScanReport{watchScan(both)},
+			changes: "+192.0.2.9 +192.0.2.11",
+			up:      "192.0.2.9 192.0.2.11",
+		},
+		{
+			name:    "down after two misses",
+			scans:   []*networkutils.ScanReport{watchScan(both), watchScan([]string{"192.0.2.9"}), watchScan([]string{"192.0.2.9"})},
+			changes: "-192.0.2.11",
+			up:      "192.0.2.9",
+		},
+		{
+			name:  "answer resets the misses",
+			scans: []*networkutils.ScanReport{watchScan(both), watchScan([]string{"192.0.2.9"}), watchScan(both), watchScan([]string{"192.0.2.9"})},
+			up:    "192.0.2.9 192.0.2.11",
+		},
+		{
+			name:  "failed probes are not misses",
+			scans: []*networkutils.ScanReport{watchScan(both), watchScan(nil, "192.0.2.11"), watchScan(nil, "192.0.2.11")},
+			// 192.0.2.9 was probed and missed, even though 192.0.2.11 was not
+			changes: "-192.0.2.9",
+			up:      "192.0.2.11",
+		},
+		{
+			name:  "nothing probed",
+			scans: []*networkutils.ScanReport{watchScan(both), watchScan(nil, "192.0.2.8", "192.0.2.9", "192.0.2.10", "192.0.2.11", "192.0.2.12", "192.0.2.13"), watchScan(nil, "192.0.2.8", "192.0.2.9", "192.0.2.10", "192.0.2.11", "192.0.2.12", "192.0.2.13")},
+			up:    "192.0.2.9 192.0.2.11",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &watchState{downAfter: 2, started: time.Now(), hosts: make(map[string]*watchedHost)}
+			var changes []watchChange
+			for i, scan := range tt.scans {
+				changes = state.observe(scan, state.started.Add(time.Duration(i)*time.Second))
+			}
+
+			var got []string
+			for _, c := range changes {
+				sign := "-"
+				if c.up {
+					sign = "+"
+				}
+				got = append(got, sign+c.host.host.IP.String())
+			}
+			if strings.Join(got, " ") != tt.changes {
+				t.Errorf("changes = %v, want %s", got, tt.changes)
+			}
+			var up []string
+			for _, h := range state.sorted() {
+				if h.up {
+					up = append(up, h.host.IP.String())
+				}
+			}
+			if strings.Join(up, " ") != tt.up {
+				t.Errorf("hosts up = %v, want %s", up, tt.up)
+			}
+		})
+	}
+}
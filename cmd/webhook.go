@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"goscan/config"
+	"goscan/events"
+	"goscan/notify"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+func NewWebhookCmd() *cobra.Command {
+	webhookCmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Test webhook deliveries",
+	}
+
+	listenCmd := &cobra.Command{
+		Use:   "listen",
+		Short: "Run a local webhook receiver that prints the events it gets",
+		Args:  cobra.NoArgs,
+		Run:   runWebhookListen,
+	}
+	listenCmd.Flags().StringP("listen", "l", "127.0.0.1:9090", "Address to listen on")
+	listenCmd.Flags().String("secret", "", "Reject deliveries not signed with this secret")
+	listenCmd.Flags().Int("fail", 0, "Answer 503 to this many deliveries first, to exercise retries")
+
+	testCmd := &cobra.Command{
+		Use:   "test",
+		Short: "Send a test event to a webhook, or to those of a configuration file",
+		Args:  cobra.NoArgs,
+		Run:   runWebhookTest,
+	}
+	testCmd.Flags().String("url", "", "Webhook URL")
+	testCmd.Flags().String("secret", "", "Secret to sign the payload with")
+	testCmd.Flags().StringP("config", "c", "", "JSON configuration file whose webhooks to test")
+
+	webhookCmd.AddCommand(listenCmd, testCmd)
+	return webhookCmd
+}
+
+func runWebhookListen(cmd *cobra.Command, args []string) {
+	address, _ := cmd.Flags().GetString("listen")
+	secret, _ := cmd.Flags().GetString("secret")
+	fail, _ := cmd.Flags().GetInt("fail")
+
+	var mu sync.Mutex
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil || r.Method != http.MethodPost {
+			http.Error(w, "POST a JSON event", http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		delivery := r.Header.Get(notify.HeaderDelivery)
+		if fail > 0 {
+			fail--
+			fmt.Printf(colorYellow+"Failing delivery %s on purpose"+colorReset+"\n", delivery)
+			http.Error(w, "failing on purpose", http.StatusServiceUnavailable)
+			return
+		}
+		if secret != "" && !notify.VerifySignature(secret, body, r.Header.Get(notify.HeaderSignature)) {
+			fmt.Printf(colorRed+"Rejected delivery %s: bad signature"+colorReset+"\n", delivery)
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+
+		var e events.Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			fmt.Printf(colorRed+"Rejected delivery %s: %v"+colorReset+"\n", delivery, err)
+			http.Error(w, "invalid event", http.StatusBadRequest)
+			return
+		}
+		signed := ""
+		if secret != "" {
+			signed = colorGreen + " (signature ok)" + colorReset
+		}
+		fmt.Printf("%s %s%s%s %s%s\n", e.Time.Local().Format("15:04:05"), boldText, e.Type, colorReset, e.Message, signed)
+		fmt.Printf("  %s\n", body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	fmt.Printf("Listening for webhooks on http://%s/\n", address)
+	log.Fatal(http.ListenAndServe(address, handler))
+}
+
+func runWebhookTest(cmd *cobra.Command, args []string) {
+	url, _ := cmd.Flags().GetString("url")
+	secret, _ := cmd.Flags().GetString("secret")
+	configPath, _ := cmd.Flags().GetString("config")
+
+	var hooks []config.WebhookConfig
+	if url != "" {
+		hooks = append(hooks, config.WebhookConfig{URL: url, Secret: secret, MaxRetries: -1})
+	}
+	if configPath != "" {
+		if err := config.LoadFile(configPath); err != nil {
+			log.Fatal(err)
+		}
+		hooks = append(hooks, config.GetServerConfig().Webhooks...)
+	}
+	if len(hooks) == 0 {
+		log.Fatal("Give a webhook with --url or a configuration file with --config")
+	}
+
+	failed := false
+	for _, hook := range hooks {
+		webhook, _, err := notify.NewWebhook(hook)
+		if err != nil {
+			log.Fatal(err)
+		}
+		e := events.New(events.Test, "Test event from goscan")
+		if err := webhook.Send(e); err != nil {
+			fmt.Printf(colorRed+"%s: %v"+colorReset+"\n", webhook.Name(), err)
+			failed = true
+			continue
+		}
+		fmt.Printf(colorGreen+"%s: delivered"+colorReset+"\n", webhook.Name())
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -6,12 +6,215 @@ import (
 )
 
 type ServerConfig struct {
-	ListenAddress string
-	ListenPort    string
-	Timeout       time.Duration
-	SSLCertFile   string
-	SSLKeyFile    string
-	MaxSubnetSize int
+	ListenAddress string        `json:"listenAddress"`
+	ListenPort    string        `json:"listenPort"`
+	Timeout       time.Duration `json:"-"`
+	SSLCertFile   string        `json:"sslCert"`
+	SSLKeyFile    string        `json:"sslKey"`
+	MaxSubnetSize int           `json:"maxSubnetSize"`
+
+	// Profile is the scan profile used when an interface has none assigned.
+	Profile string `json:"profile"`
+	// InterfaceProfiles assigns a scan profile to individual interfaces.
+	InterfaceProfiles map[string]string `json:"interfaceProfiles"`
+	// Profiles holds user-defined scan profiles.
+	Profiles []ProfileConfig `json:"profiles"`
+
+	// IncludeInterfaces, if set, restricts scanning to interfaces whose
+	// name matches one of these glob patterns.
+	IncludeInterfaces []string `json:"includeInterfaces"`
+	// ExcludeInterfaces skips interfaces whose name matches a glob pattern.
+	ExcludeInterfaces []string `json:"excludeInterfaces"`
+	// InterfaceTypes, if set, restricts scanning to these interface types
+	// (physical, bridge, veth, tun, wireguard, vlan, other).
+	InterfaceTypes []string `json:"interfaceTypes"`
+
+	// Netns runs the primary scan inside this network namespace (name or
+	// path) instead of the host namespace.
+	Netns string `json:"netns"`
+	// Namespaces lists further network namespaces scanned by the server
+	// alongside the primary one.
+	Namespaces []string `json:"namespaces"`
+
+	// InventoryPath is the host inventory database. The server records
+	// every scan into it.
+	InventoryPath string `json:"inventory"`
+	// ScanInterval schedules a scan of every interface at this interval,
+	// recording host availability. Zero disables scheduled scans.
+	ScanInterval Duration `json:"scanInterval"`
+
+	// LeaseFiles are DHCP lease files ("dnsmasq:/path", "dhcpd:/path",
+	// "kea:/path", or a bare path to detect the format) used to enrich and
+	// check scan results.
+	LeaseFiles []string `json:"leaseFiles"`
+	// DHCPPools are the ranges DHCP servers hand out and DHCPReservations
+	// their static assignments, as addresses, ranges
+	// ("192.168.1.100-192.168.1.199") or CIDR blocks. Free blocks never
+	// include them.
+	DHCPPools        []string `json:"dhcpPools"`
+	DHCPReservations []string `json:"dhcpReservations"`
+
+	// Events tunes how scan results are turned into events.
+	Events EventsConfig `json:"events"`
+	// Webhooks receive events as JSON POST requests.
+	Webhooks []WebhookConfig `json:"webhooks"`
+	// Syslog sends events to a syslog server in RFC 5424 format.
+	Syslog SyslogConfig `json:"syslog"`
+	// Journald sends events to the systemd journal.
+	Journald JournaldConfig `json:"journald"`
+	// Email sends alerts and a daily summary over SMTP.
+	Email EmailConfig `json:"email"`
+	// MQTT publishes host presence and scan summaries to a broker.
+	MQTT MQTTConfig `json:"mqtt"`
+}
+
+// EventsConfig tunes event detection.
+type EventsConfig struct {
+	// DownAfter is the number of consecutive scans a host must miss before
+	// it is reported down. Zero means 1.
+	DownAfter int `json:"downAfter,omitempty"`
+	// Thresholds raise an event when an interface crosses them.
+	Thresholds []ThresholdConfig `json:"thresholds,omitempty"`
+}
+
+// ThresholdConfig sets limits on the interfaces matching a glob pattern.
+// Zero limits are not checked.
+type ThresholdConfig struct {
+	Interface      string  `json:"interface"`
+	MaxUtilization float64 `json:"maxUtilization,omitempty"`
+	MinHosts       int     `json:"minHosts,omitempty"`
+	MaxHosts       int     `json:"maxHosts,omitempty"`
+}
+
+// WebhookConfig is an HTTP endpoint receiving events.
+type WebhookConfig struct {
+	URL string `json:"url"`
+	// Secret, if set, signs each payload with HMAC-SHA256.
+	Secret string `json:"secret,omitempty"`
+	// Events restricts the events sent; empty means every host and
+	// threshold event. Interfaces restricts them to matching interfaces.
+	Events     []string `json:"events,omitempty"`
+	Interfaces []string `json:"interfaces,omitempty"`
+	Timeout    Duration `json:"timeout,omitempty"`
+	// MaxRetries is the number of further attempts after a failed
+	// delivery, with exponential backoff. Zero means 5; negative disables
+	// retries.
+	MaxRetries int `json:"maxRetries,omitempty"`
+}
+
+// SyslogConfig is a syslog destination. It is disabled when Address is
+// empty.
+type SyslogConfig struct {
+	// Network is "udp" (the default), "tcp", "unix" (datagram, falling back to
+	// stream) or "unixgram".
+	Network string `json:"network,omitempty"`
+	// Address is host[:port] (port 514 by default), or a socket path such
+	// as /dev/log for "unix".
+	Address string `json:"address,omitempty"`
+	// Facility is a syslog facility name, "daemon" by default.
+	Facility string `json:"facility,omitempty"`
+	// Tag is the APP-NAME of messages, "goscan" by default.
+	Tag string `json:"tag,omitempty"`
+	// Events and Interfaces restrict the events sent; empty means all.
+	Events     []string `json:"events,omitempty"`
+	Interfaces []string `json:"interfaces,omitempty"`
+}
+
+// JournaldConfig sends events to the systemd journal when Enabled.
+type JournaldConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Socket is journald's native socket, /run/systemd/journal/socket by
+	// default.
+	Socket string `json:"socket,omitempty"`
+	// Identifier is the SYSLOG_IDENTIFIER of entries, "goscan" by default.
+	Identifier string `json:"identifier,omitempty"`
+	// Events and Interfaces restrict the events sent; empty means all.
+	Events     []string `json:"events,omitempty"`
+	Interfaces []string `json:"interfaces,omitempty"`
+}
+
+// EmailConfig sends alert emails and a daily summary. It is disabled when
+// Host is empty.
+type EmailConfig struct {
+	Host string `json:"host,omitempty"`
+	// Port defaults to 587, or 465 with TLS "tls".
+	Port int `json:"port,omitempty"`
+	// TLS is "starttls" (the default, required), "tls" (implicit TLS) or
+	// "none" (plain text, for local mail sinks).
+	TLS                string `json:"tls,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	Username           string `json:"username,omitempty"`
+	Password           string `json:"password,omitempty"`
+
+	From string   `json:"from"`
+	To   []string `json:"to"`
+	// SubjectPrefix starts every subject, "[goscan]" by default.
+	SubjectPrefix string `json:"subjectPrefix,omitempty"`
+	// TemplateDir holds templates overriding the built-in ones
+	// (alert.txt, alert.html, summary.txt, summary.html).
+	TemplateDir string `json:"templateDir,omitempty"`
+
+	// Events selects the events that send an alert, host.down by default.
+	Events []string `json:"events,omitempty"`
+	// Watch restricts alerts to these addresses or CIDR blocks, and
+	// Interfaces to matching interfaces; empty means every host.
+	Watch      []string `json:"watch,omitempty"`
+	Interfaces []string `json:"interfaces,omitempty"`
+	// BatchWindow groups the alerts raised within it into one email, 30s
+	// by default.
+	BatchWindow Duration `json:"batchWindow,omitempty"`
+	// MaxPerHour caps the alert emails sent per hour, 10 by default.
+	// Alerts over the limit are held and sent together later.
+	MaxPerHour int `json:"maxPerHour,omitempty"`
+
+	// SummaryAt sends a daily summary at this local time ("08:00"). Empty
+	// disables the summary.
+	SummaryAt string `json:"summaryAt,omitempty"`
+}
+
+// MQTTConfig publishes presence and scan summaries to an MQTT broker. It
+// is disabled when Broker is empty.
+type MQTTConfig struct {
+	// Broker is "tcp://host:1883" or "tls://host:8883" ("mqtt://" and
+	// "mqtts://" work too).
+	Broker             string `json:"broker,omitempty"`
+	ClientID           string `json:"clientId,omitempty"`
+	Username           string `json:"username,omitempty"`
+	Password           string `json:"password,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	// TopicPrefix starts every topic, "goscan" by default.
+	TopicPrefix string `json:"topicPrefix,omitempty"`
+	// QoS is 0 (the default) or 1.
+	QoS int `json:"qos,omitempty"`
+	// Interfaces restricts publishing to matching interfaces.
+	Interfaces []string `json:"interfaces,omitempty"`
+	// Discovery publishes Home Assistant discovery messages under
+	// DiscoveryPrefix ("homeassistant" by default).
+	Discovery       bool   `json:"discovery,omitempty"`
+	DiscoveryPrefix string `json:"discoveryPrefix,omitempty"`
+}
+
+// DefaultExcludeInterfaces skips container and VM plumbing.
+var DefaultExcludeInterfaces = []string{
+	"docker*", "br-*", "veth*", "virbr*", "cni*", "flannel*", "cali*", "podman*", "lxcbr*",
+}
+
+// ProfileConfig is a user-defined scan profile. Zero fields are inherited
+// from the profile named in Extends, or from the default profile.
+type ProfileConfig struct {
+	Name        string   `json:"name"`
+	Extends     string   `json:"extends,omitempty"`
+	Timeout     Duration `json:"timeout,omitempty"`
+	Retries     int      `json:"retries,omitempty"`
+	Concurrency int      `json:"concurrency,omitempty"`
+	Methods     []string `json:"methods,omitempty"`
+	Rate        int      `json:"rate,omitempty"`
+	Jitter      Duration `json:"jitter,omitempty"`
+	Shuffle     bool     `json:"shuffle,omitempty"`
+	Ports       []int    `json:"ports,omitempty"`
+	// ResolveNames is a pointer so that a profile can turn off name
+	// resolution inherited from its parent.
+	ResolveNames *bool `json:"resolveNames,omitempty"`
 }
 
 var (
@@ -25,6 +228,9 @@ func init() {
 		ListenPort:    "8080",
 		Timeout:       50 * time.Millisecond,
 		MaxSubnetSize: 1024,
+		Profile:       "default",
+
+		ExcludeInterfaces: DefaultExcludeInterfaces,
 	}
 }
 
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Duration is a time.Duration written as a string ("500ms", "2s") in
+// configuration files.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"500ms\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (c *ServerConfig) UnmarshalJSON(data []byte) error {
+	type plain ServerConfig
+	aux := struct {
+		*plain
+		Timeout Duration `json:"timeout"`
+	}{
+		plain:   (*plain)(c),
+		Timeout: Duration(c.Timeout),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	c.Timeout = time.Duration(aux.Timeout)
+	return nil
+}
+
+// LoadFile reads a JSON configuration file on top of the current server
+// configuration. Keys absent from the file keep their current value.
+func LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg := GetServerConfig()
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	SetServerConfig(cfg)
+	return nil
+}
@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Events derived from scan results (hosts appearing, going down, changing
+   MAC address, thresholds being crossed) and their delivery to sinks such
+   as webhooks.
+
+   Each sink gets its own queue and goroutine, so a slow or unreachable
+   receiver never holds up scanning or the other sinks.
+*/
+
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Type identifies what happened.
+type Type string
+
+// Event types.
+const (
+	// HostNew is a host never seen before by the inventory.
+	HostNew Type = "host.new"
+	// HostUp is a known host answering again after being down.
+	HostUp Type = "host.up"
+	// HostDown is a host that stopped answering.
+	HostDown Type = "host.down"
+	// HostMACChanged is an address answering from a different MAC address.
+	HostMACChanged Type = "host.mac_changed"
+	// ThresholdBreached is an interface crossing a configured threshold.
+	ThresholdBreached Type = "threshold.breached"
+	// ThresholdCleared is an interface back within a threshold.
+	ThresholdCleared Type = "threshold.cleared"
+	// ScanStarted and ScanFinished frame a scan.
+	ScanStarted  Type = "scan.started"
+	ScanFinished Type = "scan.finished"
+	// ScanError is a scan, or part of one, that could not be carried out.
+	ScanError Type = "scan.error"
+	// Test is sent on demand to check that a sink works.
+	Test Type = "test"
+)
+
+// Types lists the event types that can be subscribed to.
+var Types = []Type{
+	HostNew, HostUp, HostDown, HostMACChanged, ThresholdBreached, ThresholdCleared,
+	ScanStarted, ScanFinished, ScanError, Test,
+}
+
+// NotificationTypes are the events worth telling someone about: changes to
+// hosts and thresholds, leaving out the routine of scanning. Sinks that
+// notify people use them when no types are configured.
+var NotificationTypes = []Type{HostNew, HostUp, HostDown, HostMACChanged, ThresholdBreached, ThresholdCleared, Test}
+
+// Event is something that happened on a scanned network.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      Type      `json:"type"`
+	Time      time.Time `json:"time"`
+	Interface string    `json:"interface,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	MAC       string    `json:"mac,omitempty"`
+	OldMAC    string    `json:"oldMac,omitempty"`
+	Names     []string  `json:"names,omitempty"`
+	// Threshold names the threshold crossed ("maxUtilization", "minHosts",
+	// "maxHosts"), with the measured value and the configured limit.
+	Threshold string  `json:"threshold,omitempty"`
+	Value     float64 `json:"value,omitempty"`
+	Limit     float64 `json:"limit,omitempty"`
+	// Hosts and Scanned count the hosts up and addresses probed by a
+	// finished scan.
+	Hosts   int `json:"hosts,omitempty"`
+	Scanned int `json:"scanned,omitempty"`
+	// Error is the error kind of a scan error.
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message"`
+}
+
+// Severity levels, as in syslog.
+const (
+	SeverityError   = 3
+	SeverityWarning = 4
+	SeverityNotice  = 5
+	SeverityInfo    = 6
+)
+
+// Severity rates how much attention an event deserves, on the syslog
+// scale.
+func (e Event) Severity() int {
+	switch e.Type {
+	case ScanError:
+		return SeverityError
+	case HostDown, HostMACChanged, ThresholdBreached:
+		return SeverityWarning
+	case HostNew, HostUp, ThresholdCleared:
+		return SeverityNotice
+	}
+	return SeverityInfo
+}
+
+// New returns an event of the given type with a fresh ID, stamped now.
+func New(t Type, message string) Event {
+	return Event{ID: newID(), Type: t, Time: time.Now().UTC(), Message: message}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Filter selects events by type and interface. Empty lists match anything.
+type Filter struct {
+	Types []Type
+	// Interfaces are glob patterns matched against the qualified interface
+	// name. Events not tied to an interface always match.
+	Interfaces []string
+}
+
+// Match reports whether the filter selects e.
+func (f Filter) Match(e Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	if len(f.Interfaces) == 0 || e.Interface == "" {
+		return true
+	}
+	for _, pattern := range f.Interfaces {
+		if ok, _ := filepath.Match(pattern, e.Interface); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Sink delivers events somewhere. Send may block, for instance to retry.
+type Sink interface {
+	Name() string
+	Send(e Event) error
+}
+
+// queueSize bounds the events waiting for a sink. Further events are
+// dropped until it catches up.
+const queueSize = 256
+
+// Bus fans events out to the sinks subscribed to them. Its zero value has no
+// subscribers and discards events.
+type Bus struct {
+	subscriptions []*subscription
+}
+
+type subscription struct {
+	sink   Sink
+	filter Filter
+	queue  chan Event
+}
+
+// Subscribe starts delivering the events selected by filter to sink.
+func (b *Bus) Subscribe(sink Sink, filter Filter) {
+	sub := &subscription{sink: sink, filter: filter, queue: make(chan Event, queueSize)}
+	b.subscriptions = append(b.subscriptions, sub)
+	go func() {
+		for e := range sub.queue {
+			if err := sub.sink.Send(e); err != nil {
+				log.Printf("Failed to deliver %s event to %s: %v", e.Type, sub.sink.Name(), err)
+			}
+		}
+	}()
+}
+
+// Publish queues events for their subscribers without waiting for delivery.
+func (b *Bus) Publish(events ...Event) {
+	for _, e := range events {
+		for _, sub := range b.subscriptions {
+			if !sub.filter.Match(e) {
+				continue
+			}
+			select {
+			case sub.queue <- e:
+			default:
+				log.Printf("Dropped %s event for %s: queue full", e.Type, sub.sink.Name())
+			}
+		}
+	}
+}
+
+// ValidateTypes checks that every name is a known event type.
+func ValidateTypes(names []string) ([]Type, error) {
+	types := make([]Type, 0, len(names))
+	for _, name := range names {
+		t := Type(name)
+		if !slices.Contains(Types, t) {
+			known := make([]string, len(Types))
+			for i, k := range Types {
+				known[i] = string(k)
+			}
+			return nil, fmt.Errorf("unknown event type %q (expected one of %s)", name, strings.Join(known, ", "))
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Tracker compares each scan of an interface with the previous ones to
+   find hosts going up or down, changing MAC address, and thresholds being
+   crossed. It also reports how each scan went.
+*/
+
+package events
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"goscan/config"
+	"goscan/inventory"
+	"goscan/networkutils"
+)
+
+// Tracker turns successive scan reports into events. The first scan of an
+// interface only sets the initial state.
+type Tracker struct {
+	cfg config.EventsConfig
+
+	mu         sync.Mutex
+	interfaces map[string]map[string]*hostState
+	// breached holds the thresholds currently crossed, by interface and
+	// threshold name.
+	breached map[string]bool
+}
+
+type hostState struct {
+	mac    string
+	names  []string
+	up     bool
+	missed int
+}
+
+// NewTracker returns a tracker using the given settings.
+func NewTracker(cfg config.EventsConfig) *Tracker {
+	if cfg.DownAfter <= 0 {
+		cfg.DownAfter = 1
+	}
+	return &Tracker{
+		cfg:        cfg,
+		interfaces: make(map[string]map[string]*hostState),
+		breached:   make(map[string]bool),
+	}
+}
+
+// Observe returns the events of a scan, ending with ScanFinished. newHosts
+// are the inventory entries the scan created, reported as new devices.
+func (t *Tracker) Observe(report *networkutils.ScanReport, newHosts []inventory.Host) []Event {
+	var result []Event
+	for i := range report.Interfaces {
+		iface := &report.Interfaces[i]
+		for _, summary := range iface.Errors {
+			e := New(ScanError, fmt.Sprintf("%s: %d probes failed (%s): %s", iface.QualifiedName(), summary.Count, summary.Kind, summary.Message))
+			e.Interface, e.Error = iface.QualifiedName(), string(summary.Kind)
+			result = append(result, e)
+		}
+	}
+	for _, h := range newHosts {
+		e := New(HostNew, fmt.Sprintf("New device %s", describe(h.IP, h.MAC, h.Names)))
+		e.Interface, e.IP, e.MAC, e.Names = h.Interface, h.IP, h.MAC, h.Names
+		result = append(result, e)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range report.Interfaces {
+		iface := &report.Interfaces[i]
+		if iface.TotalIPsScanned == 0 {
+			// A scan that could not run says nothing about the hosts
+			continue
+		}
+		result = append(result, t.observeHosts(iface)...)
+		if len(iface.Errors) == 0 {
+			// Counts of a partial scan would cross thresholds falsely
+			result = append(result, t.checkThresholds(iface)...)
+		}
+	}
+
+	scope := fmt.Sprintf("%d interfaces", len(report.Interfaces))
+	if len(report.Interfaces) == 1 {
+		scope = report.Interfaces[0].QualifiedName()
+	}
+	finished := New(ScanFinished, fmt.Sprintf("Scanned %d addresses on %s in %s: %d hosts up",
+		report.TotalIPsScanned, scope, report.Elapsed.Round(time.Millisecond), report.ActiveHostCount()))
+	if len(report.Interfaces) == 1 {
+		finished.Interface = scope
+	}
+	finished.Hosts, finished.Scanned = report.ActiveHostCount(), report.TotalIPsScanned
+	return append(result, finished)
+}
+
+func (t *Tracker) observeHosts(iface *networkutils.InterfaceReport) []Event {
+	name := iface.QualifiedName()
+	hosts, seen := t.interfaces[name]
+	if !seen {
+		hosts = make(map[string]*hostState)
+		t.interfaces[name] = hosts
+	}
+
+	var result []Event
+	active := make(map[string]bool, len(iface.ActiveHosts))
+	for _, host := range iface.ActiveHosts {
+		ip := host.IP.String()
+		active[ip] = true
+		state, known := hosts[ip]
+		if !known {
+			hosts[ip] = &hostState{mac: host.MAC, names: host.Names, up: true}
+			continue
+		}
+		if !state.up {
+			e := New(HostUp, fmt.Sprintf("%s is up", describe(ip, host.MAC, host.Names)))
+			e.Interface, e.IP, e.MAC, e.Names = name, ip, host.MAC, host.Names
+			result = append(result, e)
+		}
+		if host.MAC != "" && state.mac != "" && host.MAC != state.mac {
+			e := New(HostMACChanged, fmt.Sprintf("%s changed MAC address from %s to %s", ip, state.mac, host.MAC))
+			e.Interface, e.IP, e.MAC, e.OldMAC, e.Names = name, ip, host.MAC, state.mac, host.Names
+			result = append(result, e)
+		}
+		state.up = true
+		state.missed = 0
+		if host.MAC != "" {
+			state.mac = host.MAC
+		}
+		if len(host.Names) > 0 {
+			state.names = host.Names
+		}
+	}
+
+	for ip, state := range hosts {
+		if active[ip] || !state.up {
+			continue
+		}
+		if iface.ProbeFailed(net.ParseIP(ip)) {
+			// Its probes failed, so it was not seen missing either
+			continue
+		}
+		state.missed++
+		if state.missed >= t.cfg.DownAfter {
+			state.up = false
+			e := New(HostDown, fmt.Sprintf("%s is down", describe(ip, state.mac, state.names)))
+			e.Interface, e.IP, e.MAC, e.Names = name, ip, state.mac, state.names
+			result = append(result, e)
+		}
+	}
+	sortEvents(result)
+	return result
+}
+
+// checkThresholds raises an event when a threshold is first crossed and
+// when the interface is back within it.
+func (t *Tracker) checkThresholds(iface *networkutils.InterfaceReport) []Event {
+	name := iface.QualifiedName()
+	alive := len(iface.ActiveHosts)
+	utilization := float64(alive) / float64(iface.TotalIPsScanned)
+
+	var result []Event
+	check := func(threshold string, crossed bool, value, limit float64) {
+		key := name + "|" + threshold
+		if crossed == t.breached[key] {
+			return
+		}
+		t.breached[key] = crossed
+
+		e := New(ThresholdBreached, fmt.Sprintf("%s %s: %g (limit %g)", name, threshold, value, limit))
+		if !crossed {
+			e = New(ThresholdCleared, fmt.Sprintf("%s back within %s: %g (limit %g)", name, threshold, value, limit))
+		}
+		e.Interface, e.Threshold, e.Value, e.Limit = name, threshold, value, limit
+		result = append(result, e)
+	}
+
+	for _, th := range t.cfg.Thresholds {
+		if th.Interface != "" {
+			if ok, _ := filepath.Match(th.Interface, name); !ok {
+				continue
+			}
+		}
+		if th.MaxUtilization > 0 {
+			check("maxUtilization", utilization > th.MaxUtilization, utilization, th.MaxUtilization)
+		}
+		if th.MinHosts > 0 {
+			check("minHosts", alive < th.MinHosts, float64(alive), float64(th.MinHosts))
+		}
+		if th.MaxHosts > 0 {
+			check("maxHosts", alive > th.MaxHosts, float64(alive), float64(th.MaxHosts))
+		}
+	}
+	return result
+}
+
+// describe names a host in event messages: "192.168.1.20 (printer,
+// aa:bb:cc:dd:ee:ff)".
+func describe(ip, mac string, names []string) string {
+	var details []string
+	if len(names) > 0 {
+		details = append(details, names[0])
+	}
+	if mac != "" {
+		details = append(details, mac)
+	}
+	if len(details) == 0 {
+		return ip
+	}
+	return fmt.Sprintf("%s (%s)", ip, strings.Join(details, ", "))
+}
+
+// sortEvents orders the events of an interface by address, as map
+// iteration does not.
+func sortEvents(list []Event) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(list[i].IP).To16(), net.ParseIP(list[j].IP).To16()) < 0
+	})
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Scan report export formats shared by the CLI (--output) and the server
+   (?format=).
+*/
+
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"goscan/networkutils"
+)
+
+// Output formats.
+const (
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatXML      = "xml"
+	FormatYAML     = "yaml"
+	FormatMarkdown = "markdown"
+	FormatTable    = "table"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatJSON, FormatCSV, FormatXML, FormatYAML, FormatMarkdown, FormatTable}
+
+// Show modes select which addresses the row-based formats (csv, markdown,
+// table) list. The document formats always carry the full report.
+const (
+	ShowAll       = "all"
+	ShowAlive     = "alive"
+	ShowAvailable = "available"
+)
+
+// Host states in row-based formats.
+const (
+	StateUp   = "up"
+	StateDown = "down"
+)
+
+// Options tune an export.
+type Options struct {
+	// Show is one of ShowAll, ShowAlive or ShowAvailable. Empty means
+	// ShowAlive.
+	Show string
+	// Args is the command line recorded in the XML output.
+	Args string
+}
+
+// ValidateFormat checks that format is supported.
+func ValidateFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q (expected one of %s)", format, strings.Join(Formats, ", "))
+}
+
+// ContentType returns the MIME type of a format.
+func ContentType(format string) string {
+	switch format {
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXML:
+		return "application/xml; charset=utf-8"
+	case FormatYAML:
+		return "application/yaml; charset=utf-8"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// Write encodes a scan report in the given format.
+func Write(w io.Writer, report *networkutils.ScanReport, format string, opts Options) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case FormatCSV:
+		return writeCSV(w, report, opts)
+	case FormatXML:
+		return writeXML(w, report, opts)
+	case FormatYAML:
+		return writeYAML(w, report)
+	case FormatMarkdown:
+		return writeMarkdown(w, report, opts)
+	case FormatTable:
+		return writeTable(w, report, opts)
+	}
+	return ValidateFormat(format)
+}
+
+// row is one address in the row-based formats.
+type row struct {
+	Interface string
+	IP        net.IP
+	State     string
+	MAC       string
+	Method    string
+	RTT       time.Duration
+	Names     []string
+	Lease     *networkutils.Lease
+}
+
+// rows lists the addresses of an interface selected by the show mode, in
+// address order.
+func rows(iface *networkutils.InterfaceReport, show string) []row {
+	var result []row
+	if show != ShowAvailable {
+		for _, host := range iface.ActiveHosts {
+			result = append(result, row{
+				Interface: iface.QualifiedName(),
+				IP:        host.IP,
+				State:     StateUp,
+				MAC:       host.MAC,
+				Method:    host.Method,
+				RTT:       host.RTT,
+				Names:     host.Names,
+				Lease:     host.Lease,
+			})
+		}
+	}
+	if show == ShowAll || show == ShowAvailable {
+		for _, ip := range iface.InactiveHosts() {
+			result = append(result, row{Interface: iface.QualifiedName(), IP: ip, State: StateDown})
+		}
+	}
+	if show == ShowAll {
+		sortRows(result)
+	}
+	return result
+}
+
+func showMode(opts Options) string {
+	if opts.Show == "" {
+		return ShowAlive
+	}
+	return opts.Show
+}
+
+// formatRTT renders a round-trip time in milliseconds, empty if unknown.
+func formatRTT(rtt time.Duration) string {
+	if rtt == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.3f", float64(rtt)/float64(time.Millisecond))
+}
+
+func leaseHostname(lease *networkutils.Lease) string {
+	if lease == nil {
+		return ""
+	}
+	return lease.Hostname
+}
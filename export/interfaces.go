@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Interface listings in the scan report formats: one row per interface in
+   CSV, Markdown and plain tables, the full listing in JSON, YAML and XML.
+*/
+
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+
+	"goscan/networkutils"
+)
+
+var interfaceHeader = []string{"interface", "type", "state", "mac", "ipv4", "ipv6", "subnet_size", "gateway", "scanned", "reason"}
+
+// InterfaceFields returns the columns of an interface in the row-based
+// formats, in the order of the header.
+func InterfaceFields(l *networkutils.InterfaceListing) []string {
+	var ipv4, ipv6 []string
+	size := 0
+	for _, a := range l.Addresses {
+		if a.Family == "IPv4" {
+			ipv4 = append(ipv4, a.Address)
+			size += a.Size
+		} else {
+			ipv6 = append(ipv6, a.Address)
+		}
+	}
+	sizeField := ""
+	if len(ipv4) > 0 {
+		sizeField = strconv.Itoa(size)
+	}
+	scanned := "yes"
+	if l.Excluded {
+		scanned = "no"
+	}
+	return []string{
+		l.QualifiedName(),
+		l.Type,
+		l.State,
+		l.MAC,
+		strings.Join(ipv4, " "),
+		strings.Join(ipv6, " "),
+		sizeField,
+		strings.Join(l.Gateways, " "),
+		scanned,
+		l.Reason,
+	}
+}
+
+// WriteInterfaces encodes an interface listing in the given format.
+func WriteInterfaces(w io.Writer, ifaces []networkutils.InterfaceListing, format string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ifaces)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(interfaceHeader); err != nil {
+			return err
+		}
+		for i := range ifaces {
+			if err := cw.Write(InterfaceFields(&ifaces[i])); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatXML:
+		return writeInterfacesXML(w, ifaces)
+	case FormatYAML:
+		return writeYAML(w, ifaces)
+	case FormatMarkdown:
+		fmt.Fprintf(w, "# goscan interfaces\n\n")
+		fmt.Fprintf(w, "| Interface | Type | State | MAC | IPv4 | IPv6 | Subnet size | Gateway | Scanned | Reason |\n")
+		fmt.Fprintf(w, "|-----------|------|-------|-----|------|------|-------------|---------|---------|--------|\n")
+		for i := range ifaces {
+			f := InterfaceFields(&ifaces[i])
+			for j := range f {
+				f[j] = markdownEscape.Replace(f[j])
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(f, " | "))
+		}
+		return nil
+	case FormatTable:
+		table := tablewriter.NewWriter(w)
+		header := make([]string, len(interfaceHeader))
+		for i, h := range interfaceHeader {
+			header[i] = strings.ReplaceAll(h, "_", " ")
+		}
+		table.SetHeader(header)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetBorder(false)
+		table.SetColumnSeparator("   ")
+		table.SetAutoWrapText(false)
+		for i := range ifaces {
+			table.Append(InterfaceFields(&ifaces[i]))
+		}
+		table.Render()
+		return nil
+	}
+	return ValidateFormat(format)
+}
+
+// xmlInterfaces is the XML form of an interface listing.
+type xmlInterfaces struct {
+	XMLName    xml.Name       `xml:"interfaces"`
+	Interfaces []xmlInterface `xml:"interface"`
+}
+
+type xmlInterface struct {
+	Name      string       `xml:"name,attr"`
+	Namespace string       `xml:"namespace,attr,omitempty"`
+	Type      string       `xml:"type,attr"`
+	State     string       `xml:"state,attr"`
+	MAC       string       `xml:"mac,attr,omitempty"`
+	Excluded  bool         `xml:"excluded,attr"`
+	Reason    string       `xml:"reason,attr,omitempty"`
+	Addresses []xmlAddress `xml:"address"`
+	Gateways  []string     `xml:"gateway"`
+}
+
+type xmlAddress struct {
+	Addr    string `xml:"addr,attr"`
+	Family  string `xml:"family,attr"`
+	Network string `xml:"network,attr"`
+	Prefix  int    `xml:"prefix,attr"`
+	Size    int    `xml:"size,attr,omitempty"`
+}
+
+func writeInterfacesXML(w io.Writer, ifaces []networkutils.InterfaceListing) error {
+	doc := xmlInterfaces{Interfaces: make([]xmlInterface, len(ifaces))}
+	for i, l := range ifaces {
+		x := xmlInterface{
+			Name:      l.Name,
+			Namespace: l.Namespace,
+			Type:      l.Type,
+			State:     l.State,
+			MAC:       l.MAC,
+			Excluded:  l.Excluded,
+			Reason:    l.Reason,
+			Gateways:  l.Gateways,
+		}
+		for _, a := range l.Addresses {
+			x.Addresses = append(x.Addresses, xmlAddress{Addr: a.Address, Family: a.Family, Network: a.Network, Prefix: a.Prefix, Size: a.Size})
+		}
+		doc.Interfaces[i] = x
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
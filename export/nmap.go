@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   XML output following nmap's nmaprun format (nmap.dtd, output version
+   1.05), so that tools reading "nmap -sn -oX" results can read goscan's.
+   Only the elements a host discovery scan produces are used.
+*/
+
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"goscan/networkutils"
+)
+
+// NmapRun is the root element of an nmap XML report.
+type NmapRun struct {
+	XMLName          xml.Name   `xml:"nmaprun"`
+	Scanner          string     `xml:"scanner,attr"`
+	Args             string     `xml:"args,attr,omitempty"`
+	Start            int64      `xml:"start,attr,omitempty"`
+	StartStr         string     `xml:"startstr,attr,omitempty"`
+	Version          string     `xml:"version,attr"`
+	XMLOutputVersion string     `xml:"xmloutputversion,attr"`
+	ScanInfo         *NmapInfo  `xml:"scaninfo,omitempty"`
+	Verbose          NmapLevel  `xml:"verbose"`
+	Debugging        NmapLevel  `xml:"debugging"`
+	Hosts            []NmapHost `xml:"host"`
+	RunStats         NmapStats  `xml:"runstats"`
+}
+
+// NmapInfo describes the scan type.
+type NmapInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+// NmapLevel is the verbose or debugging level.
+type NmapLevel struct {
+	Level int `xml:"level,attr"`
+}
+
+// NmapHost is a scanned host.
+type NmapHost struct {
+	StartTime int64          `xml:"starttime,attr,omitempty"`
+	EndTime   int64          `xml:"endtime,attr,omitempty"`
+	Status    NmapStatus     `xml:"status"`
+	Addresses []NmapAddress  `xml:"address"`
+	Hostnames *NmapHostnames `xml:"hostnames"`
+	Times     *NmapTimes     `xml:"times,omitempty"`
+}
+
+// NmapStatus is the state of a host and why it was considered so.
+type NmapStatus struct {
+	State     string `xml:"state,attr"`
+	Reason    string `xml:"reason,attr"`
+	ReasonTTL int    `xml:"reason_ttl,attr"`
+}
+
+// NmapAddress is an IP or MAC address of a host.
+type NmapAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+	Vendor   string `xml:"vendor,attr,omitempty"`
+}
+
+// NmapHostnames lists the names of a host.
+type NmapHostnames struct {
+	Hostnames []NmapHostname `xml:"hostname"`
+}
+
+// NmapHostname is a host name, from reverse DNS ("PTR") or elsewhere
+// ("user").
+type NmapHostname struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// NmapTimes holds round-trip timing in microseconds.
+type NmapTimes struct {
+	SRTT   string `xml:"srtt,attr"`
+	RTTVar string `xml:"rttvar,attr"`
+	To     string `xml:"to,attr"`
+}
+
+// NmapStats summarizes the run.
+type NmapStats struct {
+	Finished NmapFinished `xml:"finished"`
+	Hosts    NmapCounts   `xml:"hosts"`
+}
+
+// NmapFinished records when and how the run ended.
+type NmapFinished struct {
+	Time    int64  `xml:"time,attr"`
+	TimeStr string `xml:"timestr,attr"`
+	Elapsed string `xml:"elapsed,attr"`
+	Summary string `xml:"summary,attr"`
+	Exit    string `xml:"exit,attr"`
+}
+
+// NmapCounts counts hosts by state.
+type NmapCounts struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
+}
+
+// nmapReasons maps probe methods to nmap's status reasons.
+var nmapReasons = map[string]string{
+	networkutils.MethodARP:  "arp-response",
+	networkutils.MethodICMP: "echo-reply",
+	networkutils.MethodTCP:  "syn-ack",
+}
+
+// nmapTimeFormat is the format of nmap's startstr and timestr attributes.
+const nmapTimeFormat = "Mon Jan _2 15:04:05 2006"
+
+// NmapReport converts a scan report to nmap's XML structure. Like nmap,
+// hosts that did not answer are left out, unless only the available
+// addresses are asked for.
+func NmapReport(report *networkutils.ScanReport, opts Options) *NmapRun {
+	start := report.StartedAt
+	end := start.Add(report.Elapsed)
+
+	run := &NmapRun{
+		Scanner:          "goscan",
+		Args:             opts.Args,
+		Start:            start.Unix(),
+		StartStr:         start.Format(nmapTimeFormat),
+		Version:          "1.0",
+		XMLOutputVersion: "1.05",
+		ScanInfo:         &NmapInfo{Type: "ping", Protocol: "ip"},
+	}
+
+	for i := range report.Interfaces {
+		iface := &report.Interfaces[i]
+		if opts.Show != ShowAvailable {
+			for _, host := range iface.ActiveHosts {
+				run.Hosts = append(run.Hosts, nmapHost(host, start, end))
+			}
+		}
+		if opts.Show == ShowAvailable {
+			for _, ip := range iface.InactiveHosts() {
+				run.Hosts = append(run.Hosts, NmapHost{
+					StartTime: start.Unix(),
+					EndTime:   end.Unix(),
+					Status:    NmapStatus{State: "down", Reason: "no-response"},
+					Addresses: []NmapAddress{ipAddress(ip.String())},
+					Hostnames: &NmapHostnames{},
+				})
+			}
+		}
+	}
+
+	up := report.ActiveHostCount()
+	run.RunStats = NmapStats{
+		Finished: NmapFinished{
+			Time:    end.Unix(),
+			TimeStr: end.Format(nmapTimeFormat),
+			Elapsed: fmt.Sprintf("%.2f", report.Elapsed.Seconds()),
+			Summary: fmt.Sprintf("goscan done at %s; %d IP addresses (%d hosts up) scanned in %.2f seconds",
+				end.Format(nmapTimeFormat), report.TotalIPsScanned, up, report.Elapsed.Seconds()),
+			Exit: "success",
+		},
+		Hosts: NmapCounts{Up: up, Down: report.TotalIPsScanned - up, Total: report.TotalIPsScanned},
+	}
+	return run
+}
+
+func nmapHost(host networkutils.HostResult, start, end time.Time) NmapHost {
+	h := NmapHost{
+		StartTime: start.Unix(),
+		EndTime:   end.Unix(),
+		Status:    NmapStatus{State: "up", Reason: nmapReasons[host.Method]},
+		Addresses: []NmapAddress{ipAddress(host.IP.String())},
+		Hostnames: &NmapHostnames{},
+	}
+	if host.MAC != "" {
+		h.Addresses = append(h.Addresses, NmapAddress{Addr: strings.ToUpper(host.MAC), AddrType: "mac"})
+	}
+	for _, name := range host.Names {
+		h.Hostnames.Hostnames = append(h.Hostnames.Hostnames, NmapHostname{Name: name, Type: "PTR"})
+	}
+	if host.Lease != nil && host.Lease.Hostname != "" {
+		h.Hostnames.Hostnames = append(h.Hostnames.Hostnames, NmapHostname{Name: host.Lease.Hostname, Type: "user"})
+	}
+	if host.RTT > 0 {
+		us := fmt.Sprintf("%d", host.RTT.Microseconds())
+		h.Times = &NmapTimes{SRTT: us, RTTVar: us, To: fmt.Sprintf("%d", 4*host.RTT.Microseconds()+100000)}
+	}
+	return h
+}
+
+func ipAddress(ip string) NmapAddress {
+	if strings.Contains(ip, ":") {
+		return NmapAddress{Addr: ip, AddrType: "ipv6"}
+	}
+	return NmapAddress{Addr: ip, AddrType: "ipv4"}
+}
+
+func writeXML(w io.Writer, report *networkutils.ScanReport, opts Options) error {
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(NmapReport(report, opts)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
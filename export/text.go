@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Row-based formats: CSV, Markdown and plain text tables, one address per
+   row.
+*/
+
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+
+	"goscan/networkutils"
+)
+
+var rowHeader = []string{"interface", "ip", "state", "mac", "method", "rtt_ms", "names", "lease_hostname"}
+
+func (r row) fields() []string {
+	return []string{
+		r.Interface,
+		r.IP.String(),
+		r.State,
+		r.MAC,
+		r.Method,
+		formatRTT(r.RTT),
+		strings.Join(r.Names, " "),
+		leaseHostname(r.Lease),
+	}
+}
+
+func sortRows(rows []row) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return bytes.Compare(rows[i].IP.To16(), rows[j].IP.To16()) < 0
+	})
+}
+
+func writeCSV(w io.Writer, report *networkutils.ScanReport, opts Options) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(rowHeader); err != nil {
+		return err
+	}
+	for i := range report.Interfaces {
+		for _, r := range rows(&report.Interfaces[i], showMode(opts)) {
+			if err := cw.Write(r.fields()); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// markdownEscape keeps cell contents from breaking the table.
+var markdownEscape = strings.NewReplacer("|", "\\|", "\n", " ")
+
+func writeMarkdown(w io.Writer, report *networkutils.ScanReport, opts Options) error {
+	fmt.Fprintf(w, "# goscan report\n\n")
+	fmt.Fprintf(w, "Started %s, took %s, %d addresses scanned, %d hosts up.\n",
+		report.StartedAt.Format("2006-01-02 15:04:05 MST"), report.Elapsed.Round(1e6),
+		report.TotalIPsScanned, report.ActiveHostCount())
+
+	for i := range report.Interfaces {
+		iface := &report.Interfaces[i]
+		fmt.Fprintf(w, "\n## %s\n\n", iface.QualifiedName())
+		fmt.Fprintf(w, "- MAC address: %s\n", iface.MACAddress)
+		fmt.Fprintf(w, "- Addresses: %s\n", strings.Join(iface.Addresses, ", "))
+		fmt.Fprintf(w, "- Profile: %s\n", iface.Profile)
+		fmt.Fprintf(w, "- Hosts up: %d of %d\n", len(iface.ActiveHosts), iface.TotalIPsScanned)
+		for _, e := range iface.Errors {
+			fmt.Fprintf(w, "- Error: %s (%d): %s\n", e.Kind, e.Count, markdownEscape.Replace(e.Message))
+		}
+
+		fmt.Fprintf(w, "\n| IP | State | MAC | Method | RTT (ms) | Names | Lease hostname |\n")
+		fmt.Fprintf(w, "|----|-------|-----|--------|----------|-------|----------------|\n")
+		for _, r := range rows(iface, showMode(opts)) {
+			f := r.fields()
+			for j := range f {
+				f[j] = markdownEscape.Replace(f[j])
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(f[1:], " | "))
+		}
+	}
+	return nil
+}
+
+func writeTable(w io.Writer, report *networkutils.ScanReport, opts Options) error {
+	table := tablewriter.NewWriter(w)
+	header := make([]string, len(rowHeader))
+	for i, h := range rowHeader {
+		header[i] = strings.ReplaceAll(h, "_", " ")
+	}
+	table.SetHeader(header)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetBorder(false)
+	table.SetColumnSeparator("   ")
+	table.SetAutoWrapText(false)
+
+	for i := range report.Interfaces {
+		for _, r := range rows(&report.Interfaces[i], showMode(opts)) {
+			table.Append(r.fields())
+		}
+	}
+	table.Render()
+	return nil
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   YAML output with the same keys and layout as the JSON report.
+*/
+
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// writeYAML encodes a report or listing through its JSON form, so that the
+// keys follow the JSON tags and the versioned schema. JSON is valid YAML;
+// the decoded node tree keeps the field order and only needs its flow style
+// reset to be written as block YAML.
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	resetStyle(&doc)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
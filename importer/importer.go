@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Readers for the results of other scanners, so that hosts they found can
+   be added to the inventory: nmap's XML output ("-oX") and masscan's JSON
+   output ("-oJ").
+*/
+
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"goscan/inventory"
+)
+
+// Supported input formats.
+const (
+	FormatNmapXML     = "nmap-xml"
+	FormatMasscanJSON = "masscan-json"
+)
+
+// Formats lists the supported input formats.
+var Formats = []string{FormatNmapXML, FormatMasscanJSON}
+
+// Result is the hosts found by a scanner.
+type Result struct {
+	// Source is the tool that produced the results ("nmap", "masscan").
+	Source    string
+	Sightings []inventory.Sighting
+}
+
+// Parse reads scan results in the given format. Only hosts reported up are
+// returned, one sighting per address.
+func Parse(r io.Reader, format string) (*Result, error) {
+	switch format {
+	case FormatNmapXML:
+		return parseNmap(r)
+	case FormatMasscanJSON:
+		return parseMasscan(r)
+	}
+	return nil, ValidateFormat(format)
+}
+
+// ValidateFormat checks that format is supported.
+func ValidateFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown import format %q (expected one of %s)", format, strings.Join(Formats, ", "))
+}
+
+// DetectFormat guesses the format of a results file from its contents.
+func DetectFormat(data []byte) (string, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return FormatNmapXML, nil
+	case bytes.HasPrefix(trimmed, []byte("[")), bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatMasscanJSON, nil
+	}
+	return "", fmt.Errorf("unrecognized scan results format")
+}
+
+// ReadFile reads a results file. An empty format is detected from the
+// contents.
+func ReadFile(path, format string) (*Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scan results: %w", err)
+	}
+	if format == "" {
+		if format, err = DetectFormat(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	result, err := Parse(bytes.NewReader(data), format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return result, nil
+}
+
+// merge folds a sighting into the one already collected for its address, so
+// that a host listed several times (once per port with masscan) counts as
+// a single sighting.
+func merge(sightings []inventory.Sighting, byIP map[string]int, s inventory.Sighting) []inventory.Sighting {
+	i, ok := byIP[s.IP.String()]
+	if !ok {
+		byIP[s.IP.String()] = len(sightings)
+		return append(sightings, s)
+	}
+	prev := &sightings[i]
+	if prev.MAC == "" {
+		prev.MAC = s.MAC
+	}
+	if prev.Method == "" {
+		prev.Method = s.Method
+	}
+	for _, name := range s.Names {
+		if !contains(prev.Names, name) {
+			prev.Names = append(prev.Names, name)
+		}
+	}
+	if s.SeenAt.After(prev.SeenAt) {
+		prev.SeenAt = s.SeenAt
+	}
+	return sightings
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   nmap and masscan result parsing.
+*/
+
+package importer
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"goscan/inventory"
+)
+
+// sightingString is a compact form of a sighting for comparison: address,
+// MAC, method, names and Unix time.
+func sightingString(s inventory.Sighting) string {
+	return strings.Join([]string{s.IP.String(), s.MAC, s.Method, strings.Join(s.Names, ","), strconv.FormatInt(s.SeenAt.Unix(), 10)}, " ")
+}
+
+func sightingStrings(sightings []inventory.Sighting) []string {
+	s := []string{}
+	for _, sighting := range sightings {
+		s = append(s, sightingString(sighting))
+	}
+	return s
+}
+
+const nmapXML = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE nmaprun>
+<?xml-stylesheet href="file:///usr/bin/../share/nmap/nmap.xsl" type="text/xsl"?>
+<nmaprun scanner="nmap" args="nmap -sn -oX - 192.168.1.0/24" start="1704405000" version="7.94" xmloutputversion="1.05">
+<verbose level="0"/>
+<debugging level="0"/>
+<host><status state="up" reason="arp-response" reason_ttl="0"/>
+<address addr="192.168.1.1" addrtype="ipv4"/>
+<address addr="AA:BB:CC:DD:EE:01" addrtype="mac" vendor="Acme"/>
+<hostnames><hostname name="router.lan" type="PTR"/></hostnames>
+</host>
+<host starttime="1704405010" endtime="1704405020"><status state="up" reason="syn-ack" reason_ttl="64"/>
+<address addr="192.168.1.20" addrtype="ipv4"/>
+<hostnames>
+<hostname name="nas" type="user"/>
+<hostname name="nas.lan" type="PTR"/>
+<hostname name="nas" type="PTR"/>
+</hostnames>
+</host>
+<host><status state="down" reason="no-response" reason_ttl="0"/>
+<address addr="192.168.1.30" addrtype="ipv4"/>
+</host>
+<host starttime="1704405030"><status state="up" reason="user-set" reason_ttl="0"/>
+<address addr="fd00::40" addrtype="ipv6"/>
+</host>
+<host starttime="1704405040"><status state="up" reason="echo-reply" reason_ttl="64"/>
+<address addr="192.168.1.20" addrtype="ipv4"/>
+<address addr="aa:bb:cc:dd:ee:20" addrtype="mac"/>
+<hostnames><hostname name="storage.lan" type="PTR"/></hostnames>
+</host>
+<runstats><finished time="1704405100" timestr="Thu Jan  4 22:05:00 2024" elapsed="100"/>
+<hosts up="4" down="1" total="5"/>
+</runstats>
+</nmaprun>
+`
+
+func TestParseNmap(t *testing.T) {
+	result, err := Parse(strings.NewReader(nmapXML), FormatNmapXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Source != "nmap" {
+		t.Errorf("source = %q, want nmap", result.Source)
+	}
+	// The second listing of .20 adds its MAC and name and a later time; the
+	// down host is left out
+	want := []string{
+		"192.168.1.1 aa:bb:cc:dd:ee:01 arp router.lan 1704405100",
+		"192.168.1.20 aa:bb:cc:dd:ee:20 tcp nas,nas.lan,storage.lan 1704405040",
+		"fd00::40  user-set  1704405030",
+	}
+	if got := sightingStrings(result.Sightings); !reflect.DeepEqual(got, want) {
+		t.Errorf("sightings =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseNmapRunStart(t *testing.T) {
+	// An interrupted run has no finished time
+	input := `<nmaprun scanner="nmap" start="1704405000"><host><status state="up" reason="echo-reply"/><address addr="10.0.0.1" addrtype="ipv4"/></host></nmaprun>`
+	result, err := Parse(strings.NewReader(input), FormatNmapXML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1  icmp  1704405000"}
+	if got := sightingStrings(result.Sightings); !reflect.DeepEqual(got, want) {
+		t.Errorf("sightings = %v, want %v", got, want)
+	}
+}
+
+func TestParseMasscan(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "valid json",
+			input: `[
+{"ip": "10.0.0.5", "timestamp": "1704405000", "ports": [{"port": 22, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64}]},
+{"ip": "10.0.0.6", "timestamp": "1704405001", "ports": [{"port": 80, "proto": "tcp", "status": "closed", "reason": "rst", "ttl": 64}]},
+{"ip": "10.0.0.5", "timestamp": "1704405002", "ports": [{"port": 443, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64}]},
+{"ip": "10.0.0.7", "timestamp": "1704405003", "ports": [{"port": 53, "proto": "udp", "status": "filtered"}]}
+]`,
+		},
+		{
+			name: "trailing comma",
+			input: `[
+{   "ip": "10.0.0.5",   "timestamp": "1704405000", "ports": [ {"port": 22, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "10.0.0.6",   "timestamp": "1704405001", "ports": [ {"port": 80, "proto": "tcp", "status": "closed", "reason": "rst", "ttl": 64} ] },
+{   "ip": "10.0.0.5",   "timestamp": "1704405002", "ports": [ {"port": 443, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "10.0.0.7",   "timestamp": "1704405003", "ports": [ {"port": 53, "proto": "udp", "status": "filtered"} ] },
+]
+`,
+		},
+		{
+			name: "finished marker",
+			input: `[
+{   "ip": "10.0.0.5",   "timestamp": "1704405000", "ports": [ {"port": 22, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "10.0.0.6",   "timestamp": "1704405001", "ports": [ {"port": 80, "proto": "tcp", "status": "closed", "reason": "rst", "ttl": 64} ] },
+{   "ip": "10.0.0.5",   "timestamp": "1704405002", "ports": [ {"port": 443, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] },
+{   "ip": "10.0.0.7",   "timestamp": "1704405003", "ports": [ {"port": 53, "proto": "udp", "status": "filtered"} ] },
+{finished: 1}
+]
+`,
+		},
+	}
+
+	// One sighting per address at its latest time; a filtered port does
+	// not show the host is up
+	want := []string{
+		"10.0.0.5  tcp  1704405002",
+		"10.0.0.6  tcp  1704405001",
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Parse(strings.NewReader(tt.input), FormatMasscanJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Source != "masscan" {
+				t.Errorf("source = %q, want masscan", result.Source)
+			}
+			if got := sightingStrings(result.Sightings); !reflect.DeepEqual(got, want) {
+				t.Errorf("sightings =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		err    string
+	}{
+		{"nmap not xml", FormatNmapXML, "hosts: 3", "invalid nmap XML"},
+		{"nmap other root", FormatNmapXML, `<scan><host/></scan>`, "<scan>"},
+		{"masscan bad line", FormatMasscanJSON, "[\n{\"ip\": \"10.0.0.5\"},\n{ip\n]\n", "invalid masscan JSON on line 3"},
+		{"masscan bad address", FormatMasscanJSON, `[{"ip": "10.0.0", "ports": []}]`, `bad address "10.0.0"`},
+		{"unknown format", "nessus", "", `unknown import format "nessus"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input), tt.format)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{nmapXML, FormatNmapXML},
+		{"\n[\n{\"ip\": \"10.0.0.5\"}\n]", FormatMasscanJSON},
+		{`{"ip": "10.0.0.5"}`, FormatMasscanJSON},
+		{"10.0.0.5 up", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := DetectFormat([]byte(tt.input))
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("DetectFormat(%.20q) = %q, want an error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("DetectFormat(%.20q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
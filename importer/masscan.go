@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   masscan JSON reader.
+
+   masscan writes one object per line and, depending on its version, leaves
+   a trailing comma before the closing bracket or ends the list with a bare
+   "{finished: 1}" marker, neither of which is valid JSON. Files that do not
+   decode as a whole are therefore read line by line.
+*/
+
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"goscan/inventory"
+)
+
+type masscanRecord struct {
+	IP        string        `json:"ip"`
+	Timestamp string        `json:"timestamp"`
+	Ports     []masscanPort `json:"ports"`
+}
+
+type masscanPort struct {
+	Port   int    `json:"port"`
+	Proto  string `json:"proto"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+func parseMasscan(r io.Reader) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []masscanRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		if records, err = masscanLines(data); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{Source: "masscan"}
+	byIP := make(map[string]int)
+	for _, rec := range records {
+		ip := net.ParseIP(rec.IP)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid masscan JSON: bad address %q", rec.IP)
+		}
+		s := inventory.Sighting{IP: ip, SeenAt: time.Now()}
+		if seconds, err := strconv.ParseInt(rec.Timestamp, 10, 64); err == nil {
+			s.SeenAt = time.Unix(seconds, 0)
+		}
+		up := false
+		for _, port := range rec.Ports {
+			// A closed port still answered, so the host is up either way
+			if port.Status == "open" || port.Status == "closed" || port.Status == "up" {
+				up = true
+				if s.Method == "" {
+					s.Method = port.Proto
+				}
+			}
+		}
+		if up {
+			result.Sightings = merge(result.Sightings, byIP, s)
+		}
+	}
+	return result, nil
+}
+
+// masscanLines decodes masscan's line-oriented output.
+func masscanLines(data []byte) ([]masscanRecord, error) {
+	var records []masscanRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSuffix(strings.TrimSpace(scanner.Text()), ",")
+		if line == "" || line == "[" || line == "]" || strings.HasPrefix(line, "{finished") {
+			continue
+		}
+		var rec masscanRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, fmt.Errorf("invalid masscan JSON on line %d: %w", lineNum, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
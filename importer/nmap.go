@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   nmap XML reader. Host discovery ("-sn") and port scans are read alike:
+   every host whose status is up is a sighting.
+*/
+
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"goscan/export"
+	"goscan/inventory"
+	"goscan/networkutils"
+)
+
+// nmapMethods maps nmap's status reasons to probe methods. Other reasons
+// are recorded as they are.
+var nmapMethods = map[string]string{
+	"arp-response":      networkutils.MethodARP,
+	"nd-response":       networkutils.MethodARP,
+	"echo-reply":        networkutils.MethodICMP,
+	"timestamp-reply":   networkutils.MethodICMP,
+	"addressmask-reply": networkutils.MethodICMP,
+	"syn-ack":           networkutils.MethodTCP,
+	"reset":             networkutils.MethodTCP,
+	"conn-refused":      networkutils.MethodTCP,
+}
+
+func parseNmap(r io.Reader) (*Result, error) {
+	var run export.NmapRun
+	dec := xml.NewDecoder(r)
+	// nmap writes a DOCTYPE and a stylesheet instruction, both ignored
+	dec.Strict = false
+	if err := dec.Decode(&run); err != nil {
+		return nil, fmt.Errorf("invalid nmap XML: %w", err)
+	}
+	if run.XMLName.Local != "nmaprun" {
+		return nil, fmt.Errorf("invalid nmap XML: root element is <%s>, not <nmaprun>", run.XMLName.Local)
+	}
+
+	// Hosts without times of their own were seen when the run finished
+	runTime := time.Unix(run.RunStats.Finished.Time, 0)
+	if run.RunStats.Finished.Time == 0 {
+		runTime = time.Unix(run.Start, 0)
+	}
+
+	result := &Result{Source: "nmap"}
+	if run.Scanner != "" {
+		result.Source = run.Scanner
+	}
+	byIP := make(map[string]int)
+	for _, host := range run.Hosts {
+		if host.Status.State != "up" {
+			continue
+		}
+		s := inventory.Sighting{
+			Method: nmapMethods[host.Status.Reason],
+			SeenAt: runTime,
+		}
+		if s.Method == "" {
+			s.Method = host.Status.Reason
+		}
+		switch {
+		case host.EndTime != 0:
+			s.SeenAt = time.Unix(host.EndTime, 0)
+		case host.StartTime != 0:
+			s.SeenAt = time.Unix(host.StartTime, 0)
+		}
+		for _, addr := range host.Addresses {
+			switch addr.AddrType {
+			case "ipv4", "ipv6":
+				s.IP = net.ParseIP(addr.Addr)
+			case "mac":
+				s.MAC = strings.ToLower(addr.Addr)
+			}
+		}
+		if s.IP == nil {
+			continue
+		}
+		if host.Hostnames != nil {
+			for _, name := range host.Hostnames.Hostnames {
+				if name.Name != "" && !contains(s.Names, name.Name) {
+					s.Names = append(s.Names, name.Name)
+				}
+			}
+		}
+		result.Sightings = merge(result.Sightings, byIP, s)
+	}
+	return result, nil
+}
@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Host annotations: owner, role, tags and notes attached to an IP address
+   by users. They are stored apart from scan data so rescans never touch
+   them.
+*/
+
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var annotationsBucket = []byte("annotations")
+
+// Annotation is user-supplied information about a host.
+type Annotation struct {
+	Owner     string    `json:"owner,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Notes     string    `json:"notes,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Empty reports whether the annotation holds no information.
+func (a Annotation) Empty() bool {
+	return a.Owner == "" && a.Role == "" && len(a.Tags) == 0 && a.Notes == ""
+}
+
+// AnnotatedIP is an annotation with the address it belongs to.
+type AnnotatedIP struct {
+	IP string `json:"ip"`
+	Annotation
+}
+
+func normalizeIP(ip string) (string, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", fmt.Errorf("invalid IP address %q", ip)
+	}
+	return parsed.String(), nil
+}
+
+// normalizeTags trims, deduplicates and sorts tags.
+func normalizeTags(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			out = addUnique(out, tag)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Annotate stores the annotation of an IP address, replacing the previous
+// one. An empty annotation removes it.
+func (s *Store) Annotate(ip string, a Annotation) error {
+	key, err := normalizeIP(ip)
+	if err != nil {
+		return err
+	}
+	a.Tags = normalizeTags(a.Tags)
+	a.Owner = strings.TrimSpace(a.Owner)
+	a.Role = strings.TrimSpace(a.Role)
+	a.Notes = strings.TrimSpace(a.Notes)
+
+	return s.update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(annotationsBucket)
+		if err != nil {
+			return err
+		}
+		if a.Empty() {
+			return b.Delete([]byte(key))
+		}
+		if a.UpdatedAt.IsZero() {
+			a.UpdatedAt = time.Now().UTC()
+		}
+		v, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), v)
+	})
+}
+
+// Annotation returns the annotation of an IP address. It returns
+// ErrNotFound if there is none.
+func (s *Store) Annotation(ip string) (Annotation, error) {
+	key, err := normalizeIP(ip)
+	if err != nil {
+		return Annotation{}, err
+	}
+
+	var a Annotation
+	found := false
+	err = s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(annotationsBucket)
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(key))
+		if v == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(v, &a)
+	})
+	if err != nil {
+		return Annotation{}, err
+	}
+	if !found {
+		return Annotation{}, ErrNotFound
+	}
+	return a, nil
+}
+
+// Annotations returns every annotation, sorted by IP address.
+func (s *Store) Annotations() ([]AnnotatedIP, error) {
+	result := []AnnotatedIP{}
+	err := s.view(func(tx *bolt.Tx) error {
+		return readAnnotations(tx, func(ip string, a Annotation) {
+			result = append(result, AnnotatedIP{IP: ip, Annotation: a})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return compareIPs(net.ParseIP(result[i].IP), net.ParseIP(result[j].IP)) < 0
+	})
+	return result, nil
+}
+
+func readAnnotations(tx *bolt.Tx, fn func(ip string, a Annotation)) error {
+	b := tx.Bucket(annotationsBucket)
+	if b == nil {
+		return nil
+	}
+	return b.ForEach(func(k, v []byte) error {
+		var a Annotation
+		if err := json.Unmarshal(v, &a); err != nil {
+			return fmt.Errorf("corrupt annotation for %s: %w", k, err)
+		}
+		fn(string(k), a)
+		return nil
+	})
+}
+
+// Search returns the hosts matching a query. Each whitespace-separated term
+// must match: "owner:", "role:", "tag:", "iface:" and "mac:" terms match that
+// field, other terms match any of the address, MAC, names, interface and
+// annotation fields. Matching is case-insensitive and by substring, except
+// for tags and "source:" terms (the tool that found the host) which must
+// match exactly.
+func (s *Store) Search(query string) ([]Host, error) {
+	hosts, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := strings.Fields(strings.ToLower(query))
+	var result []Host
+	for _, h := range hosts {
+		if matchesAll(h, terms) {
+			result = append(result, h)
+		}
+	}
+	return result, nil
+}
+
+func matchesAll(h Host, terms []string) bool {
+	for _, term := range terms {
+		if !matchesTerm(h, term) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesTerm(h Host, term string) bool {
+	var a Annotation
+	if h.Annotation != nil {
+		a = *h.Annotation
+	}
+	contains := func(s string) bool { return strings.Contains(strings.ToLower(s), term) }
+
+	if field, value, ok := strings.Cut(term, ":"); ok && value != "" {
+		in := func(s string) bool { return strings.Contains(strings.ToLower(s), value) }
+		switch field {
+		case "owner":
+			return in(a.Owner)
+		case "role":
+			return in(a.Role)
+		case "iface":
+			return in(h.Interface)
+		case "mac":
+			return in(h.MAC)
+		case "tag":
+			for _, tag := range a.Tags {
+				if strings.ToLower(tag) == value {
+					return true
+				}
+			}
+			return false
+		case "source":
+			for _, source := range h.Sources {
+				if strings.ToLower(source) == value {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	if contains(h.IP) || contains(h.MAC) || contains(h.Interface) ||
+		contains(a.Owner) || contains(a.Role) || contains(a.Notes) {
+		return true
+	}
+	for _, name := range h.Names {
+		if contains(name) {
+			return true
+		}
+	}
+	for _, tag := range a.Tags {
+		if contains(tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Per-host availability history.
+
+   Each scheduled scan adds an up or down sample for every host known on the
+   scanned interfaces. Samples older than HistoryRetention are pruned, and a
+   host is forgotten once it has not been up for that long.
+*/
+
+package inventory
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"goscan/networkutils"
+)
+
+// HistoryRetention is how long availability samples are kept.
+const HistoryRetention = 30 * 24 * time.Hour
+
+// AvailabilityWindows are the periods availability is reported over.
+var AvailabilityWindows = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+var historyBucket = []byte("history")
+
+// Sample is the state of a host in one scan.
+type Sample struct {
+	Time time.Time `json:"time"`
+	Up   bool      `json:"up"`
+}
+
+// WindowStats summarizes availability over one window.
+type WindowStats struct {
+	Window  string `json:"window"`
+	Samples int    `json:"samples"`
+	// Uptime is the percentage of samples in which the host was up.
+	Uptime        float64       `json:"uptime"`
+	LongestOutage time.Duration `json:"longestOutageNs"`
+	// Flaps counts changes between up and down.
+	Flaps int `json:"flaps"`
+}
+
+// HostAvailability is the availability of a host on one interface.
+type HostAvailability struct {
+	Interface  string        `json:"interface"`
+	IP         string        `json:"ip"`
+	Up         bool          `json:"up"`
+	LastChange time.Time     `json:"lastChange"`
+	Windows    []WindowStats `json:"windows"`
+}
+
+func historyKey(ifaceName, ip string) []byte {
+	return []byte(ifaceName + "|" + ip)
+}
+
+func sampleKey(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	return k
+}
+
+// RecordAvailability adds a sample for every host on the interfaces of a
+// scheduled scan: up for the active hosts, down for previously seen ones
+// that did not answer. Interfaces that could not be scanned, and hosts whose
+// probes failed, are skipped.
+func (s *Store) RecordAvailability(report *networkutils.ScanReport) error {
+	at := report.StartedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	cutoff := sampleKey(at.Add(-HistoryRetention))
+
+	return s.update(func(tx *bolt.Tx) error {
+		history, err := tx.CreateBucketIfNotExists(historyBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, iface := range report.Interfaces {
+			if iface.TotalIPsScanned == 0 {
+				continue
+			}
+			name := iface.QualifiedName()
+
+			active := make(map[string]bool, len(iface.ActiveHosts))
+			for _, host := range iface.ActiveHosts {
+				ip := host.IP.String()
+				active[ip] = true
+				b, err := history.CreateBucketIfNotExists(historyKey(name, ip))
+				if err != nil {
+					return err
+				}
+				if err := b.Put(sampleKey(at), []byte{1}); err != nil {
+					return err
+				}
+			}
+
+			var forget [][]byte
+			prefix := name + "|"
+			c := history.Cursor()
+			for k, _ := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, _ = c.Next() {
+				b := history.Bucket(k)
+				if b == nil {
+					continue
+				}
+				ip := strings.TrimPrefix(string(k), prefix)
+				// Hosts whose probes failed get no sample at all
+				if !active[ip] && !iface.ProbeFailed(net.ParseIP(ip)) {
+					if err := b.Put(sampleKey(at), []byte{0}); err != nil {
+						return err
+					}
+				}
+				everUp, err := pruneSamples(b, cutoff)
+				if err != nil {
+					return err
+				}
+				if !everUp {
+					forget = append(forget, append([]byte(nil), k...))
+				}
+			}
+			for _, k := range forget {
+				if err := history.DeleteBucket(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// pruneSamples drops samples before cutoff and reports whether the host was
+// up in any of the remaining ones.
+func pruneSamples(b *bolt.Bucket, cutoff []byte) (bool, error) {
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && string(k) < string(cutoff); k, _ = c.First() {
+		if err := c.Delete(); err != nil {
+			return false, err
+		}
+	}
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if len(v) == 1 && v[0] == 1 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Availability returns the availability of every host with history, as of
+// the given time.
+func (s *Store) Availability(at time.Time) ([]HostAvailability, error) {
+	return s.availability(at, func(ifaceName, ip string) bool { return true })
+}
+
+// HostAvailability returns the availability of an IP address on each
+// interface it was seen on.
+func (s *Store) HostAvailability(ip string, at time.Time) ([]HostAvailability, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, ErrNotFound
+	}
+	result, err := s.availability(at, func(_, hostIP string) bool { return hostIP == parsed.String() })
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrNotFound
+	}
+	return result, nil
+}
+
+func (s *Store) availability(at time.Time, match func(ifaceName, ip string) bool) ([]HostAvailability, error) {
+	result := []HostAvailability{}
+	err := s.view(func(tx *bolt.Tx) error {
+		history := tx.Bucket(historyBucket)
+		if history == nil {
+			return nil
+		}
+		return history.ForEach(func(k, _ []byte) error {
+			ifaceName, ip, ok := strings.Cut(string(k), "|")
+			if !ok || !match(ifaceName, ip) {
+				return nil
+			}
+			samples := readSamples(history.Bucket(k))
+			if len(samples) == 0 {
+				return nil
+			}
+			result = append(result, summarize(ifaceName, ip, samples, at))
+			return nil
+		})
+	})
+	return result, err
+}
+
+func readSamples(b *bolt.Bucket) []Sample {
+	var samples []Sample
+	if b == nil {
+		return nil
+	}
+	b.ForEach(func(k, v []byte) error {
+		if len(k) != 8 || len(v) != 1 {
+			return nil
+		}
+		samples = append(samples, Sample{
+			Time: time.Unix(0, int64(binary.BigEndian.Uint64(k))).UTC(),
+			Up:   v[0] == 1,
+		})
+		return nil
+	})
+	return samples
+}
+
+// summarize computes the availability of a host from its samples, which are
+// in chronological order.
+func summarize(ifaceName, ip string, samples []Sample, at time.Time) HostAvailability {
+	last := samples[len(samples)-1]
+	ha := HostAvailability{Interface: ifaceName, IP: ip, Up: last.Up, LastChange: samples[0].Time}
+	for i := len(samples) - 1; i > 0; i-- {
+		if samples[i].Up != samples[i-1].Up {
+			ha.LastChange = samples[i].Time
+			break
+		}
+	}
+
+	for _, w := range AvailabilityWindows {
+		ha.Windows = append(ha.Windows, windowStats(w.Name, samples, at.Add(-w.Duration), at))
+	}
+	return ha
+}
+
+// windowStats summarizes the samples taken after since. An outage lasts from
+// its first down sample to the next up sample, or until now if ongoing.
+func windowStats(name string, samples []Sample, since, now time.Time) WindowStats {
+	stats := WindowStats{Window: name}
+	up := 0
+	var outageStart time.Time
+	var prev *Sample
+
+	for i := range samples {
+		sample := samples[i]
+		if sample.Time.Before(since) {
+			continue
+		}
+		stats.Samples++
+		if sample.Up {
+			up++
+		}
+		if prev != nil && prev.Up != sample.Up {
+			stats.Flaps++
+		}
+		if !sample.Up && outageStart.IsZero() {
+			outageStart = sample.Time
+		}
+		if sample.Up && !outageStart.IsZero() {
+			if d := sample.Time.Sub(outageStart); d > stats.LongestOutage {
+				stats.LongestOutage = d
+			}
+			outageStart = time.Time{}
+		}
+		prev = &samples[i]
+	}
+	if !outageStart.IsZero() {
+		if d := now.Sub(outageStart); d > stats.LongestOutage {
+			stats.LongestOutage = d
+		}
+	}
+
+	if stats.Samples > 0 {
+		stats.Uptime = float64(up) / float64(stats.Samples) * 100
+	}
+	return stats
+}
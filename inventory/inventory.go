@@ -0,0 +1,397 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Persistent host inventory.
+
+   Hosts are stored in a bbolt database keyed by IP and MAC address, and
+   remember when they were first and last seen, how often, how they were
+   detected, under which names and by which tools. Besides goscan's own
+   scans, results of other scanners can be imported. The database is
+   opened for each operation only, so the CLI can query it while the
+   server is running.
+*/
+
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"goscan/networkutils"
+)
+
+// DefaultPath is the database used when none is configured.
+const DefaultPath = "/var/lib/goscan/inventory.db"
+
+const openTimeout = 5 * time.Second
+
+var hostsBucket = []byte("hosts")
+
+// ErrNotFound is returned when no host matches a lookup.
+var ErrNotFound = errors.New("host not found in inventory")
+
+// Host is an inventory entry.
+type Host struct {
+	IP        string    `json:"ip"`
+	MAC       string    `json:"mac,omitempty"`
+	Interface string    `json:"interface"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	TimesSeen int       `json:"timesSeen"`
+	Methods   []string  `json:"methods"`
+	Names     []string  `json:"names,omitempty"`
+	Sources   []string  `json:"sources,omitempty"`
+
+	// Annotation is filled in from the annotations of the host's IP when
+	// hosts are read; it is not stored with the host.
+	Annotation *Annotation `json:"annotation,omitempty"`
+}
+
+// SourceGoscan is the source of hosts recorded from goscan's own scans.
+const SourceGoscan = "goscan"
+
+// Sighting is a host seen at a given time, by goscan or another scanner.
+type Sighting struct {
+	IP        net.IP
+	MAC       string
+	Interface string
+	Method    string
+	Names     []string
+	SeenAt    time.Time
+}
+
+// ImportStats counts the entries touched by an import.
+type ImportStats struct {
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+}
+
+// Store is a host inventory backed by a bbolt database file.
+type Store struct {
+	path string
+}
+
+// Open returns the store at path, creating the database and its parent
+// directory if needed.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create inventory directory: %w", err)
+	}
+	s := &Store{path: path}
+	err := s.update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(hostsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path returns the database file of the store.
+func (s *Store) Path() string {
+	return s.path
+}
+
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return fmt.Errorf("failed to open inventory %s: %w", s.path, err)
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: openTimeout, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to open inventory %s: %w", s.path, err)
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// hostKey identifies a host by IP and MAC. Hosts detected without ARP have
+// no MAC and use the IP alone.
+func hostKey(ip, mac string) []byte {
+	return []byte(ip + "|" + strings.ToLower(mac))
+}
+
+func ipPrefix(ip string) []byte {
+	return []byte(ip + "|")
+}
+
+// Record adds the active hosts of a scan report to the inventory. It
+// returns the entries it created, for hosts never seen before.
+func (s *Store) Record(report *networkutils.ScanReport) ([]Host, error) {
+	seenAt := report.StartedAt
+	if seenAt.IsZero() {
+		seenAt = time.Now()
+	}
+
+	var sightings []Sighting
+	for _, iface := range report.Interfaces {
+		for _, result := range iface.ActiveHosts {
+			sighting := Sighting{
+				IP:        result.IP,
+				MAC:       result.MAC,
+				Interface: iface.QualifiedName(),
+				Method:    result.Method,
+				Names:     result.Names,
+				SeenAt:    seenAt,
+			}
+			if result.Lease != nil && result.Lease.Hostname != "" {
+				sighting.Names = append(append([]string(nil), result.Names...), result.Lease.Hostname)
+			}
+			sightings = append(sightings, sighting)
+		}
+	}
+	added, _, err := s.record(SourceGoscan, sightings)
+	return added, err
+}
+
+// Import adds hosts seen by source, the name of the scanner that found them,
+// to the inventory. Sightings without an interface keep the interface the
+// host was last seen on, if any.
+func (s *Store) Import(source string, sightings []Sighting) (ImportStats, error) {
+	added, updated, err := s.record(source, sightings)
+	return ImportStats{Added: len(added), Updated: updated}, err
+}
+
+// record stores sightings, returning the entries created and the number of
+// entries updated.
+func (s *Store) record(source string, sightings []Sighting) ([]Host, int, error) {
+	var added []Host
+	updated := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		added, updated = nil, 0
+		b := tx.Bucket(hostsBucket)
+		for _, sighting := range sightings {
+			host, created, err := recordHost(b, source, sighting)
+			if err != nil {
+				return err
+			}
+			if created {
+				added = append(added, *host)
+			} else {
+				updated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return added, updated, nil
+}
+
+// recordHost updates the entry of a sighting, and reports whether it had to
+// be created.
+func recordHost(b *bolt.Bucket, source string, sighting Sighting) (*Host, bool, error) {
+	ip := sighting.IP.String()
+	key := hostKey(ip, sighting.MAC)
+
+	host, err := getHost(b, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if host == nil {
+		// A host first seen without a MAC (ICMP) is the same entry once ARP
+		// reveals it, and a MAC-less sighting belongs to the known MAC.
+		host, key, err = adoptHost(b, ip, sighting.MAC)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	added := host == nil
+	if added {
+		host = &Host{IP: ip, MAC: sighting.MAC, FirstSeen: sighting.SeenAt}
+	}
+	if sighting.Interface != "" {
+		host.Interface = sighting.Interface
+	}
+	if sighting.SeenAt.Before(host.FirstSeen) {
+		host.FirstSeen = sighting.SeenAt
+	}
+	if sighting.SeenAt.After(host.LastSeen) {
+		host.LastSeen = sighting.SeenAt
+	}
+	host.TimesSeen++
+	host.Methods = addUnique(host.Methods, sighting.Method)
+	for _, name := range sighting.Names {
+		host.Names = addUnique(host.Names, name)
+	}
+	host.Sources = addUnique(host.Sources, source)
+
+	return host, added, putHost(b, key, host)
+}
+
+// adoptHost finds an existing entry for ip that a sighting with mac should
+// update instead of creating a new one. It returns the entry and the key it
+// must be stored under.
+func adoptHost(b *bolt.Bucket, ip, mac string) (*Host, []byte, error) {
+	var candidates []*Host
+	c := b.Cursor()
+	prefix := ipPrefix(ip)
+	for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+		var h Host
+		if err := json.Unmarshal(v, &h); err != nil {
+			return nil, nil, err
+		}
+		candidates = append(candidates, &h)
+	}
+
+	if mac == "" {
+		// Attribute the sighting to the most recently seen MAC
+		var latest *Host
+		for _, h := range candidates {
+			if latest == nil || h.LastSeen.After(latest.LastSeen) {
+				latest = h
+			}
+		}
+		if latest == nil {
+			return nil, hostKey(ip, ""), nil
+		}
+		return latest, hostKey(ip, latest.MAC), nil
+	}
+
+	for _, h := range candidates {
+		if h.MAC == "" {
+			if err := b.Delete(hostKey(ip, "")); err != nil {
+				return nil, nil, err
+			}
+			h.MAC = mac
+			return h, hostKey(ip, mac), nil
+		}
+	}
+	return nil, hostKey(ip, mac), nil
+}
+
+func getHost(b *bolt.Bucket, key []byte) (*Host, error) {
+	v := b.Get(key)
+	if v == nil {
+		return nil, nil
+	}
+	var h Host
+	if err := json.Unmarshal(v, &h); err != nil {
+		return nil, fmt.Errorf("corrupt inventory entry %s: %w", key, err)
+	}
+	return &h, nil
+}
+
+func putHost(b *bolt.Bucket, key []byte, host *Host) error {
+	host.Annotation = nil
+	v, err := json.Marshal(host)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, v)
+}
+
+func addUnique(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// List returns every host in the inventory, sorted by IP address.
+func (s *Store) List() ([]Host, error) {
+	var hosts []Host
+	err := s.view(func(tx *bolt.Tx) error {
+		err := tx.Bucket(hostsBucket).ForEach(func(k, v []byte) error {
+			var h Host
+			if err := json.Unmarshal(v, &h); err != nil {
+				return fmt.Errorf("corrupt inventory entry %s: %w", k, err)
+			}
+			hosts = append(hosts, h)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return attachAnnotations(tx, hosts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortHosts(hosts)
+	return hosts, nil
+}
+
+// Lookup returns the entries for an IP address, one per MAC it was seen with.
+func (s *Store) Lookup(ip string) ([]Host, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid IP address %q", ip)
+	}
+
+	var hosts []Host
+	err := s.view(func(tx *bolt.Tx) error {
+		c := tx.Bucket(hostsBucket).Cursor()
+		prefix := ipPrefix(parsed.String())
+		for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+			var h Host
+			if err := json.Unmarshal(v, &h); err != nil {
+				return fmt.Errorf("corrupt inventory entry %s: %w", k, err)
+			}
+			hosts = append(hosts, h)
+		}
+		return attachAnnotations(tx, hosts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, ErrNotFound
+	}
+	sortHosts(hosts)
+	return hosts, nil
+}
+
+func attachAnnotations(tx *bolt.Tx, hosts []Host) error {
+	annotations := make(map[string]Annotation)
+	err := readAnnotations(tx, func(ip string, a Annotation) {
+		annotations[ip] = a
+	})
+	if err != nil {
+		return err
+	}
+	for i := range hosts {
+		if a, ok := annotations[hosts[i].IP]; ok {
+			hosts[i].Annotation = &a
+		}
+	}
+	return nil
+}
+
+// sortHosts orders hosts by IP address, then by last sighting.
+func sortHosts(hosts []Host) {
+	sort.Slice(hosts, func(i, j int) bool {
+		a, b := net.ParseIP(hosts[i].IP), net.ParseIP(hosts[j].IP)
+		if c := compareIPs(a, b); c != 0 {
+			return c < 0
+		}
+		return hosts[i].LastSeen.After(hosts[j].LastSeen)
+	})
+}
+
+func compareIPs(a, b net.IP) int {
+	return strings.Compare(string(a.To16()), string(b.To16()))
+}
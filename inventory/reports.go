@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Saved scan reports, such as the latest full scan and a baseline, kept
+   alongside the hosts so that scans can be compared across restarts.
+*/
+
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+
+	"goscan/networkutils"
+)
+
+// Names of the reports kept by the server.
+const (
+	ReportLatest   = "latest"
+	ReportPrevious = "previous"
+	ReportBaseline = "baseline"
+)
+
+var reportsBucket = []byte("reports")
+
+// ErrNoReport is returned when a named report has not been saved.
+var ErrNoReport = errors.New("no such scan report saved")
+
+// SaveReport stores a scan report under name, replacing any previous one.
+func (s *Store) SaveReport(name string, report *networkutils.ScanReport) error {
+	v, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(reportsBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(name), v)
+	})
+}
+
+// RotateReport saves report as the latest one, keeping the one it replaces
+// as the previous report.
+func (s *Store) RotateReport(report *networkutils.ScanReport) error {
+	v, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(reportsBucket)
+		if err != nil {
+			return err
+		}
+		if latest := b.Get([]byte(ReportLatest)); latest != nil {
+			if err := b.Put([]byte(ReportPrevious), append([]byte(nil), latest...)); err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte(ReportLatest), v)
+	})
+}
+
+// LoadReport returns the scan report saved under name.
+func (s *Store) LoadReport(name string) (*networkutils.ScanReport, error) {
+	var data []byte
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(reportsBucket)
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(name)); v != nil {
+			data = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrNoReport
+	}
+	return networkutils.ReadReport(bytes.NewReader(data))
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Address reservations: addresses handed out by goscan allocate, held for
+   an owner until they expire or are released, and never handed out again
+   meanwhile. Reserving is a single transaction, so concurrent allocations
+   from the server and the CLI cannot claim the same address.
+*/
+
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var reservationsBucket = []byte("reservations")
+
+// ErrReserved is returned when reserving an address already reserved.
+var ErrReserved = errors.New("address already reserved")
+
+// Reservation holds an address for an owner.
+type Reservation struct {
+	IP        string    `json:"ip"`
+	Interface string    `json:"interface,omitempty"`
+	Owner     string    `json:"owner"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// ExpiresAt is nil for reservations that never expire.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Expired reports whether the reservation no longer holds at the given time.
+func (r Reservation) Expired(at time.Time) bool {
+	return r.ExpiresAt != nil && !r.ExpiresAt.After(at)
+}
+
+// Reserve records a reservation. It fails with ErrReserved if the address
+// holds a reservation that has not expired.
+func (s *Store) Reserve(r Reservation) error {
+	key, err := normalizeIP(r.IP)
+	if err != nil {
+		return err
+	}
+	r.IP = key
+	r.Owner = strings.TrimSpace(r.Owner)
+	r.Note = strings.TrimSpace(r.Note)
+	if r.Owner == "" {
+		return errors.New("a reservation needs an owner")
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now().UTC()
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(reservationsBucket)
+		if err != nil {
+			return err
+		}
+		if v := b.Get([]byte(key)); v != nil {
+			var existing Reservation
+			if err := json.Unmarshal(v, &existing); err != nil {
+				return fmt.Errorf("corrupt reservation for %s: %w", key, err)
+			}
+			if !existing.Expired(r.CreatedAt) {
+				return fmt.Errorf("%s: %w by %s", key, ErrReserved, existing.Owner)
+			}
+		}
+		v, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), v)
+	})
+}
+
+// Release removes the reservation of an address. It returns ErrNotFound if
+// there is none.
+func (s *Store) Release(ip string) error {
+	key, err := normalizeIP(ip)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(reservationsBucket)
+		if b == nil || b.Get([]byte(key)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// Reservations returns the reservations that hold at the given time, sorted
+// by IP address. Expired ones are removed.
+func (s *Store) Reservations(at time.Time) ([]Reservation, error) {
+	result := []Reservation{}
+	err := s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(reservationsBucket)
+		if b == nil {
+			return nil
+		}
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var r Reservation
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("corrupt reservation for %s: %w", k, err)
+			}
+			if r.Expired(at) {
+				expired = append(expired, k)
+				return nil
+			}
+			result = append(result, r)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return compareIPs(net.ParseIP(result[i].IP), net.ParseIP(result[j].IP)) < 0
+	})
+	return result, nil
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Address reservations.
+*/
+
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "inventory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestReserveConcurrent(t *testing.T) {
+	s := openTestStore(t)
+	// A second store on the same file stands for another process, such as
+	// goscan allocate next to the server
+	other, err := Open(s.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const attempts = 16
+	var wg sync.WaitGroup
+	errs := make([]error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store := s
+			if i%2 == 1 {
+				store = other
+			}
+			errs[i] = store.Reserve(Reservation{IP: "192.0.2.10", Owner: fmt.Sprintf("owner-%d", i)})
+		}(i)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			if winner >= 0 {
+				t.Errorf("both owner-%d and owner-%d reserved 192.0.2.10", winner, i)
+			}
+			winner = i
+		case !errors.Is(err, ErrReserved):
+			t.Errorf("owner-%d: %v, want ErrReserved", i, err)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("no reservation succeeded")
+	}
+
+	reservations, err := s.Reservations(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reservations) != 1 || reservations[0].Owner != fmt.Sprintf("owner-%d", winner) {
+		t.Errorf("reservations = %+v, want the one of owner-%d", reservations, winner)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		existing *Reservation
+		r        Reservation
+		err      error
+	}{
+		{
+			name: "free address",
+			r:    Reservation{IP: "192.0.2.10", Owner: "ops", CreatedAt: now},
+		},
+		{
+			name:     "held",
+			existing: &Reservation{IP: "192.0.2.10", Owner: "lab", CreatedAt: past, ExpiresAt: &future},
+			r:        Reservation{IP: "192.0.2.10", Owner: "ops", CreatedAt: now},
+			err:      ErrReserved,
+		},
+		{
+			name:     "held forever",
+			existing: &Reservation{IP: "192.0.2.10", Owner: "lab", CreatedAt: past},
+			r:        Reservation{IP: "192.0.2.10", Owner: "ops", CreatedAt: now},
+			err:      ErrReserved,
+		},
+		{
+			name:     "expired",
+			existing: &Reservation{IP: "192.0.2.10", Owner: "lab", CreatedAt: past.Add(-time.Hour), ExpiresAt: &past},
+			r:        Reservation{IP: "192.0.2.10", Owner: "ops", CreatedAt: now},
+		},
+		{
+			// Addresses are compared in canonical form
+			name:     "same address written differently",
+			existing: &Reservation{IP: "fd00::a", Owner: "lab", CreatedAt: past},
+			r:        Reservation{IP: "fd00:0:0::0a", Owner: "ops", CreatedAt: now},
+			err:      ErrReserved,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openTestStore(t)
+			if tt.existing != nil {
+				if err := s.Reserve(*tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := s.Reserve(tt.r)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Reserve() = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestReserveInvalid(t *testing.T) {
+	s := openTestStore(t)
+	for _, r := range []Reservation{
+		{IP: "192.0.2", Owner: "ops"},
+		{IP: "192.0.2.10", Owner: "  "},
+	} {
+		if err := s.Reserve(r); err == nil {
+			t.Errorf("Reserve(%+v) succeeded", r)
+		}
+	}
+}
+
+func TestReleaseAndExpiry(t *testing.T) {
+	s := openTestStore(t)
+	now := time.Now().UTC()
+	soon := now.Add(time.Minute)
+	for _, r := range []Reservation{
+		{IP: "192.0.2.20", Owner: "ops", Note: " printer ", CreatedAt: now},
+		{IP: "192.0.2.3", Owner: "lab", CreatedAt: now, ExpiresAt: &soon},
+	} {
+		if err := s.Reserve(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reservations, err := s.Reservations(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reservations) != 2 || reservations[0].IP != "192.0.2.3" || reservations[1].Note != "printer" {
+		t.Errorf("reservations = %+v, want 192.0.2.3 then 192.0.2.20 with its note trimmed", reservations)
+	}
+
+	// The reservation of .3 has expired an hour from now and is dropped
+	reservations, err = s.Reservations(now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reservations) != 1 || reservations[0].IP != "192.0.2.20" {
+		t.Errorf("reservations = %+v, want 192.0.2.20 alone", reservations)
+	}
+
+	if err := s.Release("192.0.2.20"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Release("192.0.2.20"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Release() = %v, want ErrNotFound", err)
+	}
+}
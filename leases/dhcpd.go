@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   ISC dhcpd lease database (dhcpd.leases). Leases are blocks such as:
+
+       lease 192.168.1.10 {
+         starts 4 2024/01/04 10:00:00;
+         ends 4 2024/01/04 22:00:00;
+         binding state active;
+         hardware ethernet aa:bb:cc:dd:ee:ff;
+         client-hostname "laptop";
+       }
+
+   The file is a journal: a later block for the same address replaces the
+   earlier ones. Only leases in the active binding state are kept.
+*/
+
+package leases
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"goscan/networkutils"
+)
+
+func parseDhcpd(r io.Reader) ([]networkutils.Lease, error) {
+	byIP := make(map[string]int)
+	var leases []networkutils.Lease
+	var active []bool
+
+	var current *networkutils.Lease
+	currentActive := true
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+
+		if current == nil {
+			fields := strings.Fields(text)
+			if len(fields) >= 3 && fields[0] == "lease" && fields[2] == "{" {
+				ip := net.ParseIP(fields[1])
+				if ip == nil {
+					return nil, fmt.Errorf("line %d: invalid IP address %q", line, fields[1])
+				}
+				current = &networkutils.Lease{IP: ip}
+				currentActive = true
+			}
+			// Other top-level statements (server-duid, failover, host...)
+			// are skipped.
+			continue
+		}
+
+		if text == "}" {
+			key := current.IP.String()
+			if i, ok := byIP[key]; ok {
+				leases[i] = *current
+				active[i] = currentActive
+			} else {
+				byIP[key] = len(leases)
+				leases = append(leases, *current)
+				active = append(active, currentActive)
+			}
+			current = nil
+			continue
+		}
+
+		statement := strings.TrimSuffix(text, ";")
+		fields := strings.Fields(statement)
+		switch {
+		case len(fields) >= 3 && fields[0] == "hardware" && fields[1] == "ethernet":
+			if mac, err := net.ParseMAC(fields[2]); err == nil {
+				current.MAC = mac.String()
+			}
+		case len(fields) >= 2 && fields[0] == "client-hostname":
+			current.Hostname = strings.Trim(strings.Join(fields[1:], " "), "\"")
+		case len(fields) >= 3 && fields[0] == "binding" && fields[1] == "state":
+			currentActive = fields[2] == "active"
+		case len(fields) >= 2 && fields[0] == "ends":
+			expires, err := parseDhcpdTime(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			current.Expires = expires
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		return nil, fmt.Errorf("line %d: unterminated lease for %s", line, current.IP)
+	}
+
+	var result []networkutils.Lease
+	for i, lease := range leases {
+		if active[i] {
+			result = append(result, lease)
+		}
+	}
+	return result, nil
+}
+
+// stripComment removes a "#" comment from a line, leaving any "#" within a
+// quoted string.
+func stripComment(line string) string {
+	quoted := false
+	for i, c := range line {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case '#':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// parseDhcpdTime parses the value of an "ends" statement: "never",
+// "<weekday> <yyyy/mm/dd> <hh:mm:ss>" in UTC, or "epoch <seconds>" when
+// db-time-format is local.
+func parseDhcpdTime(fields []string) (*time.Time, error) {
+	switch {
+	case fields[0] == "never":
+		return nil, nil
+	case fields[0] == "epoch" && len(fields) >= 2:
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lease time %q", strings.Join(fields, " "))
+		}
+		t := time.Unix(seconds, 0).UTC()
+		return &t, nil
+	case len(fields) >= 3:
+		t, err := time.Parse("2006/01/02 15:04:05", fields[1]+" "+fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid lease time %q", strings.Join(fields, " "))
+		}
+		return &t, nil
+	}
+	return nil, fmt.Errorf("invalid lease time %q", strings.Join(fields, " "))
+}
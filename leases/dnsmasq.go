@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   dnsmasq lease file (dhcp-leasefile), one lease per line:
+
+       <expiry epoch> <mac> <ip> <hostname|*> <client id|*>
+
+   An expiry of 0 means an infinite lease. A "duid" line introduces the
+   DHCPv6 leases that follow; they are read the same way, without a MAC.
+*/
+
+package leases
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"goscan/networkutils"
+)
+
+func parseDnsmasq(r io.Reader) ([]networkutils.Lease, error) {
+	var leases []networkutils.Lease
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] == "duid" {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("line %d: expected at least 4 fields, got %d", line, len(fields))
+		}
+
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", line, fields[0])
+		}
+		ip := net.ParseIP(fields[2])
+		if ip == nil {
+			return nil, fmt.Errorf("line %d: invalid IP address %q", line, fields[2])
+		}
+
+		lease := networkutils.Lease{IP: ip}
+		if mac, err := net.ParseMAC(fields[1]); err == nil {
+			lease.MAC = mac.String()
+		}
+		if fields[3] != "*" {
+			lease.Hostname = fields[3]
+		}
+		if expiry != 0 {
+			expires := time.Unix(expiry, 0).UTC()
+			lease.Expires = &expires
+		}
+		leases = append(leases, lease)
+	}
+	return leases, scanner.Err()
+}
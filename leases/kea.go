@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Kea DHCPv4 memfile lease database (kea-leases4.csv). The first line names
+   the columns; address, hwaddr, valid_lifetime, expire, hostname and state
+   are used. Like dhcpd's, the file is a journal in which later rows replace
+   earlier ones, and a row with a zero valid_lifetime deletes the lease.
+*/
+
+package leases
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"goscan/networkutils"
+)
+
+// keaStateDefault is the state of a lease in use; 1 is declined and 2
+// expired-reclaimed.
+const keaStateDefault = "0"
+
+func parseKea(r io.Reader) ([]networkutils.Lease, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, required := range []string{"address", "hwaddr", "expire"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	byIP := make(map[string]int)
+	var leases []networkutils.Lease
+	var valid []bool
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		ip := net.ParseIP(field(record, "address"))
+		if ip == nil {
+			return nil, fmt.Errorf("line %d: invalid IP address %q", line, field(record, "address"))
+		}
+		lease := networkutils.Lease{IP: ip, Hostname: field(record, "hostname")}
+		if mac, err := net.ParseMAC(field(record, "hwaddr")); err == nil {
+			lease.MAC = mac.String()
+		}
+		expire, err := strconv.ParseInt(field(record, "expire"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expire %q", line, field(record, "expire"))
+		}
+		expires := time.Unix(expire, 0).UTC()
+		lease.Expires = &expires
+
+		ok := field(record, "valid_lifetime") != "0"
+		if state := field(record, "state"); state != "" && state != keaStateDefault {
+			ok = false
+		}
+
+		key := ip.String()
+		if i, seen := byIP[key]; seen {
+			leases[i] = lease
+			valid[i] = ok
+		} else {
+			byIP[key] = len(leases)
+			leases = append(leases, lease)
+			valid = append(valid, ok)
+		}
+	}
+
+	var result []networkutils.Lease
+	for i, lease := range leases {
+		if valid[i] {
+			result = append(result, lease)
+		}
+	}
+	return result, nil
+}
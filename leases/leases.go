@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Readers for DHCP server lease databases: dnsmasq, ISC dhcpd and Kea's
+   memfile CSV.
+
+   Lease files are named as "format:path" or as a bare path, in which case
+   the format is detected from the contents.
+*/
+
+package leases
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"goscan/networkutils"
+)
+
+// Supported lease file formats.
+const (
+	FormatDnsmasq = "dnsmasq"
+	FormatDhcpd   = "dhcpd"
+	FormatKea     = "kea"
+)
+
+// Formats lists the supported lease file formats.
+var Formats = []string{FormatDnsmasq, FormatDhcpd, FormatKea}
+
+// Parse reads leases in the given format. Source is recorded in each lease.
+func Parse(r io.Reader, format, source string) ([]networkutils.Lease, error) {
+	var leases []networkutils.Lease
+	var err error
+	switch format {
+	case FormatDnsmasq:
+		leases, err = parseDnsmasq(r)
+	case FormatDhcpd:
+		leases, err = parseDhcpd(r)
+	case FormatKea:
+		leases, err = parseKea(r)
+	default:
+		return nil, fmt.Errorf("unknown lease file format %q (expected one of %s)", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range leases {
+		leases[i].Source = source
+	}
+	return leases, nil
+}
+
+// DetectFormat guesses the format of a lease file from its contents.
+func DetectFormat(data []byte) (string, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) == 0:
+		// An empty file is a valid dnsmasq lease file with no leases
+		return FormatDnsmasq, nil
+	case bytes.HasPrefix(trimmed, []byte("address,")):
+		return FormatKea, nil
+	case bytes.Contains(data, []byte("lease ")) && bytes.Contains(data, []byte("{")):
+		return FormatDhcpd, nil
+	case trimmed[0] >= '0' && trimmed[0] <= '9':
+		return FormatDnsmasq, nil
+	}
+	return "", fmt.Errorf("unrecognized lease file format")
+}
+
+// splitSpec splits a "format:path" lease file name. A spec without a known
+// format prefix is a path whose format must be detected.
+func splitSpec(spec string) (format, path string) {
+	if prefix, rest, ok := strings.Cut(spec, ":"); ok {
+		for _, f := range Formats {
+			if prefix == f {
+				return f, rest
+			}
+		}
+	}
+	return "", spec
+}
+
+// ReadFile reads the leases of a lease file given as "format:path" or path.
+func ReadFile(spec string) ([]networkutils.Lease, error) {
+	format, path := splitSpec(spec)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lease file: %w", err)
+	}
+	if format == "" {
+		if format, err = DetectFormat(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	leases, err := Parse(bytes.NewReader(data), format, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return leases, nil
+}
+
+// ReadFiles reads several lease files. Later files take precedence for an
+// address leased in more than one.
+func ReadFiles(specs []string) ([]networkutils.Lease, error) {
+	byIP := make(map[string]int)
+	var all []networkutils.Lease
+	for _, spec := range specs {
+		leases, err := ReadFile(spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, lease := range leases {
+			if i, ok := byIP[lease.IP.String()]; ok {
+				all[i] = lease
+				continue
+			}
+			byIP[lease.IP.String()] = len(all)
+			all = append(all, lease)
+		}
+	}
+	return all, nil
+}
+
+// ValidateSpec checks the format prefix of a lease file name, if any.
+func ValidateSpec(spec string) error {
+	if prefix, _, ok := strings.Cut(spec, ":"); ok && !strings.Contains(prefix, "/") {
+		if format, _ := splitSpec(spec); format == "" {
+			return fmt.Errorf("unknown lease file format %q (expected one of %s)", prefix, strings.Join(Formats, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Lease file parsing and format detection.
+*/
+
+package leases
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"goscan/networkutils"
+)
+
+// leaseString is a compact form of a lease for comparison: address, MAC,
+// hostname and expiry in RFC 3339, or "never".
+func leaseString(l networkutils.Lease) string {
+	expires := "never"
+	if l.Expires != nil {
+		expires = l.Expires.Format(time.RFC3339)
+	}
+	return strings.Join([]string{l.IP.String(), l.MAC, l.Hostname, expires}, " ")
+}
+
+func leaseStrings(leases []networkutils.Lease) []string {
+	s := []string{}
+	for _, l := range leases {
+		s = append(s, leaseString(l))
+	}
+	return s
+}
+
+const dnsmasqLeases = `1704405600 aa:bb:cc:dd:ee:01 192.168.1.10 laptop 01:aa:bb:cc:dd:ee:01
+0 AA-BB-CC-DD-EE-02 192.168.1.11 * *
+
+duid 00:01:00:01:2c:3a:4b:5c:aa:bb:cc:dd:ee:ff
+1704405600 1234 fd00::10 phone 00:01:00:01
+`
+
+const dhcpdLeases = `# The format of this file is documented in the dhcpd.leases(5) manual page.
+authoring-byte-order little-endian;
+server-duid "\000\001\000\001";
+
+lease 192.168.1.10 {
+  starts 4 2024/01/04 10:00:00;
+  ends 4 2024/01/04 22:00:00;
+  binding state active;
+  hardware ethernet aa:bb:cc:dd:ee:01;
+  client-hostname "laptop"; # from the client
+}
+lease 192.168.1.11 {
+  ends never;
+  binding state active;
+  hardware ethernet aa:bb:cc:dd:ee:02;
+}
+lease 192.168.1.12 {
+  ends epoch 1704405600; # Thu Jan 04 22:00:00 2024
+  binding state active;
+  client-hostname "rack#2";
+}
+lease 192.168.1.13 {
+  ends 4 2024/01/04 22:00:00;
+  binding state active;
+}
+lease 192.168.1.13 {
+  ends 4 2024/01/04 23:00:00;
+  binding state free;
+}
+lease 192.168.1.11 {
+  ends never;
+  binding state active;
+  hardware ethernet aa:bb:cc:dd:ee:03;
+  client-hostname "desk top";
+}
+`
+
+const keaLeases = `address,hwaddr,client_id,valid_lifetime,expire,subnet_id,fqdn_fwd,fqdn_rev,hostname,state,user_context
+192.168.1.10,aa:bb:cc:dd:ee:01,,3600,1704405600,1,0,0,laptop,0,
+192.168.1.11,aa:bb:cc:dd:ee:02,,3600,1704405600,1,0,0,printer,0,
+192.168.1.12,aa:bb:cc:dd:ee:04,,3600,1704405600,1,0,0,,1,
+192.168.1.11,aa:bb:cc:dd:ee:02,,0,1704405600,1,0,0,printer,0,
+192.168.1.10,aa:bb:cc:dd:ee:01,,3600,1704409200,1,0,0,laptop,0,
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		format string
+		input  string
+		want   []string
+	}{
+		{
+			format: FormatDnsmasq,
+			input:  dnsmasqLeases,
+			want: []string{
+				"192.168.1.10 aa:bb:cc:dd:ee:01 laptop 2024-01-04T22:00:00Z",
+				"192.168.1.11 aa:bb:cc:dd:ee:02  never",
+				"fd00::10  phone 2024-01-04T22:00:00Z",
+			},
+		},
+		{
+			// The later block for .11 replaces the first, .13 is no longer
+			// active
+			format: FormatDhcpd,
+			input:  dhcpdLeases,
+			want: []string{
+				"192.168.1.10 aa:bb:cc:dd:ee:01 laptop 2024-01-04T22:00:00Z",
+				"192.168.1.11 aa:bb:cc:dd:ee:03 desk top never",
+				"192.168.1.12  rack#2 2024-01-04T22:00:00Z",
+			},
+		},
+		{
+			// .11 is deleted by a zero lifetime, .12 is declined
+			format: FormatKea,
+			input:  keaLeases,
+			want: []string{
+				"192.168.1.10 aa:bb:cc:dd:ee:01 laptop 2024-01-04T23:00:00Z",
+			},
+		},
+		{format: FormatDnsmasq, input: "", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			leases, err := Parse(strings.NewReader(tt.input), tt.format, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := leaseStrings(leases); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("leases =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			for _, l := range leases {
+				if l.Source != "test" {
+					t.Errorf("lease %s has source %q, want test", l.IP, l.Source)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		err    string
+	}{
+		{"dnsmasq short line", FormatDnsmasq, "1704405600 aa:bb:cc:dd:ee:01 192.168.1.10\n", "line 1: expected at least 4 fields"},
+		{"dnsmasq expiry", FormatDnsmasq, "soon aa:bb:cc:dd:ee:01 192.168.1.10 * *\n", `line 1: invalid expiry "soon"`},
+		{"dnsmasq address", FormatDnsmasq, "\n0 aa:bb:cc:dd:ee:01 192.168.1.300 * *\n", `line 2: invalid IP address "192.168.1.300"`},
+		{"dhcpd address", FormatDhcpd, "lease 192.168.1 {\n}\n", `line 1: invalid IP address "192.168.1"`},
+		{"dhcpd time", FormatDhcpd, "lease 192.168.1.10 {\n  ends 4 2024-01-04 22:00;\n}\n", "line 2: invalid lease time"},
+		{"dhcpd unterminated", FormatDhcpd, "lease 192.168.1.10 {\n  binding state active;\n", "unterminated lease for 192.168.1.10"},
+		{"kea header", FormatKea, "address,hostname\n", `missing column "hwaddr"`},
+		{"kea address", FormatKea, "address,hwaddr,expire\nnope,,0\n", `line 2: invalid IP address "nope"`},
+		{"kea expire", FormatKea, "address,hwaddr,expire\n192.168.1.10,,later\n", `line 2: invalid expire "later"`},
+		{"unknown format", "isc", "", `unknown lease file format "isc"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input), tt.format, "")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"dnsmasq", dnsmasqLeases, FormatDnsmasq},
+		{"dhcpd", dhcpdLeases, FormatDhcpd},
+		{"kea", keaLeases, FormatKea},
+		{"empty", "\n", FormatDnsmasq},
+		{"unknown", "<leases/>", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectFormat([]byte(tt.input))
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("detected %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("DetectFormat() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	dnsmasq := filepath.Join(dir, "dnsmasq.leases")
+	kea := filepath.Join(dir, "kea-leases4.csv")
+	if err := os.WriteFile(dnsmasq, []byte(dnsmasqLeases), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kea, []byte(keaLeases), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The Kea lease of .10 takes precedence, in the position of the first
+	leases, err := ReadFiles([]string{"dnsmasq:" + dnsmasq, kea})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"192.168.1.10 aa:bb:cc:dd:ee:01 laptop 2024-01-04T23:00:00Z",
+		"192.168.1.11 aa:bb:cc:dd:ee:02  never",
+		"fd00::10  phone 2024-01-04T22:00:00Z",
+	}
+	if got := leaseStrings(leases); !reflect.DeepEqual(got, want) {
+		t.Errorf("leases =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if leases[0].Source != kea || leases[1].Source != dnsmasq {
+		t.Errorf("sources = %q, %q, want %q, %q", leases[0].Source, leases[1].Source, kea, dnsmasq)
+	}
+
+	if _, err := ReadFiles([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	tests := []struct {
+		spec  string
+		valid bool
+	}{
+		{"/var/lib/misc/dnsmasq.leases", true},
+		{"dhcpd:/var/lib/dhcp/dhcpd.leases", true},
+		{"kea:/var/lib/kea/kea-leases4.csv", true},
+		{"./leases:old", true},
+		{"isc:/var/lib/dhcp/dhcpd.leases", false},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateSpec(tt.spec); (err == nil) != tt.valid {
+			t.Errorf("ValidateSpec(%q) = %v, want valid %v", tt.spec, err, tt.valid)
+		}
+	}
+}
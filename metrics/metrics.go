@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Prometheus metrics in the text exposition format (version 0.0.4).
+
+   The collector keeps the latest scan of every interface and renders it,
+   with the probe counters and runtime statistics, on every scrape. Hosts
+   stay listed with goscan_host_up 0 once they stop answering, so that
+   alerts can fire on them, but are left out while their probes fail.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goscan/networkutils"
+	"goscan/stats"
+)
+
+// ContentType is the MIME type of the exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ScanDurationBuckets are the upper bounds, in seconds, of the scan
+// duration histogram.
+var ScanDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Collector gathers scan results for exposition.
+type Collector struct {
+	mu         sync.Mutex
+	interfaces map[string]*interfaceState
+}
+
+type interfaceState struct {
+	report    networkutils.InterfaceReport
+	scannedAt time.Time
+	// known holds every address seen up on the interface
+	known    map[string]bool
+	duration *histogram
+}
+
+// NewCollector returns an empty collector.
+func NewCollector() *Collector {
+	return &Collector{interfaces: make(map[string]*interfaceState)}
+}
+
+// Observe records the results of a scan.
+func (c *Collector) Observe(report *networkutils.ScanReport) {
+	scannedAt := report.StartedAt.Add(report.Elapsed)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, iface := range report.Interfaces {
+		name := iface.QualifiedName()
+		state, ok := c.interfaces[name]
+		if !ok {
+			state = &interfaceState{known: make(map[string]bool), duration: newHistogram(ScanDurationBuckets)}
+			c.interfaces[name] = state
+		}
+		state.report = iface
+		state.scannedAt = scannedAt
+		for _, host := range iface.ActiveHosts {
+			state.known[host.IP.String()] = true
+		}
+		elapsed := iface.Elapsed
+		if elapsed == 0 {
+			elapsed = report.Elapsed
+		}
+		state.duration.observe(elapsed.Seconds())
+	}
+}
+
+// Write renders every metric.
+func (c *Collector) Write(w io.Writer) error {
+	e := &encoder{w: w}
+
+	c.mu.Lock()
+	names := make([]string, 0, len(c.interfaces))
+	for name := range c.interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	e.family("goscan_interface_hosts_alive", "gauge", "Hosts that answered the latest scan of the interface.")
+	for _, name := range names {
+		e.sample("goscan_interface_hosts_alive", labels{"interface", name}, float64(len(c.interfaces[name].report.ActiveHosts)))
+	}
+	e.family("goscan_interface_ips_scanned", "gauge", "Addresses probed by the latest scan of the interface.")
+	for _, name := range names {
+		e.sample("goscan_interface_ips_scanned", labels{"interface", name}, float64(c.interfaces[name].report.TotalIPsScanned))
+	}
+	e.family("goscan_interface_utilization_ratio", "gauge", "Share of the scanned addresses in use.")
+	for _, name := range names {
+		r := c.interfaces[name].report
+		ratio := 0.0
+		if r.TotalIPsScanned > 0 {
+			ratio = float64(len(r.ActiveHosts)) / float64(r.TotalIPsScanned)
+		}
+		e.sample("goscan_interface_utilization_ratio", labels{"interface", name}, ratio)
+	}
+	e.family("goscan_interface_scan_errors", "gauge", "Probes of the latest scan that could not be carried out.")
+	for _, name := range names {
+		count := 0
+		for _, summary := range c.interfaces[name].report.Errors {
+			count += summary.Count
+		}
+		e.sample("goscan_interface_scan_errors", labels{"interface", name}, float64(count))
+	}
+	e.family("goscan_interface_last_scan_timestamp_seconds", "gauge", "Time the latest scan of the interface finished.")
+	for _, name := range names {
+		e.sample("goscan_interface_last_scan_timestamp_seconds", labels{"interface", name}, float64(c.interfaces[name].scannedAt.Unix()))
+	}
+
+	e.family("goscan_host_up", "gauge", "Whether the host answered the latest scan of its interface.")
+	for _, name := range names {
+		state := c.interfaces[name]
+		active := make(map[string]bool, len(state.report.ActiveHosts))
+		for _, host := range state.report.ActiveHosts {
+			active[host.IP.String()] = true
+		}
+		for _, ip := range sortedIPs(state.known) {
+			// A failed probe tells nothing of the host
+			if state.report.ProbeFailed(net.ParseIP(ip)) {
+				continue
+			}
+			up := 0.0
+			if active[ip] {
+				up = 1
+			}
+			e.sample("goscan_host_up", labels{"interface", name, "ip", ip}, up)
+		}
+	}
+	e.family("goscan_host_rtt_seconds", "gauge", "Round-trip time of the probe the host answered in the latest scan.")
+	for _, name := range names {
+		for _, host := range c.interfaces[name].report.ActiveHosts {
+			if host.RTT > 0 {
+				e.sample("goscan_host_rtt_seconds", labels{"interface", name, "ip", host.IP.String(), "method", host.Method}, host.RTT.Seconds())
+			}
+		}
+	}
+
+	e.family("goscan_scan_duration_seconds", "histogram", "Duration of interface scans.")
+	for _, name := range names {
+		e.histogram("goscan_scan_duration_seconds", labels{"interface", name}, c.interfaces[name].duration)
+	}
+	c.mu.Unlock()
+
+	e.family("goscan_probes_total", "counter", "Host probes by method and result (reply, no_reply, error).")
+	for _, p := range networkutils.ProbeCounts() {
+		e.sample("goscan_probes_total", labels{"method", p.Method, "result", p.Result}, float64(p.Count))
+	}
+
+	s := stats.GetStats()
+	e.family("goscan_memory_alloc_bytes", "gauge", "Bytes of allocated heap objects.")
+	e.sample("goscan_memory_alloc_bytes", nil, float64(s.MemAlloc))
+	e.family("goscan_memory_sys_bytes", "gauge", "Bytes of memory obtained from the system.")
+	e.sample("goscan_memory_sys_bytes", nil, float64(s.Sys))
+	e.family("goscan_gc_pause_seconds_total", "counter", "Total time spent in garbage collection pauses.")
+	e.sample("goscan_gc_pause_seconds_total", nil, float64(s.LastPauseNs)/1e9)
+	e.family("goscan_goroutines", "gauge", "Number of goroutines.")
+	e.sample("goscan_goroutines", nil, float64(s.NumGoroutine))
+
+	return e.err
+}
+
+func sortedIPs(set map[string]bool) []string {
+	ips := make([]string, 0, len(set))
+	for ip := range set {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(ips[i]).To16(), net.ParseIP(ips[j]).To16()) < 0
+	})
+	return ips
+}
+
+// histogram is a cumulative histogram with fixed buckets.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// labels are alternating names and values.
+type labels []string
+
+// labelEscape escapes label values as the exposition format requires.
+var labelEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (l labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(l); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, l[i], labelEscape.Replace(l[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// encoder writes metric families, keeping the first write error.
+type encoder struct {
+	w   io.Writer
+	err error
+}
+
+func (e *encoder) printf(format string, args ...any) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+func (e *encoder) family(name, kind, help string) {
+	e.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (e *encoder) sample(name string, l labels, value float64) {
+	e.printf("%s%s %s\n", name, l, formatValue(value))
+}
+
+func (e *encoder) histogram(name string, l labels, h *histogram) {
+	for i, bound := range h.bounds {
+		e.sample(name+"_bucket", append(l[:len(l):len(l)], "le", formatValue(bound)), float64(h.counts[i]))
+	}
+	e.sample(name+"_bucket", append(l[:len(l):len(l)], "le", "+Inf"), float64(h.count))
+	e.sample(name+"_sum", l, h.sum)
+	e.sample(name+"_count", l, float64(h.count))
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	case v == math.Trunc(v) && math.Abs(v) < 1e15:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Exposition of scan results.
+*/
+
+package metrics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"goscan/networkutils"
+)
+
+func host(ip string, rtt time.Duration) networkutils.HostResult {
+	return networkutils.HostResult{IP: net.ParseIP(ip), Method: networkutils.MethodICMP, RTT: rtt}
+}
+
+func scan(elapsed time.Duration, hosts ...networkutils.HostResult) *networkutils.ScanReport {
+	return &networkutils.ScanReport{
+		StartedAt: time.Unix(1700000000, 0),
+		Elapsed:   elapsed,
+		Interfaces: []networkutils.InterfaceReport{{
+			Name:            "eth0",
+			TotalIPsScanned: 4,
+			ActiveHosts:     hosts,
+			Errors:          []networkutils.ErrorSummary{{Kind: networkutils.ErrKindPermission, Count: 2}},
+		}},
+	}
+}
+
+// unprobed marks addresses of a scan as not probed.
+func unprobed(report *networkutils.ScanReport, ips ...string) *networkutils.ScanReport {
+	for _, ip := range ips {
+		report.Interfaces[0].Unprobed = append(report.Interfaces[0].Unprobed, net.ParseIP(ip))
+	}
+	return report
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		scans  []*networkutils.ScanReport
+		want   []string
+		absent []string
+	}{
+		{
+			name:  "one scan",
+			scans: []*networkutils.ScanReport{scan(2*time.Second, host("192.0.2.10", 1500*time.Microsecond), host("192.0.2.2", 0))},
+			want: []string{
+				"# TYPE goscan_interface_hosts_alive gauge",
+				`goscan_interface_hosts_alive{interface="eth0"} 2`,
+				`goscan_interface_ips_scanned{interface="eth0"} 4`,
+				`goscan_interface_utilization_ratio{interface="eth0"} 0.5`,
+				`goscan_interface_scan_errors{interface="eth0"} 2`,
+				`goscan_interface_last_scan_timestamp_seconds{interface="eth0"} 1700000002`,
+				`goscan_host_up{interface="eth0",ip="192.0.2.2"} 1` + "\n" + `goscan_host_up{interface="eth0",ip="192.0.2.10"} 1`,
+				`goscan_host_rtt_seconds{interface="eth0",ip="192.0.2.10",method="icmp"} 0.0015`,
+				`goscan_scan_duration_seconds_bucket{interface="eth0",le="1"} 0`,
+				`goscan_scan_duration_seconds_bucket{interface="eth0",le="2.5"} 1`,
+				`goscan_scan_duration_seconds_bucket{interface="eth0",le="+Inf"} 1`,
+				`goscan_scan_duration_seconds_sum{interface="eth0"} 2`,
+				`goscan_scan_duration_seconds_count{interface="eth0"} 1`,
+				"# TYPE goscan_probes_total counter",
+				"goscan_goroutines ",
+			},
+		},
+		{
+			name: "host gone",
+			scans: []*networkutils.ScanReport{
+				scan(time.Second, host("192.0.2.2", 0), host("192.0.2.3", 0)),
+				scan(200*time.Millisecond, host("192.0.2.3", 0)),
+			},
+			want: []string{
+				`goscan_interface_hosts_alive{interface="eth0"} 1`,
+				`goscan_host_up{interface="eth0",ip="192.0.2.2"} 0`,
+				`goscan_host_up{interface="eth0",ip="192.0.2.3"} 1`,
+				`goscan_scan_duration_seconds_bucket{interface="eth0",le="0.25"} 1`,
+				`goscan_scan_duration_seconds_bucket{interface="eth0",le="1"} 2`,
+				`goscan_scan_duration_seconds_count{interface="eth0"} 2`,
+			},
+		},
+		{
+			name: "probe failed",
+			scans: []*networkutils.ScanReport{
+				scan(time.Second, host("192.0.2.2", 0), host("192.0.2.3", 0)),
+				unprobed(scan(time.Second, host("192.0.2.3", 0)), "192.0.2.2"),
+			},
+			want: []string{
+				`goscan_host_up{interface="eth0",ip="192.0.2.3"} 1`,
+			},
+			absent: []string{
+				`ip="192.0.2.2"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector()
+			for _, report := range tt.scans {
+				c.Observe(report)
+			}
+
+			var b strings.Builder
+			if err := c.Write(&b); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(b.String(), want) {
+					t.Errorf("exposition lacks %q:\n%s", want, b.String())
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(b.String(), absent) {
+					t.Errorf("exposition has %q:\n%s", absent, b.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLabelsEscape(t *testing.T) {
+	got := labels{"interface", "a\"b\\c\nd"}.String()
+	if want := `{interface="a\"b\\c\nd"}`; got != want {
+		t.Errorf("labels = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Comparison of two scan reports: hosts that appeared, disappeared, or
+   answered with a different MAC address or hostname.
+*/
+
+package networkutils
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"time"
+)
+
+// HostChange describes one host in a ReportDiff. The Old fields are only set
+// for changed hosts.
+type HostChange struct {
+	Interface string   `json:"interface"`
+	IP        string   `json:"ip"`
+	MAC       string   `json:"mac,omitempty"`
+	Names     []string `json:"names,omitempty"`
+	OldMAC    string   `json:"oldMac,omitempty"`
+	OldNames  []string `json:"oldNames,omitempty"`
+
+	sortIP []byte
+}
+
+// ReportDiff lists the differences between two scan reports.
+type ReportDiff struct {
+	From        time.Time    `json:"from"`
+	To          time.Time    `json:"to"`
+	Appeared    []HostChange `json:"appeared"`
+	Disappeared []HostChange `json:"disappeared"`
+	MACChanged  []HostChange `json:"macChanged"`
+	NameChanged []HostChange `json:"nameChanged"`
+}
+
+// Empty reports whether the two scans found the same hosts.
+func (d *ReportDiff) Empty() bool {
+	return len(d.Appeared) == 0 && len(d.Disappeared) == 0 &&
+		len(d.MACChanged) == 0 && len(d.NameChanged) == 0
+}
+
+// DiffReports compares an older scan report with a newer one. Hosts are
+// matched by interface and IP address. MAC changes are only reported when
+// both scans learned a MAC, as ICMP replies do not carry one. A host whose
+// probes failed in the newer scan has not disappeared.
+func DiffReports(before, after *ScanReport) *ReportDiff {
+	diff := &ReportDiff{
+		From:        before.StartedAt,
+		To:          after.StartedAt,
+		Appeared:    []HostChange{},
+		Disappeared: []HostChange{},
+		MACChanged:  []HostChange{},
+		NameChanged: []HostChange{},
+	}
+
+	oldHosts := indexHosts(before)
+	newHosts := indexHosts(after)
+
+	for key, n := range newHosts {
+		o, ok := oldHosts[key]
+		if !ok {
+			diff.Appeared = append(diff.Appeared, n)
+			continue
+		}
+		if o.MAC != "" && n.MAC != "" && o.MAC != n.MAC {
+			change := n
+			change.OldMAC = o.MAC
+			diff.MACChanged = append(diff.MACChanged, change)
+		}
+		if !sameNames(o.Names, n.Names) {
+			change := n
+			change.OldNames = o.Names
+			diff.NameChanged = append(diff.NameChanged, change)
+		}
+	}
+	for key, o := range oldHosts {
+		if _, ok := newHosts[key]; ok {
+			continue
+		}
+		if iface, ok := after.Interface(o.Interface); ok && iface.ProbeFailed(net.ParseIP(o.IP)) {
+			continue
+		}
+		diff.Disappeared = append(diff.Disappeared, o)
+	}
+
+	for _, changes := range [][]HostChange{diff.Appeared, diff.Disappeared, diff.MACChanged, diff.NameChanged} {
+		sortChanges(changes)
+	}
+	return diff
+}
+
+func indexHosts(report *ScanReport) map[string]HostChange {
+	hosts := make(map[string]HostChange)
+	for _, iface := range report.Interfaces {
+		name := iface.QualifiedName()
+		for _, host := range iface.ActiveHosts {
+			hosts[name+"|"+host.IP.String()] = HostChange{
+				Interface: name,
+				IP:        host.IP.String(),
+				MAC:       host.MAC,
+				Names:     host.Names,
+				sortIP:    host.IP.To16(),
+			}
+		}
+	}
+	return hosts
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sortChanges orders changes by interface, then address.
+func sortChanges(changes []HostChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Interface != changes[j].Interface {
+			return changes[i].Interface < changes[j].Interface
+		}
+		return bytes.Compare(changes[i].sortIP, changes[j].sortIP) < 0
+	})
+}
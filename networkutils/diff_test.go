@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Scan report comparison.
+*/
+
+package networkutils
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// diffHost is a host of a diff test report: interface, address, MAC and
+// names.
+type diffHost struct {
+	iface string
+	ip    string
+	mac   string
+	names []string
+}
+
+func diffReport(started time.Time, hosts []diffHost, unprobed map[string][]string) *ScanReport {
+	report := &ScanReport{Version: ReportVersion, StartedAt: started}
+	index := make(map[string]int)
+	addIface := func(name string) int {
+		i, ok := index[name]
+		if !ok {
+			i = len(report.Interfaces)
+			index[name] = i
+			report.Interfaces = append(report.Interfaces, InterfaceReport{Name: name, ActiveHosts: []HostResult{}})
+		}
+		return i
+	}
+	for _, h := range hosts {
+		i := addIface(h.iface)
+		report.Interfaces[i].ActiveHosts = append(report.Interfaces[i].ActiveHosts, HostResult{
+			IP:    net.ParseIP(h.ip),
+			MAC:   h.mac,
+			Names: h.names,
+		})
+	}
+	for name, ips := range unprobed {
+		i := addIface(name)
+		for _, ip := range ips {
+			report.Interfaces[i].Unprobed = append(report.Interfaces[i].Unprobed, net.ParseIP(ip))
+		}
+	}
+	return report
+}
+
+// changeKeys lists the interface and address of each change, in order.
+func changeKeys(changes []HostChange) []string {
+	keys := []string{}
+	for _, c := range changes {
+		keys = append(keys, c.Interface+" "+c.IP)
+	}
+	return keys
+}
+
+func TestDiffReports(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		before      []diffHost
+		after       []diffHost
+		unprobed    map[string][]string
+		appeared    []string
+		disappeared []string
+		macChanged  []string
+		nameChanged []string
+	}{
+		{
+			name:   "identical",
+			before: []diffHost{{"eth0", "10.0.0.1", "aa:aa:aa:aa:aa:01", []string{"gw"}}},
+			after:  []diffHost{{"eth0", "10.0.0.1", "aa:aa:aa:aa:aa:01", []string{"gw"}}},
+		},
+		{
+			name: "appeared and disappeared in address order",
+			before: []diffHost{
+				{"eth0", "10.0.0.1", "", nil},
+				{"eth0", "10.0.0.20", "", nil},
+				{"eth0", "10.0.0.3", "", nil},
+			},
+			after: []diffHost{
+				{"eth0", "10.0.0.1", "", nil},
+				{"eth0", "10.0.0.100", "", nil},
+				{"eth0", "10.0.0.9", "", nil},
+			},
+			appeared:    []string{"eth0 10.0.0.9", "eth0 10.0.0.100"},
+			disappeared: []string{"eth0 10.0.0.3", "eth0 10.0.0.20"},
+		},
+		{
+			name:        "hosts are matched by interface",
+			before:      []diffHost{{"eth0", "10.0.0.1", "", nil}},
+			after:       []diffHost{{"eth1", "10.0.0.1", "", nil}},
+			appeared:    []string{"eth1 10.0.0.1"},
+			disappeared: []string{"eth0 10.0.0.1"},
+		},
+		{
+			name:       "mac changed",
+			before:     []diffHost{{"eth0", "10.0.0.1", "aa:aa:aa:aa:aa:01", nil}},
+			after:      []diffHost{{"eth0", "10.0.0.1", "aa:aa:aa:aa:aa:02", nil}},
+			macChanged: []string{"eth0 10.0.0.1"},
+		},
+		{
+			// An ICMP reply carries no MAC
+			name:   "mac learned by one scan only",
+			before: []diffHost{{"eth0", "10.0.0.1", "aa:aa:aa:aa:aa:01", nil}},
+			after:  []diffHost{{"eth0", "10.0.0.1", "", nil}},
+		},
+		{
+			name:        "names changed",
+			before:      []diffHost{{"eth0", "10.0.0.1", "", []string{"a"}}},
+			after:       []diffHost{{"eth0", "10.0.0.1", "", []string{"a", "b"}}},
+			nameChanged: []string{"eth0 10.0.0.1"},
+		},
+		{
+			name:   "names in another order",
+			before: []diffHost{{"eth0", "10.0.0.1", "", []string{"b", "a"}}},
+			after:  []diffHost{{"eth0", "10.0.0.1", "", []string{"a", "b"}}},
+		},
+		{
+			name:        "failed probes are not disappearances",
+			before:      []diffHost{{"eth0", "10.0.0.1", "", nil}, {"eth0", "10.0.0.2", "", nil}},
+			after:       []diffHost{},
+			unprobed:    map[string][]string{"eth0": {"10.0.0.2"}},
+			disappeared: []string{"eth0 10.0.0.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffReports(diffReport(t0, tt.before, nil), diffReport(t1, tt.after, tt.unprobed))
+
+			if !diff.From.Equal(t0) || !diff.To.Equal(t1) {
+				t.Errorf("diff spans %s to %s, want %s to %s", diff.From, diff.To, t0, t1)
+			}
+			for _, c := range []struct {
+				kind string
+				got  []HostChange
+				want []string
+			}{
+				{"appeared", diff.Appeared, tt.appeared},
+				{"disappeared", diff.Disappeared, tt.disappeared},
+				{"macChanged", diff.MACChanged, tt.macChanged},
+				{"nameChanged", diff.NameChanged, tt.nameChanged},
+			} {
+				want := c.want
+				if want == nil {
+					want = []string{}
+				}
+				if got := changeKeys(c.got); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %v, want %v", c.kind, got, want)
+				}
+			}
+			if empty := tt.appeared == nil && tt.disappeared == nil && tt.macChanged == nil && tt.nameChanged == nil; diff.Empty() != empty {
+				t.Errorf("Empty() = %v, want %v", diff.Empty(), empty)
+			}
+		})
+	}
+}
+
+func TestDiffReportsOldValues(t *testing.T) {
+	t0 := time.Now()
+	before := diffReport(t0, []diffHost{{"eth0", "10.0.0.1", "aa:aa:aa:aa:aa:01", []string{"old"}}}, nil)
+	after := diffReport(t0, []diffHost{{"eth0", "10.0.0.1", "aa:aa:aa:aa:aa:02", []string{"new"}}}, nil)
+
+	diff := DiffReports(before, after)
+
+	if len(diff.MACChanged) != 1 || diff.MACChanged[0].MAC != "aa:aa:aa:aa:aa:02" || diff.MACChanged[0].OldMAC != "aa:aa:aa:aa:aa:01" {
+		t.Errorf("macChanged = %+v", diff.MACChanged)
+	}
+	if len(diff.NameChanged) != 1 || !reflect.DeepEqual(diff.NameChanged[0].Names, []string{"new"}) || !reflect.DeepEqual(diff.NameChanged[0].OldNames, []string{"old"}) {
+		t.Errorf("nameChanged = %+v", diff.NameChanged)
+	}
+	if diff.MACChanged[0].OldNames != nil || diff.NameChanged[0].OldMAC != "" {
+		t.Error("a change carries the old value of another kind of change")
+	}
+}
@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Scan error taxonomy.
+
+   A host that does not answer is not an error. A probe that could not be
+   sent or received (no privileges, no sockets left, interface down) is, and
+   must be reported so that a broken scan does not look like an empty network.
+*/
+
+package networkutils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// ErrorKind classifies why a probe could not be carried out.
+type ErrorKind string
+
+const (
+	ErrKindPermission        ErrorKind = "permission"
+	ErrKindResourceExhausted ErrorKind = "resource_exhausted"
+	ErrKindInterfaceDown     ErrorKind = "interface_down"
+	ErrKindTimeout           ErrorKind = "timeout"
+	ErrKindUnknown           ErrorKind = "unknown"
+)
+
+// Sentinel errors matching each kind, for use with errors.Is.
+var (
+	ErrPermission        = errors.New("permission denied")
+	ErrResourceExhausted = errors.New("resource exhausted")
+	ErrInterfaceDown     = errors.New("interface down")
+	ErrTimeout           = errors.New("timeout")
+)
+
+var kindSentinels = map[ErrorKind]error{
+	ErrKindPermission:        ErrPermission,
+	ErrKindResourceExhausted: ErrResourceExhausted,
+	ErrKindInterfaceDown:     ErrInterfaceDown,
+	ErrKindTimeout:           ErrTimeout,
+}
+
+// ScanError describes a single probe or interface that could not be scanned.
+type ScanError struct {
+	Kind      ErrorKind
+	Interface string
+	Host      net.IP // nil for interface-level errors
+	Method    string // "arp", "icmp", "tcp" or empty for interface-level errors
+	Err       error
+}
+
+func (e *ScanError) Error() string {
+	var b strings.Builder
+	if e.Interface != "" {
+		b.WriteString(e.Interface)
+		b.WriteString(": ")
+	}
+	if e.Method != "" {
+		b.WriteString(e.Method)
+		b.WriteString(" ")
+	}
+	if e.Host != nil {
+		b.WriteString(e.Host.String())
+		b.WriteString(": ")
+	}
+	b.WriteString(string(e.Kind))
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel error for this error's kind.
+func (e *ScanError) Is(target error) bool {
+	sentinel, ok := kindSentinels[e.Kind]
+	return ok && sentinel == target
+}
+
+// newScanError wraps err with its classified kind.
+func newScanError(method string, host net.IP, err error) *ScanError {
+	return &ScanError{
+		Kind:   classifyError(err),
+		Host:   host,
+		Method: method,
+		Err:    err,
+	}
+}
+
+// classifyError maps low-level socket errors onto an ErrorKind.
+func classifyError(err error) ErrorKind {
+	var scanErr *ScanError
+	if errors.As(err, &scanErr) {
+		return scanErr.Kind
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		switch errno {
+		case syscall.EPERM, syscall.EACCES:
+			return ErrKindPermission
+		case syscall.EMFILE, syscall.ENFILE, syscall.ENOBUFS, syscall.ENOMEM:
+			return ErrKindResourceExhausted
+		case syscall.ENETDOWN, syscall.ENODEV, syscall.ENXIO:
+			return ErrKindInterfaceDown
+		case syscall.ETIMEDOUT, syscall.EAGAIN:
+			return ErrKindTimeout
+		}
+	}
+
+	if errors.Is(err, os.ErrPermission) {
+		return ErrKindPermission
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return ErrKindTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrKindTimeout
+	}
+	return ErrKindUnknown
+}
+
+// ErrorSummary aggregates scan errors of one kind.
+type ErrorSummary struct {
+	Kind    ErrorKind `json:"kind"`
+	Count   int       `json:"count"`
+	Message string    `json:"message"`
+}
+
+// InterfaceErrors collects the scan errors for one interface. It is returned
+// by ProbeHosts alongside whatever results could still be gathered.
+type InterfaceErrors struct {
+	Interface string
+	Errors    []*ScanError
+}
+
+func (e *InterfaceErrors) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, s := range e.Summary() {
+		parts = append(parts, fmt.Sprintf("%s (%d): %s", s.Kind, s.Count, s.Message))
+	}
+	return fmt.Sprintf("%s: %d probe(s) failed: %s", e.Interface, len(e.Errors), strings.Join(parts, "; "))
+}
+
+func (e *InterfaceErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, scanErr := range e.Errors {
+		errs[i] = scanErr
+	}
+	return errs
+}
+
+// Summary groups the errors by kind, most frequent first. The message of the
+// first error of each kind is kept as a representative example.
+func (e *InterfaceErrors) Summary() []ErrorSummary {
+	byKind := make(map[ErrorKind]*ErrorSummary)
+	var order []ErrorKind
+	for _, scanErr := range e.Errors {
+		s, ok := byKind[scanErr.Kind]
+		if !ok {
+			s = &ErrorSummary{Kind: scanErr.Kind, Message: scanErr.Error()}
+			byKind[scanErr.Kind] = s
+			order = append(order, scanErr.Kind)
+		}
+		s.Count++
+	}
+
+	summary := make([]ErrorSummary, 0, len(order))
+	for _, kind := range order {
+		summary = append(summary, *byKind[kind])
+	}
+	sort.SliceStable(summary, func(i, j int) bool {
+		return summary[i].Count > summary[j].Count
+	})
+	return summary
+}
+
+// SummarizeError turns any error returned by ProbeHosts into error summaries
+// suitable for display or JSON output.
+func SummarizeError(err error) []ErrorSummary {
+	if err == nil {
+		return nil
+	}
+	var ifaceErrs *InterfaceErrors
+	if errors.As(err, &ifaceErrs) {
+		return ifaceErrs.Summary()
+	}
+	return []ErrorSummary{{Kind: classifyError(err), Count: 1, Message: err.Error()}}
+}
@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Free address blocks: runs of consecutive addresses of a scanned subnet
+   that did not answer, hold no valid DHCP lease and are not reserved.
+*/
+
+package networkutils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net"
+	"sort"
+	"strings"
+)
+
+// AddressRange is an inclusive range of IPv4 addresses.
+type AddressRange struct {
+	First net.IP `json:"first"`
+	Last  net.IP `json:"last"`
+}
+
+// ParseAddressRange reads an address ("192.168.1.10"), a range
+// ("192.168.1.100-192.168.1.199") or a CIDR block ("192.168.1.128/26").
+func ParseAddressRange(s string) (AddressRange, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil || ipnet.IP.To4() == nil {
+			return AddressRange{}, fmt.Errorf("invalid IPv4 network %q", s)
+		}
+		first := ipToUint32(ipnet.IP.To4())
+		ones, _ := ipnet.Mask.Size()
+		last := first | uint32(1<<(32-ones)-1)
+		return AddressRange{First: uint32ToIP(first), Last: uint32ToIP(last)}, nil
+	}
+
+	firstStr, lastStr, isRange := strings.Cut(s, "-")
+	if !isRange {
+		lastStr = firstStr
+	}
+	first := net.ParseIP(strings.TrimSpace(firstStr)).To4()
+	last := net.ParseIP(strings.TrimSpace(lastStr)).To4()
+	if first == nil || last == nil {
+		return AddressRange{}, fmt.Errorf("invalid IPv4 address range %q", s)
+	}
+	if ipToUint32(first) > ipToUint32(last) {
+		return AddressRange{}, fmt.Errorf("invalid IPv4 address range %q: first address after last", s)
+	}
+	return AddressRange{First: first, Last: last}, nil
+}
+
+// ParseAddressRanges parses several ranges.
+func ParseAddressRanges(specs []string) ([]AddressRange, error) {
+	ranges := make([]AddressRange, 0, len(specs))
+	for _, spec := range specs {
+		r, err := ParseAddressRange(spec)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// Contains reports whether ip is in the range.
+func (r AddressRange) Contains(ip net.IP) bool {
+	v4 := ip.To4()
+	if v4 == nil {
+		return false
+	}
+	n := ipToUint32(v4)
+	return n >= ipToUint32(r.First.To4()) && n <= ipToUint32(r.Last.To4())
+}
+
+func (r AddressRange) String() string {
+	if r.First.Equal(r.Last) {
+		return r.First.String()
+	}
+	return r.First.String() + "-" + r.Last.String()
+}
+
+// FreeBlock is a run of consecutive free addresses.
+type FreeBlock struct {
+	Interface string `json:"interface"`
+	First     net.IP `json:"first"`
+	Last      net.IP `json:"last"`
+	Size      int    `json:"size"`
+	// CIDR is set for aligned blocks.
+	CIDR string `json:"cidr,omitempty"`
+}
+
+// BlockOptions select the free blocks to list.
+type BlockOptions struct {
+	// Size is the least number of consecutive addresses, 1 by default.
+	Size int
+	// Aligned lists blocks of exactly Size addresses, a power of two,
+	// starting at a multiple of Size, so that each is a CIDR block.
+	Aligned bool
+	// Reserved are never free: DHCP pools, static reservations.
+	Reserved []AddressRange
+}
+
+// ValidateBlockSize checks that a block size is possible.
+func ValidateBlockSize(size int, aligned bool) error {
+	if size < 1 {
+		return fmt.Errorf("invalid block size %d (expected at least 1)", size)
+	}
+	if aligned && bits.OnesCount(uint(size)) != 1 {
+		return fmt.Errorf("invalid aligned block size %d (expected a power of two)", size)
+	}
+	return nil
+}
+
+// FreeBlocks lists the free blocks of the scanned subnets. Addresses that
+// answered, that hold a valid lease not seen answering, or that are
+// reserved are in use. Addresses whose probes failed are never free.
+func (r *InterfaceReport) FreeBlocks(opts BlockOptions) []FreeBlock {
+	if opts.Size < 1 {
+		opts.Size = 1
+	}
+	used := make(map[uint32]bool, len(r.ActiveHosts))
+	for _, host := range r.ActiveHosts {
+		if v4 := host.IP.To4(); v4 != nil {
+			used[ipToUint32(v4)] = true
+		}
+	}
+	if r.LeaseCheck != nil {
+		for _, lease := range r.LeaseCheck.LeasedInactive {
+			if v4 := lease.IP.To4(); v4 != nil {
+				used[ipToUint32(v4)] = true
+			}
+		}
+	}
+
+	var free []uint32
+	for _, ip := range r.scannedIPs() {
+		v4 := ip.To4()
+		if v4 == nil || used[ipToUint32(v4)] || reserved(opts.Reserved, v4) {
+			continue
+		}
+		free = append(free, ipToUint32(v4))
+	}
+	sort.Slice(free, func(i, j int) bool { return free[i] < free[j] })
+
+	var blocks []FreeBlock
+	for start := 0; start < len(free); {
+		end := start
+		for end+1 < len(free) && free[end+1] == free[end]+1 {
+			end++
+		}
+		blocks = append(blocks, r.blocksIn(free[start], free[end], opts)...)
+		start = end + 1
+	}
+	return blocks
+}
+
+// blocksIn returns the blocks of a run of free addresses.
+func (r *InterfaceReport) blocksIn(first, last uint32, opts BlockOptions) []FreeBlock {
+	name := r.QualifiedName()
+	length := int(last-first) + 1
+	if !opts.Aligned {
+		if length < opts.Size {
+			return nil
+		}
+		return []FreeBlock{{Interface: name, First: uint32ToIP(first), Last: uint32ToIP(last), Size: length}}
+	}
+
+	size := uint32(opts.Size)
+	prefix := 32 - bits.TrailingZeros32(size)
+	var blocks []FreeBlock
+	// Round the start up to the next multiple of size; uint64 so that the
+	// last block of the address space does not wrap
+	for start := (uint64(first) + uint64(size) - 1) / uint64(size) * uint64(size); start+uint64(size)-1 <= uint64(last); start += uint64(size) {
+		ip := uint32ToIP(uint32(start))
+		blocks = append(blocks, FreeBlock{
+			Interface: name,
+			First:     ip,
+			Last:      uint32ToIP(uint32(start) + size - 1),
+			Size:      opts.Size,
+			CIDR:      fmt.Sprintf("%s/%d", ip, prefix),
+		})
+	}
+	return blocks
+}
+
+// FreeAddresses lists the free addresses of the scanned subnets in order.
+func (r *InterfaceReport) FreeAddresses(reserved []AddressRange) []net.IP {
+	var ips []net.IP
+	for _, b := range r.FreeBlocks(BlockOptions{Reserved: reserved}) {
+		for n := uint64(ipToUint32(b.First)); n <= uint64(ipToUint32(b.Last)); n++ {
+			ips = append(ips, uint32ToIP(uint32(n)))
+		}
+	}
+	return ips
+}
+
+// FreeBlocks lists the free blocks of every interface of the report.
+func (r *ScanReport) FreeBlocks(opts BlockOptions) []FreeBlock {
+	blocks := []FreeBlock{}
+	for i := range r.Interfaces {
+		blocks = append(blocks, r.Interfaces[i].FreeBlocks(opts)...)
+	}
+	return blocks
+}
+
+func reserved(ranges []AddressRange, ip net.IP) bool {
+	for _, r := range ranges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
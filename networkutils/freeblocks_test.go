@@ -0,0 +1,285 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Free address blocks and their alignment.
+*/
+
+package networkutils
+
+import (
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// blockStrings lists blocks as "first-last/size", with the CIDR of the
+// aligned ones.
+func blockStrings(blocks []FreeBlock) []string {
+	s := []string{}
+	for _, b := range blocks {
+		str := b.First.String() + "-" + b.Last.String() + "/" + strconv.Itoa(b.Size)
+		if b.CIDR != "" {
+			str += " " + b.CIDR
+		}
+		s = append(s, str)
+	}
+	return s
+}
+
+func mustRanges(t *testing.T, specs ...string) []AddressRange {
+	t.Helper()
+	ranges, err := ParseAddressRanges(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ranges
+}
+
+func TestFreeBlocks(t *testing.T) {
+	// 10.0.0.1-30, of which .1, .5 and .20 answered: free runs are .2-4,
+	// .6-19 and .21-30
+	base := func() InterfaceReport {
+		return InterfaceReport{
+			Name:            "eth0",
+			Addresses:       []string{"10.0.0.1/27"},
+			TotalIPsScanned: 30,
+			ActiveHosts: []HostResult{
+				{IP: net.ParseIP("10.0.0.1")},
+				{IP: net.ParseIP("10.0.0.5")},
+				{IP: net.ParseIP("10.0.0.20")},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *InterfaceReport)
+		opts   BlockOptions
+		want   []string
+	}{
+		{
+			name: "runs",
+			want: []string{"10.0.0.2-10.0.0.4/3", "10.0.0.6-10.0.0.19/14", "10.0.0.21-10.0.0.30/10"},
+		},
+		{
+			name: "minimum size",
+			opts: BlockOptions{Size: 11},
+			want: []string{"10.0.0.6-10.0.0.19/14"},
+		},
+		{
+			name: "aligned /30",
+			opts: BlockOptions{Size: 4, Aligned: true},
+			want: []string{
+				"10.0.0.8-10.0.0.11/4 10.0.0.8/30",
+				"10.0.0.12-10.0.0.15/4 10.0.0.12/30",
+				"10.0.0.16-10.0.0.19/4 10.0.0.16/30",
+				"10.0.0.24-10.0.0.27/4 10.0.0.24/30",
+			},
+		},
+		{
+			name: "aligned /29",
+			opts: BlockOptions{Size: 8, Aligned: true},
+			want: []string{"10.0.0.8-10.0.0.15/8 10.0.0.8/29"},
+		},
+		{
+			name: "aligned /32",
+			opts: BlockOptions{Size: 1, Aligned: true},
+			modify: func(r *InterfaceReport) {
+				r.Addresses = []string{"10.0.0.1/30"}
+			},
+			want: []string{"10.0.0.2-10.0.0.2/1 10.0.0.2/32"},
+		},
+		{
+			name: "reserved",
+			opts: BlockOptions{Reserved: mustRanges(t, "10.0.0.10-10.0.0.12", "10.0.0.28/30")},
+			want: []string{"10.0.0.2-10.0.0.4/3", "10.0.0.6-10.0.0.9/4", "10.0.0.13-10.0.0.19/7", "10.0.0.21-10.0.0.27/7"},
+		},
+		{
+			name: "leased but silent",
+			modify: func(r *InterfaceReport) {
+				r.LeaseCheck = &LeaseCheck{LeasedInactive: []Lease{{IP: net.ParseIP("10.0.0.25")}}}
+			},
+			want: []string{"10.0.0.2-10.0.0.4/3", "10.0.0.6-10.0.0.19/14", "10.0.0.21-10.0.0.24/4", "10.0.0.26-10.0.0.30/5"},
+		},
+		{
+			name: "unprobed",
+			modify: func(r *InterfaceReport) {
+				r.Unprobed = []net.IP{net.ParseIP("10.0.0.3"), net.ParseIP("10.0.0.15")}
+				r.TotalIPsScanned = 28
+			},
+			want: []string{"10.0.0.2-10.0.0.2/1", "10.0.0.4-10.0.0.4/1", "10.0.0.6-10.0.0.14/9", "10.0.0.16-10.0.0.19/4", "10.0.0.21-10.0.0.30/10"},
+		},
+		{
+			name: "unprobed breaks alignment",
+			modify: func(r *InterfaceReport) {
+				r.Unprobed = []net.IP{net.ParseIP("10.0.0.15")}
+				r.TotalIPsScanned = 29
+			},
+			opts: BlockOptions{Size: 8, Aligned: true},
+			want: []string{},
+		},
+		{
+			name: "scanned list",
+			modify: func(r *InterfaceReport) {
+				r.Scanned = []net.IP{net.ParseIP("10.0.0.6"), net.ParseIP("10.0.0.7"), net.ParseIP("10.0.0.5"), net.ParseIP("10.0.0.9")}
+			},
+			want: []string{"10.0.0.6-10.0.0.7/2", "10.0.0.9-10.0.0.9/1"},
+		},
+		{
+			name: "failed scan",
+			modify: func(r *InterfaceReport) {
+				r.ActiveHosts = []HostResult{}
+				r.TotalIPsScanned = 0
+				r.Errors = []ErrorSummary{{Kind: ErrKindPermission, Count: 1}}
+			},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := base()
+			if tt.modify != nil {
+				tt.modify(&r)
+			}
+			blocks := r.FreeBlocks(tt.opts)
+			if got := blockStrings(blocks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FreeBlocks() =\n%v\nwant\n%v", got, tt.want)
+			}
+			for _, b := range blocks {
+				if b.Interface != "eth0" {
+					t.Errorf("block %s-%s on %q, want eth0", b.First, b.Last, b.Interface)
+				}
+			}
+		})
+	}
+}
+
+func TestBlocksIn(t *testing.T) {
+	r := &InterfaceReport{Name: "eth0", Namespace: "blue"}
+	ip := func(s string) uint32 { return ipToUint32(net.ParseIP(s)) }
+
+	tests := []struct {
+		name        string
+		first, last string
+		opts        BlockOptions
+		want        []string
+	}{
+		{
+			name:  "run too short",
+			first: "10.0.0.3",
+			last:  "10.0.0.5",
+			opts:  BlockOptions{Size: 4},
+			want:  []string{},
+		},
+		{
+			name:  "whole run",
+			first: "10.0.0.3",
+			last:  "10.0.0.6",
+			opts:  BlockOptions{Size: 4},
+			want:  []string{"10.0.0.3-10.0.0.6/4"},
+		},
+		{
+			// Four free addresses, but not on a multiple of four
+			name:  "unaligned run",
+			first: "10.0.0.3",
+			last:  "10.0.0.6",
+			opts:  BlockOptions{Size: 4, Aligned: true},
+			want:  []string{},
+		},
+		{
+			name:  "start rounded up",
+			first: "10.0.0.3",
+			last:  "10.0.0.17",
+			opts:  BlockOptions{Size: 4, Aligned: true},
+			want:  []string{"10.0.0.4-10.0.0.7/4 10.0.0.4/30", "10.0.0.8-10.0.0.11/4 10.0.0.8/30", "10.0.0.12-10.0.0.15/4 10.0.0.12/30"},
+		},
+		{
+			name:  "across an octet",
+			first: "10.0.0.200",
+			last:  "10.0.2.10",
+			opts:  BlockOptions{Size: 256, Aligned: true},
+			want:  []string{"10.0.1.0-10.0.1.255/256 10.0.1.0/24"},
+		},
+		{
+			// The next start would wrap around to 0.0.0.0
+			name:  "end of the address space",
+			first: "255.255.255.224",
+			last:  "255.255.255.255",
+			opts:  BlockOptions{Size: 16, Aligned: true},
+			want:  []string{"255.255.255.224-255.255.255.239/16 255.255.255.224/28", "255.255.255.240-255.255.255.255/16 255.255.255.240/28"},
+		},
+		{
+			name:  "start of the address space",
+			first: "0.0.0.0",
+			last:  "0.0.0.2",
+			opts:  BlockOptions{Size: 2, Aligned: true},
+			want:  []string{"0.0.0.0-0.0.0.1/2 0.0.0.0/31"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := r.blocksIn(ip(tt.first), ip(tt.last), tt.opts)
+			if got := blockStrings(blocks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("blocksIn() =\n%v\nwant\n%v", got, tt.want)
+			}
+			for _, b := range blocks {
+				if b.Interface != "blue/eth0" {
+					t.Errorf("block on %q, want blue/eth0", b.Interface)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBlockSize(t *testing.T) {
+	tests := []struct {
+		size    int
+		aligned bool
+		valid   bool
+	}{
+		{1, false, true},
+		{3, false, true},
+		{0, false, false},
+		{-4, true, false},
+		{1, true, true},
+		{64, true, true},
+		{48, true, false},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateBlockSize(tt.size, tt.aligned); (err == nil) != tt.valid {
+			t.Errorf("ValidateBlockSize(%d, %v) = %v, want valid %v", tt.size, tt.aligned, err, tt.valid)
+		}
+	}
+}
+
+func TestParseAddressRange(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"192.168.1.10", "192.168.1.10"},
+		{" 192.168.1.100 - 192.168.1.199 ", "192.168.1.100-192.168.1.199"},
+		{"192.168.1.130/26", "192.168.1.128-192.168.1.191"},
+		{"192.168.1.7/32", "192.168.1.7"},
+		{"192.168.1.199-192.168.1.100", ""},
+		{"fd00::/64", ""},
+		{"192.168.1.300", ""},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseAddressRange(tt.spec)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseAddressRange(%q) = %s, want an error", tt.spec, r)
+			}
+			continue
+		}
+		if err != nil || r.String() != tt.want {
+			t.Errorf("ParseAddressRange(%q) = %s, %v, want %s", tt.spec, r, err, tt.want)
+		}
+	}
+}
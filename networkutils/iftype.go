@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Interface type detection and include/exclude filtering.
+
+   Types are read from sysfs where available (/sys/class/net/<name>) and
+   guessed from well-known name prefixes otherwise.
+*/
+
+package networkutils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"goscan/config"
+)
+
+// Interface types reported in Link.Type and InterfaceDetails.Type.
+const (
+	TypePhysical  = "physical"
+	TypeBridge    = "bridge"
+	TypeVeth      = "veth"
+	TypeTun       = "tun"
+	TypeWireGuard = "wireguard"
+	TypeVLAN      = "vlan"
+	TypeLoopback  = "loopback"
+	TypeOther     = "other"
+)
+
+// InterfaceTypes lists the types accepted by the interface type filter.
+var InterfaceTypes = []string{TypePhysical, TypeBridge, TypeVeth, TypeTun, TypeWireGuard, TypeVLAN, TypeOther}
+
+const sysfsNetRoot = "/sys/class/net"
+
+// detectInterfaceType classifies an interface using sysfs, falling back to
+// its name when sysfs has nothing to say.
+func detectInterfaceType(name string) string {
+	if t := sysfsInterfaceType(sysfsNetRoot, name); t != "" {
+		return t
+	}
+	return guessInterfaceType(name)
+}
+
+func sysfsInterfaceType(root, name string) string {
+	dir := filepath.Join(root, name)
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+
+	switch readUevent(filepath.Join(dir, "uevent"))["DEVTYPE"] {
+	case "bridge":
+		return TypeBridge
+	case "vlan":
+		return TypeVLAN
+	case "wireguard":
+		return TypeWireGuard
+	case "wlan", "wwan":
+		return TypePhysical
+	}
+
+	if exists(filepath.Join(dir, "bridge")) {
+		return TypeBridge
+	}
+	if exists(filepath.Join(dir, "tun_flags")) {
+		return TypeTun
+	}
+	if exists(filepath.Join(dir, "device")) {
+		return TypePhysical
+	}
+	if name == "lo" {
+		return TypeLoopback
+	}
+
+	// A veth reports its peer's index as iflink
+	iflink, _ := os.ReadFile(filepath.Join(dir, "iflink"))
+	ifindex, _ := os.ReadFile(filepath.Join(dir, "ifindex"))
+	if len(iflink) > 0 && strings.TrimSpace(string(iflink)) != strings.TrimSpace(string(ifindex)) {
+		if strings.HasPrefix(name, "veth") || guessInterfaceType(name) == TypeOther {
+			return TypeVeth
+		}
+	}
+	return ""
+}
+
+// guessInterfaceType classifies an interface from its name alone.
+func guessInterfaceType(name string) string {
+	switch {
+	case name == "lo":
+		return TypeLoopback
+	case strings.HasPrefix(name, "veth"), strings.HasPrefix(name, "cali"):
+		return TypeVeth
+	case strings.HasPrefix(name, "docker"), strings.HasPrefix(name, "br"),
+		strings.HasPrefix(name, "virbr"), strings.HasPrefix(name, "cni"),
+		strings.HasPrefix(name, "podman"), strings.HasPrefix(name, "lxcbr"):
+		return TypeBridge
+	case strings.HasPrefix(name, "tun"), strings.HasPrefix(name, "tap"),
+		strings.HasPrefix(name, "utun"):
+		return TypeTun
+	case strings.HasPrefix(name, "wg"):
+		return TypeWireGuard
+	case strings.Contains(name, "."), strings.HasPrefix(name, "vlan"):
+		return TypeVLAN
+	case strings.HasPrefix(name, "eth"), strings.HasPrefix(name, "en"),
+		strings.HasPrefix(name, "wl"), strings.HasPrefix(name, "ww"):
+		return TypePhysical
+	}
+	return TypeOther
+}
+
+func readUevent(file string) map[string]string {
+	values := make(map[string]string)
+	f, err := os.Open(file)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(scanner.Text(), "="); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func exists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+// ValidateInterfaceTypes checks the names given to the interface type filter.
+func ValidateInterfaceTypes(types []string) error {
+	for _, t := range types {
+		valid := false
+		for _, known := range InterfaceTypes {
+			if t == known {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown interface type %q (available: %s)", t, strings.Join(InterfaceTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// matchAny reports whether name matches one of the glob patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterReason explains why the configured include, exclude and type filters
+// skip an interface, or returns "" if it passes them.
+func filterReason(name, ifaceType string, cfg config.ServerConfig) string {
+	if len(cfg.IncludeInterfaces) > 0 && !matchAny(cfg.IncludeInterfaces, name) {
+		return "not matched by include patterns"
+	}
+	for _, pattern := range cfg.ExcludeInterfaces {
+		if ok, _ := path.Match(pattern, name); ok {
+			return "matched exclude pattern " + pattern
+		}
+	}
+	if len(cfg.InterfaceTypes) > 0 {
+		for _, t := range cfg.InterfaceTypes {
+			if t == ifaceType {
+				return ""
+			}
+		}
+		return "interface type " + ifaceType + " not selected"
+	}
+	return ""
+}
@@ -0,0 +1,371 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Deep inspection of a single host: ARP with the MAC vendor, ICMP
+   round-trip and TTL statistics, reverse names, a TCP port sweep, banners
+   of the open ports and a summary of their TLS certificates.
+*/
+
+package networkutils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// bannerTimeout is how long a banner grab waits for the service to talk.
+const bannerTimeout = 2 * time.Second
+
+// maxBannerLength bounds the banners kept.
+const maxBannerLength = 120
+
+// Services names the usual services of the common ports.
+var Services = map[int]string{
+	21: "ftp", 22: "ssh", 23: "telnet", 25: "smtp", 53: "domain", 80: "http",
+	110: "pop3", 111: "rpcbind", 135: "msrpc", 139: "netbios-ssn", 143: "imap",
+	443: "https", 445: "microsoft-ds", 465: "smtps", 548: "afp", 554: "rtsp",
+	587: "submission", 631: "ipp", 993: "imaps", 995: "pop3s", 1883: "mqtt",
+	3000: "http-alt", 3306: "mysql", 3389: "ms-wbt-server", 5000: "upnp",
+	5432: "postgresql", 5900: "vnc", 6379: "redis", 8000: "http-alt",
+	8080: "http-proxy", 8443: "https-alt", 8883: "secure-mqtt", 9000: "http-alt",
+	9100: "jetdirect",
+}
+
+// tlsPorts speak TLS from the first byte.
+var tlsPorts = map[int]bool{443: true, 465: true, 636: true, 853: true, 993: true, 995: true, 5986: true, 8443: true, 8883: true, 9443: true}
+
+// httpPorts answer an HTTP request when they do not talk first.
+var httpPorts = map[int]bool{80: true, 443: true, 631: true, 3000: true, 5000: true, 8000: true, 8080: true, 8443: true, 9000: true, 9443: true}
+
+// InspectOptions tune a host inspection.
+type InspectOptions struct {
+	// Ports are swept for open TCP ports, CommonPorts if empty.
+	Ports []int
+	// Timeout bounds ARP requests and TCP connections.
+	Timeout time.Duration
+	// Pings is the number of ICMP echo requests sent.
+	Pings int
+}
+
+// HostInspection is what an inspection found out about a host.
+type HostInspection struct {
+	IP        net.IP `json:"ip"`
+	Interface string `json:"interface,omitempty"`
+	// Self is set when the address is one of this host's.
+	Self      bool          `json:"self,omitempty"`
+	StartedAt time.Time     `json:"startedAt"`
+	Elapsed   time.Duration `json:"elapsedNs"`
+	ARP       *ARPResult    `json:"arp,omitempty"`
+	ICMP      *ICMPResult   `json:"icmp,omitempty"`
+	Names     []string      `json:"names,omitempty"`
+	// PortsSwept is the number of ports tried; Ports lists the open ones.
+	PortsSwept int          `json:"portsSwept"`
+	Ports      []PortResult `json:"ports"`
+	Errors     []string     `json:"errors,omitempty"`
+}
+
+// Up reports whether the host answered any probe.
+func (h *HostInspection) Up() bool {
+	return h.ARP != nil || (h.ICMP != nil && h.ICMP.Received > 0) || len(h.Ports) > 0
+}
+
+// ARPResult is the answer to an ARP request.
+type ARPResult struct {
+	MAC    string        `json:"mac"`
+	Vendor string        `json:"vendor,omitempty"`
+	RTT    time.Duration `json:"rttNs"`
+}
+
+// ICMPResult sums up a series of echo requests.
+type ICMPResult struct {
+	Sent     int           `json:"sent"`
+	Received int           `json:"received"`
+	Loss     float64       `json:"loss"`
+	MinRTT   time.Duration `json:"minRttNs,omitempty"`
+	AvgRTT   time.Duration `json:"avgRttNs,omitempty"`
+	MaxRTT   time.Duration `json:"maxRttNs,omitempty"`
+	// TTL is the most frequent TTL of the replies. InitialTTL is the usual
+	// initial value it was decremented from, hinting at the operating
+	// system, and Hops the routers on the way.
+	TTL        int    `json:"ttl,omitempty"`
+	InitialTTL int    `json:"initialTtl,omitempty"`
+	Hops       int    `json:"hops,omitempty"`
+	OSHint     string `json:"osHint,omitempty"`
+}
+
+// PortResult is an open TCP port.
+type PortResult struct {
+	Port    int          `json:"port"`
+	Service string       `json:"service,omitempty"`
+	Banner  string       `json:"banner,omitempty"`
+	TLS     *CertSummary `json:"tls,omitempty"`
+}
+
+// CertSummary describes the certificate a TLS service presented.
+type CertSummary struct {
+	Version    string    `json:"version"`
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	DNSNames   []string  `json:"dnsNames,omitempty"`
+	NotBefore  time.Time `json:"notBefore"`
+	NotAfter   time.Time `json:"notAfter"`
+	SelfSigned bool      `json:"selfSigned"`
+	Expired    bool      `json:"expired"`
+}
+
+// InspectHost runs every probe against one address.
+func (s *Scanner) InspectHost(ip net.IP, opts InspectOptions) *HostInspection {
+	if len(opts.Ports) == 0 {
+		opts.Ports = CommonPorts
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.Pings <= 0 {
+		opts.Pings = 5
+	}
+
+	h := &HostInspection{IP: ip, StartedAt: time.Now(), PortsSwept: len(opts.Ports), Ports: []PortResult{}}
+	var mu sync.Mutex
+	fail := func(step string, err error) {
+		mu.Lock()
+		h.Errors = append(h.Errors, fmt.Sprintf("%s: %v", step, err))
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	link, local := s.localInterface(ip)
+	if local {
+		h.Interface = s.qualifiedName(link.Name)
+	}
+	if own := ownAddress(link, ip); own {
+		// The kernel answers our own address, not ARP
+		h.Self = true
+		h.ARP = &ARPResult{MAC: link.HardwareAddr.String(), Vendor: LookupVendor(link.HardwareAddr.String())}
+	} else if local {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mac, rtt, err := s.Transport.ARP(ip, opts.Timeout)
+			switch {
+			case err == nil:
+				h.ARP = &ARPResult{MAC: mac.String(), Vendor: LookupVendor(mac.String()), RTT: rtt}
+			case !errors.Is(err, ErrNoReply):
+				fail("arp", err)
+			}
+		}()
+	}
+
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		stats, err := s.Transport.Ping(ip, opts.Pings, 200*time.Millisecond, time.Duration(opts.Pings)*200*time.Millisecond+opts.Timeout)
+		if err != nil {
+			fail("icmp", err)
+			return
+		}
+		h.ICMP = icmpResult(stats)
+	}()
+	go func() {
+		defer wg.Done()
+		names, err := s.Transport.LookupAddr(ip, lookupTimeout)
+		if err != nil {
+			return
+		}
+		for _, name := range names {
+			h.Names = append(h.Names, strings.TrimSuffix(name, "."))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		open := s.ScanPorts(ip, opts.Ports, opts.Timeout)
+		ports := make([]PortResult, len(open))
+		var portsWG sync.WaitGroup
+		for i, port := range open {
+			portsWG.Add(1)
+			go func(i, port int) {
+				defer portsWG.Done()
+				ports[i] = s.grabBanner(ip, port, opts.Timeout)
+			}(i, port)
+		}
+		portsWG.Wait()
+		h.Ports = ports
+	}()
+	wg.Wait()
+
+	sort.Strings(h.Errors)
+	h.Elapsed = time.Since(h.StartedAt)
+	return h
+}
+
+// localInterface returns the interface whose subnet holds ip, where ARP
+// can reach it.
+func (s *Scanner) localInterface(ip net.IP) (Link, bool) {
+	if ip.To4() == nil {
+		return Link{}, false
+	}
+	links, err := s.Transport.Interfaces()
+	if err != nil {
+		return Link{}, false
+	}
+	for _, link := range links {
+		if link.Flags&net.FlagLoopback != 0 || link.Flags&net.FlagUp == 0 {
+			continue
+		}
+		for _, addr := range link.Addrs {
+			if addr.IP.To4() != nil && addr.Contains(ip) {
+				return link, true
+			}
+		}
+	}
+	return Link{}, false
+}
+
+// ownAddress reports whether ip is an address of link.
+func ownAddress(link Link, ip net.IP) bool {
+	for _, addr := range link.Addrs {
+		if addr.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// icmpResult sums up ping statistics.
+func icmpResult(stats *PingStats) *ICMPResult {
+	r := &ICMPResult{Sent: stats.Sent, Received: stats.Received}
+	if stats.Sent > 0 {
+		r.Loss = float64(stats.Sent-stats.Received) / float64(stats.Sent) * 100
+	}
+	for i, rtt := range stats.RTTs {
+		if i == 0 || rtt < r.MinRTT {
+			r.MinRTT = rtt
+		}
+		if rtt > r.MaxRTT {
+			r.MaxRTT = rtt
+		}
+	}
+	r.AvgRTT = averageRTT(stats.RTTs)
+
+	counts := make(map[int]int)
+	for _, ttl := range stats.TTLs {
+		counts[ttl]++
+		if counts[ttl] > counts[r.TTL] || (counts[ttl] == counts[r.TTL] && ttl > r.TTL) {
+			r.TTL = ttl
+		}
+	}
+	if r.TTL > 0 {
+		switch {
+		case r.TTL <= 64:
+			r.InitialTTL, r.OSHint = 64, "Linux, macOS or other Unix"
+		case r.TTL <= 128:
+			r.InitialTTL, r.OSHint = 128, "Windows"
+		default:
+			r.InitialTTL, r.OSHint = 255, "network equipment"
+		}
+		r.Hops = r.InitialTTL - r.TTL
+	}
+	return r
+}
+
+// grabBanner connects to an open port and records what the service says:
+// its greeting, or its answer to an HTTP request, and its TLS certificate.
+func (s *Scanner) grabBanner(ip net.IP, port int, timeout time.Duration) PortResult {
+	result := PortResult{Port: port, Service: Services[port]}
+	conn, err := s.Transport.DialTCP(net.JoinHostPort(ip.String(), strconv.Itoa(port)), timeout)
+	if err != nil {
+		return result
+	}
+	defer conn.Close()
+
+	if tlsPorts[port] {
+		tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true, ServerName: ip.String()})
+		tlsConn.SetDeadline(time.Now().Add(bannerTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			return result
+		}
+		result.TLS = certSummary(tlsConn.ConnectionState())
+		conn = tlsConn
+	}
+
+	// HTTP servers wait for a request, other services greet first
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(bannerTimeout))
+	if httpPorts[port] {
+		fmt.Fprintf(conn, "HEAD / HTTP/1.0\r\nHost: %s\r\nUser-Agent: goscan\r\n\r\n", ip)
+		result.Banner = cleanBanner(httpBanner(r))
+		return result
+	}
+	line, _ := r.ReadString('\n')
+	result.Banner = cleanBanner(line)
+	return result
+}
+
+// httpBanner reads an HTTP response head and returns its status line with
+// the Server header, if any.
+func httpBanner(r *bufio.Reader) string {
+	status, err := r.ReadString('\n')
+	if err != nil && status == "" {
+		return ""
+	}
+	status = strings.TrimSpace(status)
+	for {
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" || err != nil {
+			return status
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "server") {
+			return status + " (" + strings.TrimSpace(value) + ")"
+		}
+	}
+}
+
+// cleanBanner keeps the printable text of the first line of a banner.
+func cleanBanner(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsPrint(r) {
+			return r
+		}
+		return -1
+	}, s)
+	if len(s) > maxBannerLength {
+		s = s[:maxBannerLength] + "..."
+	}
+	return s
+}
+
+// certSummary describes the certificate of a TLS connection.
+func certSummary(state tls.ConnectionState) *CertSummary {
+	summary := &CertSummary{Version: tls.VersionName(state.Version)}
+	if len(state.PeerCertificates) == 0 {
+		return summary
+	}
+	cert := state.PeerCertificates[0]
+	summary.Subject = cert.Subject.String()
+	summary.Issuer = cert.Issuer.String()
+	summary.DNSNames = cert.DNSNames
+	summary.NotBefore = cert.NotBefore
+	summary.NotAfter = cert.NotAfter
+	summary.Expired = time.Now().After(cert.NotAfter)
+	summary.SelfSigned = selfSigned(cert)
+	return summary
+}
+
+// selfSigned reports whether a certificate is its own issuer.
+func selfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Host inspection: ping statistics and banner parsing.
+*/
+
+package networkutils
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestICMPResult(t *testing.T) {
+	ms := time.Millisecond
+
+	tests := []struct {
+		name  string
+		stats PingStats
+		want  ICMPResult
+	}{
+		{
+			name:  "no reply",
+			stats: PingStats{Sent: 3},
+			want:  ICMPResult{Sent: 3, Loss: 100},
+		},
+		{
+			name:  "linux host",
+			stats: PingStats{Sent: 4, Received: 3, RTTs: []time.Duration{2 * ms, 1 * ms, 3 * ms}, TTLs: []int{64, 64, 64}},
+			want:  ICMPResult{Sent: 4, Received: 3, Loss: 25, MinRTT: 1 * ms, AvgRTT: 2 * ms, MaxRTT: 3 * ms, TTL: 64, InitialTTL: 64, OSHint: "Linux, macOS or other Unix"},
+		},
+		{
+			name:  "windows host two hops away",
+			stats: PingStats{Sent: 3, Received: 3, RTTs: []time.Duration{ms, ms, ms}, TTLs: []int{126, 125, 126}},
+			want:  ICMPResult{Sent: 3, Received: 3, MinRTT: ms, AvgRTT: ms, MaxRTT: ms, TTL: 126, InitialTTL: 128, Hops: 2, OSHint: "Windows"},
+		},
+		{
+			name:  "ttl tie takes the highest",
+			stats: PingStats{Sent: 2, Received: 2, RTTs: []time.Duration{ms, ms}, TTLs: []int{250, 255}},
+			want:  ICMPResult{Sent: 2, Received: 2, MinRTT: ms, AvgRTT: ms, MaxRTT: ms, TTL: 255, InitialTTL: 255, OSHint: "network equipment"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := icmpResult(&tt.stats); !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("icmpResult() =\n%+v\nwant\n%+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPBanner(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{"server header", "HTTP/1.1 200 OK\r\nDate: Wed, 01 May 2024 10:00:00 GMT\r\nserver: nginx/1.24.0\r\n\r\n", "HTTP/1.1 200 OK (nginx/1.24.0)"},
+		{"no server header", "HTTP/1.0 404 Not Found\r\nContent-Length: 0\r\n\r\n", "HTTP/1.0 404 Not Found"},
+		{"cut short", "HTTP/1.1 301 Moved Permanently\r\nLocation: /", "HTTP/1.1 301 Moved Permanently"},
+		{"nothing", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := httpBanner(bufio.NewReader(strings.NewReader(tt.response))); got != tt.want {
+				t.Errorf("httpBanner() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanBanner(t *testing.T) {
+	long := strings.Repeat("x", maxBannerLength+10)
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"SSH-2.0-OpenSSH_9.6\r\n", "SSH-2.0-OpenSSH_9.6"},
+		{"220 mail ESMTP\x00\x07 ready", "220 mail ESMTP ready"},
+		{"  \t\n", ""},
+		{long, long[:maxBannerLength] + "..."},
+	}
+
+	for _, tt := range tests {
+		if got := cleanBanner(tt.in); got != tt.want {
+			t.Errorf("cleanBanner(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
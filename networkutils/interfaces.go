@@ -15,6 +15,8 @@ import (
 
 type InterfaceDetails struct {
 	Name       string
+	Namespace  string `json:",omitempty"`
+	Type       string
 	IPs        []net.IP
 	SubnetBits []int
 	MACAddress net.HardwareAddr
@@ -32,55 +34,187 @@ func (iface *InterfaceDetails) ToJSON() InterfaceDetailsJSON {
 	}
 }
 
-func DiscoverInterfaces() ([]InterfaceDetails, error) {
+// QualifiedName is the interface name prefixed with its network namespace,
+// if any ("blue/eth0").
+func (iface *InterfaceDetails) QualifiedName() string {
+	if iface.Namespace == "" {
+		return iface.Name
+	}
+	return iface.Namespace + "/" + iface.Name
+}
+
+// Addresses returns the interface's IPv4 addresses in CIDR notation.
+func (iface *InterfaceDetails) Addresses() []string {
+	addrs := make([]string, len(iface.IPs))
+	for i, ip := range iface.IPs {
+		addrs[i] = fmt.Sprintf("%s/%d", ip, iface.SubnetBits[i])
+	}
+	return addrs
+}
+
+func (s *Scanner) DiscoverInterfaces() ([]InterfaceDetails, error) {
 	config := config.GetServerConfig()
 
-	interfaces, err := net.Interfaces()
+	links, err := s.Transport.Interfaces()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
 	}
 
 	var details []InterfaceDetails
-	for _, iface := range interfaces {
-		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+	for _, link := range links {
+		if exclusionReason(link, config) != "" {
 			continue
 		}
+		ips, subnets := ipv4Addrs(link)
+		details = append(details, InterfaceDetails{
+			Name:       link.Name,
+			Namespace:  s.Namespace,
+			Type:       link.Type,
+			IPs:        ips,
+			SubnetBits: subnets,
+			MACAddress: link.HardwareAddr,
+		})
+	}
+	return details, nil
+}
 
-		var ips []net.IP
-		var subnets []int
-		addrs, err := iface.Addrs()
-		if err != nil {
-			fmt.Printf("Skipping interface %s due to error: %v\n", iface.Name, err)
-			continue
+// ipv4Addrs returns the IPv4 addresses of a link and their prefix lengths.
+func ipv4Addrs(link Link) ([]net.IP, []int) {
+	var ips []net.IP
+	var subnets []int
+	for _, ipnet := range link.Addrs {
+		if ipnet.IP.To4() != nil {
+			ips = append(ips, ipnet.IP)
+			ones, _ := ipnet.Mask.Size()
+			subnets = append(subnets, ones)
 		}
+	}
+	return ips, subnets
+}
 
-		for _, addr := range addrs {
-			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
-				ips = append(ips, ipnet.IP)
-				mask := ipnet.Mask
-				ones, _ := mask.Size()
-				subnets = append(subnets, ones)
-			}
-		}
+// exclusionReason explains why DiscoverInterfaces leaves a link out, or
+// returns "" if it is scanned.
+func exclusionReason(link Link, cfg config.ServerConfig) string {
+	if link.Flags&net.FlagLoopback != 0 {
+		return "loopback"
+	}
+	if link.Flags&net.FlagUp == 0 {
+		return "down"
+	}
+	if reason := filterReason(link.Name, link.Type, cfg); reason != "" {
+		return reason
+	}
+	_, subnets := ipv4Addrs(link)
+	if len(subnets) == 0 {
+		return "no IPv4 address"
+	}
+	if size := CalcSubnetSize(subnets); size > cfg.MaxSubnetSize {
+		return fmt.Sprintf("%d addresses exceed MaxSubnetSize %d", size, cfg.MaxSubnetSize)
+	}
+	return ""
+}
 
-		if len(ips) > 0 {
-			detail := InterfaceDetails{
-				Name:       iface.Name,
-				IPs:        ips,
-				SubnetBits: subnets,
-				MACAddress: iface.HardwareAddr,
-			}
+// InterfaceListing describes an interface, scanned or not.
+type InterfaceListing struct {
+	Name      string             `json:"name"`
+	Namespace string             `json:"namespace,omitempty"`
+	Type      string             `json:"type"`
+	MAC       string             `json:"mac,omitempty"`
+	State     string             `json:"state"`
+	Addresses []InterfaceAddress `json:"addresses"`
+	Gateways  []string           `json:"gateways,omitempty"`
+	// Excluded is set when scans leave the interface out, for Reason.
+	Excluded bool   `json:"excluded"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// InterfaceAddress is an address of an interface and its subnet.
+type InterfaceAddress struct {
+	Address string `json:"address"`
+	Family  string `json:"family"`
+	Network string `json:"network"`
+	Prefix  int    `json:"prefix"`
+	// Size is the number of host addresses of an IPv4 subnet, the
+	// addresses a scan probes.
+	Size int `json:"size,omitempty"`
+}
 
-			if CalcSubnetSize(subnets) <= config.MaxSubnetSize {
-				details = append(details, detail)
+// Link states.
+const (
+	LinkUp        = "up"
+	LinkNoCarrier = "no-carrier"
+	LinkDown      = "down"
+)
+
+// QualifiedName is the interface name prefixed with its network namespace,
+// if any ("blue/eth0").
+func (l *InterfaceListing) QualifiedName() string {
+	if l.Namespace == "" {
+		return l.Name
+	}
+	return l.Namespace + "/" + l.Name
+}
+
+// ListInterfaces lists every interface with its addresses and whether
+// DiscoverInterfaces selects it for scanning.
+func (s *Scanner) ListInterfaces() ([]InterfaceListing, error) {
+	config := config.GetServerConfig()
+
+	links, err := s.Transport.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+
+	listings := make([]InterfaceListing, 0, len(links))
+	for _, link := range links {
+		l := InterfaceListing{
+			Name:      link.Name,
+			Namespace: s.Namespace,
+			Type:      link.Type,
+			State:     linkState(link.Flags),
+			Addresses: []InterfaceAddress{},
+			Reason:    exclusionReason(link, config),
+		}
+		l.Excluded = l.Reason != ""
+		if len(link.HardwareAddr) > 0 {
+			l.MAC = link.HardwareAddr.String()
+		}
+		for _, ipnet := range link.Addrs {
+			ones, _ := ipnet.Mask.Size()
+			addr := InterfaceAddress{
+				Address: fmt.Sprintf("%s/%d", ipnet.IP, ones),
+				Family:  "IPv6",
+				Network: (&net.IPNet{IP: ipnet.IP.Mask(ipnet.Mask), Mask: ipnet.Mask}).String(),
+				Prefix:  ones,
 			}
+			if ipnet.IP.To4() != nil {
+				addr.Family = "IPv4"
+				addr.Size = CalcSubnetSizeSingle(ones)
+			}
+			l.Addresses = append(l.Addresses, addr)
+		}
+		for _, gw := range link.Gateways {
+			l.Gateways = append(l.Gateways, gw.String())
 		}
+		listings = append(listings, l)
 	}
-	return details, nil
+	return listings, nil
 }
 
-func GetInterfaceByName(name string) (*InterfaceDetails, error) {
-	ifaces, err := DiscoverInterfaces()
+// linkState tells an interface that is down from one that is up without a
+// carrier.
+func linkState(flags net.Flags) string {
+	switch {
+	case flags&net.FlagUp == 0:
+		return LinkDown
+	case flags&net.FlagRunning == 0 && flags&net.FlagLoopback == 0:
+		return LinkNoCarrier
+	}
+	return LinkUp
+}
+
+func (s *Scanner) GetInterfaceByName(name string) (*InterfaceDetails, error) {
+	ifaces, err := s.DiscoverInterfaces()
 	if err != nil {
 		return nil, err
 	}
@@ -92,3 +226,23 @@ func GetInterfaceByName(name string) (*InterfaceDetails, error) {
 	}
 	return nil, fmt.Errorf("interface with name '%s' not found", name)
 }
+
+// GetInterfaceForSubnet returns the interface with an address whose subnet
+// contains the given network.
+func (s *Scanner) GetInterfaceForSubnet(subnet *net.IPNet) (*InterfaceDetails, error) {
+	ifaces, err := s.DiscoverInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	subnetBits, _ := subnet.Mask.Size()
+	for _, iface := range ifaces {
+		for i, ip := range iface.IPs {
+			network := &net.IPNet{IP: ip.Mask(net.CIDRMask(iface.SubnetBits[i], 32)), Mask: net.CIDRMask(iface.SubnetBits[i], 32)}
+			if network.Contains(subnet.IP) && subnetBits >= iface.SubnetBits[i] {
+				return &iface, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no interface on subnet %s", subnet)
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   DHCP leases, as read from a DHCP server, checked against scan results.
+*/
+
+package networkutils
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"time"
+)
+
+// Lease is a DHCP lease. A nil Expires means the lease does not expire.
+type Lease struct {
+	IP       net.IP     `json:"ip"`
+	MAC      string     `json:"mac,omitempty"`
+	Hostname string     `json:"hostname,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	Source   string     `json:"source,omitempty"`
+}
+
+// Expired reports whether the lease is no longer valid at the given time.
+func (l Lease) Expired(at time.Time) bool {
+	return l.Expires != nil && !l.Expires.After(at)
+}
+
+// LeaseCheck lists the disagreements between DHCP leases and a scan.
+type LeaseCheck struct {
+	// Leases is the number of valid leases within the scanned subnets.
+	Leases int `json:"leases"`
+	// LeasedInactive are valid leases whose address did not answer.
+	LeasedInactive []Lease `json:"leasedInactive"`
+	// ActiveUnleased are answering addresses without a lease: static
+	// assignments or rogue hosts.
+	ActiveUnleased []net.IP `json:"activeUnleased"`
+}
+
+// ApplyLeases attaches the valid leases of the scanned subnets to the active
+// hosts and records which leased addresses are silent and which active ones
+// have no lease. Interfaces without any lease in their subnets are left
+// alone, as no DHCP server is known to serve them.
+func (r *InterfaceReport) ApplyLeases(leases []Lease, at time.Time) {
+	scanned := make(map[string]bool)
+	for _, ip := range r.scannedIPs() {
+		scanned[ip.String()] = true
+	}
+
+	valid := make(map[string]Lease)
+	for _, lease := range leases {
+		ip := lease.IP.String()
+		if scanned[ip] && !lease.Expired(at) {
+			valid[ip] = lease
+		}
+	}
+	if len(valid) == 0 {
+		return
+	}
+
+	check := &LeaseCheck{
+		Leases:         len(valid),
+		LeasedInactive: []Lease{},
+		ActiveUnleased: []net.IP{},
+	}
+	active := make(map[string]bool, len(r.ActiveHosts))
+	for i := range r.ActiveHosts {
+		host := &r.ActiveHosts[i]
+		ip := host.IP.String()
+		active[ip] = true
+		if lease, ok := valid[ip]; ok {
+			lease := lease
+			host.Lease = &lease
+		} else {
+			host.Lease = nil
+			check.ActiveUnleased = append(check.ActiveUnleased, host.IP)
+		}
+	}
+	for ip, lease := range valid {
+		if !active[ip] {
+			check.LeasedInactive = append(check.LeasedInactive, lease)
+		}
+	}
+
+	sortLeases(check.LeasedInactive)
+	SortIPs(check.ActiveUnleased)
+	r.LeaseCheck = check
+}
+
+// ApplyLeases applies the leases to every interface of the report.
+func (r *ScanReport) ApplyLeases(leases []Lease, at time.Time) {
+	for i := range r.Interfaces {
+		r.Interfaces[i].ApplyLeases(leases, at)
+	}
+}
+
+func sortLeases(leases []Lease) {
+	sort.Slice(leases, func(i, j int) bool {
+		return bytes.Compare(leases[i].IP.To16(), leases[j].IP.To16()) < 0
+	})
+}
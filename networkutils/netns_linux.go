@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: MIT
+
+//go:build linux
+
+/*
+   Network namespace support.
+
+   Every operation of the wrapped transport runs on one of a fixed pool of
+   OS threads that joined the target namespace when the pool started.
+   Sockets keep the namespace they were created in, so replies are received
+   there even when the read happens on another thread. The threads are never
+   unlocked, which makes the Go runtime discard them when the pool stops
+   instead of returning them to the scheduler in the wrong namespace.
+*/
+
+package networkutils
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// netnsRunDir is where `ip netns add` creates named namespaces.
+const netnsRunDir = "/run/netns"
+
+// netnsWorkers is the number of threads kept in each namespace. Operations
+// beyond it wait for a free thread.
+const netnsWorkers = 256
+
+// NetnsTransport runs a Transport inside a network namespace.
+type NetnsTransport struct {
+	Base Transport
+	Name string // label used in results
+	Path string
+	fd   int
+
+	mu      sync.Mutex
+	jobs    chan netnsJob // nil until the pool starts
+	stopped bool
+}
+
+// netnsJob is an operation handed to the pool.
+type netnsJob struct {
+	fn   func()
+	done chan error
+}
+
+// NewNetnsTransport opens a namespace given by name (as listed by
+// `ip netns list`) or by path (e.g. /proc/<pid>/ns/net).
+func NewNetnsTransport(base Transport, nameOrPath string) (*NetnsTransport, error) {
+	path, name := resolveNetns(nameOrPath)
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open network namespace %s: %w", nameOrPath, err)
+	}
+	return &NetnsTransport{Base: base, Name: name, Path: path, fd: fd}, nil
+}
+
+// resolveNetns maps a namespace argument to a file path and a display name.
+func resolveNetns(nameOrPath string) (string, string) {
+	if !strings.Contains(nameOrPath, "/") {
+		return filepath.Join(netnsRunDir, nameOrPath), nameOrPath
+	}
+	if filepath.Dir(nameOrPath) == netnsRunDir {
+		return nameOrPath, filepath.Base(nameOrPath)
+	}
+	return nameOrPath, nameOrPath
+}
+
+// Close stops the threads and releases the namespace file descriptor.
+// Operations must not be running or started any more.
+func (t *NetnsTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.jobs != nil {
+		close(t.jobs)
+	}
+	t.stopped = true
+	return unix.Close(t.fd)
+}
+
+// do runs fn on a thread of the pool, starting it at the first call.
+func (t *NetnsTransport) do(fn func()) error {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return fmt.Errorf("network namespace %s is closed", t.Name)
+	}
+	if t.jobs == nil {
+		t.jobs = make(chan netnsJob)
+		for i := 0; i < netnsWorkers; i++ {
+			go t.worker(t.jobs)
+		}
+	}
+	jobs := t.jobs
+	t.mu.Unlock()
+
+	job := netnsJob{fn: fn, done: make(chan error, 1)}
+	jobs <- job
+	return <-job.done
+}
+
+// worker joins the namespace on its own thread and runs jobs there until
+// the pool stops. If the namespace cannot be joined, every job fails.
+func (t *NetnsTransport) worker(jobs <-chan netnsJob) {
+	// Deliberately left locked so the thread exits with the goroutine
+	runtime.LockOSThread()
+	var err error
+	if nsErr := unix.Setns(t.fd, unix.CLONE_NEWNET); nsErr != nil {
+		err = &ScanError{Kind: classifyError(nsErr), Err: fmt.Errorf("setns %s: %w", t.Name, nsErr)}
+	}
+	for job := range jobs {
+		if err == nil {
+			job.fn()
+		}
+		job.done <- err
+	}
+}
+
+func (t *NetnsTransport) Interfaces() ([]Link, error) {
+	var links []Link
+	var err error
+	if nsErr := t.do(func() { links, err = t.Base.Interfaces() }); nsErr != nil {
+		return nil, nsErr
+	}
+	// sysfs shows the namespace it was mounted from, not ours
+	for i := range links {
+		links[i].Type = guessInterfaceType(links[i].Name)
+	}
+	return links, err
+}
+
+func (t *NetnsTransport) ARP(ip net.IP, timeout time.Duration) (net.HardwareAddr, time.Duration, error) {
+	var mac net.HardwareAddr
+	var rtt time.Duration
+	var err error
+	if nsErr := t.do(func() { mac, rtt, err = t.Base.ARP(ip, timeout) }); nsErr != nil {
+		return nil, 0, nsErr
+	}
+	return mac, rtt, err
+}
+
+func (t *NetnsTransport) Ping(ip net.IP, count int, interval, timeout time.Duration) (*PingStats, error) {
+	var stats *PingStats
+	var err error
+	if nsErr := t.do(func() { stats, err = t.Base.Ping(ip, count, interval, timeout) }); nsErr != nil {
+		return nil, nsErr
+	}
+	return stats, err
+}
+
+func (t *NetnsTransport) DialTCP(address string, timeout time.Duration) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if nsErr := t.do(func() { conn, err = t.Base.DialTCP(address, timeout) }); nsErr != nil {
+		return nil, nsErr
+	}
+	return conn, err
+}
+
+func (t *NetnsTransport) LookupAddr(ip net.IP, timeout time.Duration) ([]string, error) {
+	var names []string
+	var err error
+	if nsErr := t.do(func() { names, err = t.Base.LookupAddr(ip, timeout) }); nsErr != nil {
+		return nil, nsErr
+	}
+	return names, err
+}
+
+// NewNamespaceScanner returns a Scanner that discovers and probes inside
+// the given network namespace. Its results are labeled with the namespace.
+func NewNamespaceScanner(nameOrPath string) (*Scanner, error) {
+	transport, err := NewNetnsTransport(SystemTransport{}, nameOrPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Scanner{Transport: transport, Namespace: transport.Name}, nil
+}
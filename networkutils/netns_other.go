@@ -0,0 +1,12 @@
+// SPDX-License-Identifier: MIT
+
+//go:build !linux
+
+package networkutils
+
+import "errors"
+
+// NewNamespaceScanner is only supported on Linux.
+func NewNamespaceScanner(nameOrPath string) (*Scanner, error) {
+	return nil, errors.New("network namespaces are only supported on Linux")
+}
@@ -7,10 +7,18 @@
 package networkutils
 
 import (
+	"errors"
+	"net"
+	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	maxConcurrentLookups = 32
+	lookupTimeout        = time.Second
+)
+
 func calculateTotalIPsScanned(ifaces []InterfaceDetails) int {
 	totalIPsScanned := 0
 	for _, iface := range ifaces {
@@ -19,54 +27,128 @@ func calculateTotalIPsScanned(ifaces []InterfaceDetails) int {
 	return totalIPsScanned
 }
 
-func FetchAllNetworkData(timeout time.Duration) (map[string]interface{}, error) {
+// ScanInterface probes an interface and builds its report. Probe errors are
+// recorded in the report rather than returned.
+func (s *Scanner) ScanInterface(iface *InterfaceDetails, profile Profile) InterfaceReport {
 	startTime := time.Now()
-	ifaces, err := DiscoverInterfaces()
-	if err != nil {
-		return nil, err
+	report := InterfaceReport{
+		Name:        iface.Name,
+		Namespace:   iface.Namespace,
+		Type:        iface.Type,
+		MACAddress:  iface.MACAddress.String(),
+		Addresses:   iface.Addresses(),
+		Profile:     profile.Name,
+		ActiveHosts: []HostResult{},
 	}
 
-	var wg sync.WaitGroup
-	results := make(map[string]interface{})
-	var mu sync.Mutex
+	activeHosts, allHosts, err := s.ProbeHosts(iface, profile)
+	if activeHosts != nil {
+		report.ActiveHosts = activeHosts
+	}
+	if profile.ResolveNames {
+		s.resolveNames(report.ActiveHosts)
+	}
+	report.Unprobed = unprobedHosts(err)
+	unprobed := report.unprobedSet()
+	report.Scanned = make([]net.IP, 0, len(allHosts))
+	for _, ip := range allHosts {
+		if !unprobed[ip.String()] {
+			report.Scanned = append(report.Scanned, ip)
+		}
+	}
+	report.TotalIPsScanned = len(report.Scanned)
+	report.Errors = SummarizeError(err)
+	report.Elapsed = time.Since(startTime)
+	return report
+}
 
-	for _, iface := range ifaces {
+// unprobedHosts returns the addresses of the failed probes of a ProbeHosts
+// error, sorted.
+func unprobedHosts(err error) []net.IP {
+	var ifaceErrs *InterfaceErrors
+	if !errors.As(err, &ifaceErrs) {
+		return nil
+	}
+	var ips []net.IP
+	for _, scanErr := range ifaceErrs.Errors {
+		if scanErr.Host != nil {
+			ips = append(ips, scanErr.Host)
+		}
+	}
+	SortIPs(ips)
+	return ips
+}
+
+// resolveNames fills in the reverse DNS names of the hosts. Lookups that
+// fail or time out leave the host unnamed.
+func (s *Scanner) resolveNames(hosts []HostResult) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentLookups)
+	for i := range hosts {
 		wg.Add(1)
-		go func(iface InterfaceDetails) {
+		sem <- struct{}{}
+		go func(host *HostResult) {
 			defer wg.Done()
-			activeHosts, allHosts, err := ProbeHosts(&iface, timeout)
-			mu.Lock()
-			defer mu.Unlock()
+			defer func() { <-sem }()
+			names, err := s.Transport.LookupAddr(host.IP, lookupTimeout)
 			if err != nil {
-				results[iface.Name] = map[string]interface{}{"error": err.Error()}
 				return
 			}
-			SortIPs(activeHosts)
-			totalIpsScanned := len(allHosts)
-
-			results[iface.Name] = map[string]interface{}{
-				"MACAddress":      iface.MACAddress.String(),
-				"TotalIPsScanned": totalIpsScanned,
-				"activeHosts":     activeHosts,
+			for _, name := range names {
+				host.Names = append(host.Names, strings.TrimSuffix(name, "."))
 			}
-		}(iface)
+		}(&hosts[i])
 	}
 	wg.Wait()
+}
 
-	elapsed := time.Since(startTime)
+// FetchAllNetworkData scans every discovered interface with the profile
+// assigned to it in the server configuration.
+func FetchAllNetworkData() (*ScanReport, error) {
+	return DefaultScanner.FetchAllNetworkData()
+}
 
-	totalIPsScanned := 0
-	for _, result := range results {
-		if resultMap, ok := result.(map[string]interface{}); ok {
-			if scanned, ok := resultMap["TotalIPsScanned"].(int); ok {
-				totalIPsScanned += scanned
+func (s *Scanner) FetchAllNetworkData() (*ScanReport, error) {
+	startTime := time.Now()
+	ifaces, err := s.DiscoverInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	reports := make([]InterfaceReport, len(ifaces))
+
+	for i, iface := range ifaces {
+		wg.Add(1)
+		go func(i int, iface InterfaceDetails) {
+			defer wg.Done()
+			profile, err := s.ProfileForInterface(iface.Name)
+			if err != nil {
+				reports[i] = InterfaceReport{
+					Name:        iface.Name,
+					Namespace:   iface.Namespace,
+					Type:        iface.Type,
+					MACAddress:  iface.MACAddress.String(),
+					Addresses:   iface.Addresses(),
+					ActiveHosts: []HostResult{},
+					Errors:      SummarizeError(err),
+				}
+				return
 			}
-		}
+			reports[i] = s.ScanInterface(&iface, profile)
+		}(i, iface)
 	}
+	wg.Wait()
 
-	return map[string]interface{}{
-		"results":         results,
-		"elapsedTime":     elapsed,
-		"totalIPsScanned": totalIPsScanned,
-	}, nil
+	report := &ScanReport{
+		Version:    ReportVersion,
+		StartedAt:  startTime,
+		Elapsed:    time.Since(startTime),
+		Interfaces: reports,
+	}
+	for _, iface := range reports {
+		report.TotalIPsScanned += iface.TotalIPsScanned
+	}
+	sortInterfaces(report.Interfaces)
+	return report, nil
 }
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   TCP port sweeps of single hosts.
+*/
+
+package networkutils
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxConcurrentPorts bounds the connections a port sweep opens at once.
+const maxConcurrentPorts = 64
+
+// CommonPorts are the TCP ports swept when none are given.
+var CommonPorts = []int{
+	21, 22, 23, 25, 53, 80, 110, 111, 135, 139, 143, 443, 445, 465, 548, 554,
+	587, 631, 993, 995, 1883, 3000, 3306, 3389, 5000, 5432, 5900, 6379, 8000,
+	8080, 8443, 8883, 9000, 9100,
+}
+
+// ScanPorts returns the ports of ip, in order, that accept a TCP
+// connection within the timeout.
+func (s *Scanner) ScanPorts(ip net.IP, ports []int, timeout time.Duration) []int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, maxConcurrentPorts)
+	open := []int{}
+	for _, port := range ports {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(port int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			conn, err := s.Transport.DialTCP(net.JoinHostPort(ip.String(), strconv.Itoa(port)), timeout)
+			if err != nil {
+				return
+			}
+			conn.Close()
+			mu.Lock()
+			open = append(open, port)
+			mu.Unlock()
+		}(port)
+	}
+	wg.Wait()
+	sort.Ints(open)
+	return open
+}
@@ -10,14 +10,13 @@
 package networkutils
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
 	"sync"
+	"syscall"
 	"time"
-
-	"github.com/go-ping/ping"
-	"github.com/j-keck/arping"
 )
 
 const (
@@ -45,6 +44,9 @@ var commonPorts = []int{
 type hostResult struct {
 	ip     net.IP
 	active bool
+	mac    net.HardwareAddr
+	method string
+	rtt    time.Duration
 	err    error
 }
 
@@ -89,50 +91,93 @@ func isLocalNetwork(subnetBits int) bool {
 }
 
 // arpScan attempts to discover hosts using ARP
-func arpScan(ip net.IP, timeout time.Duration) bool {
-	arping.SetTimeout(timeout)
-
-	_, _, err := arping.Ping(ip)
-	return err == nil
+func (s *Scanner) arpScan(ip net.IP, timeout time.Duration) (hostResult, error) {
+	mac, rtt, err := s.Transport.ARP(ip, timeout)
+	if err == nil {
+		return hostResult{ip: ip, active: true, mac: mac, method: MethodARP, rtt: rtt}, nil
+	}
+	if errors.Is(err, ErrNoReply) {
+		// No reply is a negative result, not a failure
+		return hostResult{ip: ip}, nil
+	}
+	return hostResult{ip: ip}, newScanError(MethodARP, ip, err)
 }
 
-// icmpScan attempts to discover hosts using ICMP echo requests
-func icmpScan(ip net.IP, timeout time.Duration) bool {
-	var retryCount int
-	currentTimeout := timeout
+// confirmAttempts is how many ARP requests ConfirmUnused sends, as a single
+// lost reply would hand out an address in use.
+const confirmAttempts = 3
 
-	for retryCount < maxRetries {
-		pinger, err := ping.NewPinger(ip.String())
+// ConfirmUnused checks with fresh ARP requests that nothing answers on an
+// address. It returns the host that answered, or nil if none did.
+func (s *Scanner) ConfirmUnused(ip net.IP, timeout time.Duration) (*HostResult, error) {
+	for attempt := 0; attempt < confirmAttempts; attempt++ {
+		result, err := s.arpScan(ip, timeout)
+		countProbe(MethodARP, result.active, err)
 		if err != nil {
-			return false
+			return nil, err
+		}
+		if result.active {
+			host := &HostResult{IP: ip, Method: MethodARP, RTT: result.rtt}
+			if result.mac != nil {
+				host.MAC = result.mac.String()
+			}
+			return host, nil
 		}
+	}
+	return nil, nil
+}
 
-		pinger.SetPrivileged(true)
-		pinger.Count = 2
-		pinger.Interval = 50 * time.Millisecond
-		pinger.Timeout = currentTimeout
-		pinger.Size = 56
+// icmpScan attempts to discover hosts using ICMP echo requests
+func (s *Scanner) icmpScan(ip net.IP, profile Profile) (hostResult, error) {
+	var retryCount int
+	var lastErr error
+	currentTimeout := profile.Timeout
 
-		jitter := time.Duration(rand.Intn(100)) * time.Millisecond
-		time.Sleep(jitter)
+	for retryCount < profile.Retries {
+		if profile.Jitter > 0 {
+			jitter := time.Duration(rand.Int63n(int64(profile.Jitter)))
+			time.Sleep(jitter)
+		}
 
-		err = pinger.Run()
-		stats := pinger.Statistics()
+		stats, err := s.Transport.Ping(ip, 2, 50*time.Millisecond, currentTimeout)
+		if err == nil && stats.Received > 0 {
+			return hostResult{ip: ip, active: true, method: MethodICMP, rtt: averageRTT(stats.RTTs)}, nil
+		}
 
-		if err == nil && stats.PacketsRecv > 0 {
-			return true
+		if err != nil {
+			lastErr = newScanError(MethodICMP, ip, err)
+			// Retrying will not fix missing privileges or a downed interface
+			if kind := classifyError(err); kind == ErrKindPermission || kind == ErrKindInterfaceDown {
+				return hostResult{ip: ip}, lastErr
+			}
+		} else {
+			lastErr = nil
 		}
 
 		retryCount++
 		currentTimeout *= 2
 	}
 
-	return false
+	return hostResult{ip: ip}, lastErr
+}
+
+// averageRTT returns the mean of the round-trip times, or zero.
+func averageRTT(rtts []time.Duration) time.Duration {
+	if len(rtts) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, rtt := range rtts {
+		total += rtt
+	}
+	return total / time.Duration(len(rtts))
 }
 
-// tcpScan attempts to discover hosts by checking for common open TCP ports
-func tcpScan(ip net.IP, timeout time.Duration) bool {
-	for _, port := range commonPorts {
+// tcpScan attempts to discover hosts by checking for common open TCP ports.
+// A refused connection also proves the host is up.
+func (s *Scanner) tcpScan(ip net.IP, profile Profile) (hostResult, error) {
+	var lastErr error
+	for _, port := range profile.ports() {
 		var address string
 		if ip.To4() == nil {
 			// IPv6 address
@@ -142,65 +187,181 @@ func tcpScan(ip net.IP, timeout time.Duration) bool {
 			address = fmt.Sprintf("%s:%d", ip.String(), port)
 		}
 
-		conn, err := net.DialTimeout("tcp", address, timeout/2)
+		start := time.Now()
+		conn, err := s.Transport.DialTCP(address, profile.Timeout/2)
 		if err == nil {
 			conn.Close()
-			return true
+			return hostResult{ip: ip, active: true, method: MethodTCP, rtt: time.Since(start)}, nil
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return hostResult{ip: ip, active: true, method: MethodTCP, rtt: time.Since(start)}, nil
+		}
+		switch kind := classifyError(err); kind {
+		case ErrKindPermission, ErrKindResourceExhausted, ErrKindInterfaceDown:
+			lastErr = newScanError(MethodTCP, ip, err)
 		}
 	}
-	return false
+	return hostResult{ip: ip}, lastErr
 }
 
-// probeHost attempts to discover if a host is active using multiple methods
-func probeHost(ip net.IP, timeout time.Duration, isLocal bool, resultsChan chan<- hostResult, wg *sync.WaitGroup) {
+// probeHost attempts to discover if a host is active using the methods of
+// the profile. A host that answers none of the probes is only reported
+// inactive when every probe was actually carried out; otherwise the probe
+// error is returned.
+func (s *Scanner) probeHost(ip net.IP, profile Profile, isLocal bool, resultsChan chan<- hostResult, wg *sync.WaitGroup) {
 	defer wg.Done()
 
+	var probeErr error
+
 	// Try ARP first if it's a local network (fastest method)
-	if isLocal && arpScan(ip, timeout/2) {
-		resultsChan <- hostResult{ip: ip, active: true}
-		return
+	if isLocal && profile.Uses(MethodARP) {
+		result, err := s.arpScan(ip, profile.Timeout/2)
+		countProbe(MethodARP, result.active, err)
+		if result.active {
+			resultsChan <- result
+			return
+		}
+		probeErr = err
 	}
 
-	if icmpScan(ip, timeout) {
-		resultsChan <- hostResult{ip: ip, active: true}
-		return
+	if profile.Uses(MethodICMP) {
+		result, err := s.icmpScan(ip, profile)
+		countProbe(MethodICMP, result.active, err)
+		if result.active {
+			resultsChan <- result
+			return
+		}
+		if err != nil {
+			probeErr = err
+		}
+	}
+
+	if profile.Uses(MethodTCP) {
+		result, err := s.tcpScan(ip, profile)
+		countProbe(MethodTCP, result.active, err)
+		if result.active {
+			resultsChan <- result
+			return
+		}
+		if err != nil {
+			probeErr = err
+		}
+	}
+
+	resultsChan <- hostResult{ip: ip, active: false, err: probeErr}
+}
+
+// ProbeHost probes a single address of an interface with the methods of the
+// profile, resolving its names if the profile does. It returns nil if the
+// host did not answer.
+func (s *Scanner) ProbeHost(ifaceDetails *InterfaceDetails, ip net.IP, profile Profile) (*HostResult, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	isLocal := false
+	for i, ifaceIP := range ifaceDetails.IPs {
+		subnet := net.IPNet{IP: ifaceIP.Mask(net.CIDRMask(ifaceDetails.SubnetBits[i], 32)), Mask: net.CIDRMask(ifaceDetails.SubnetBits[i], 32)}
+		if subnet.Contains(ip) {
+			isLocal = isLocalNetwork(ifaceDetails.SubnetBits[i])
+		}
 	}
 
-	// if tcpScan(ip, timeout) {
-	// 	resultsChan <- hostResult{ip: ip, active: true}
-	// 	return
-	// }
+	var wg sync.WaitGroup
+	resultsChan := make(chan hostResult, 1)
+	wg.Add(1)
+	s.probeHost(ip, profile, isLocal, resultsChan, &wg)
+	result := <-resultsChan
+	if result.err != nil {
+		return nil, result.err
+	}
+	if !result.active {
+		return nil, nil
+	}
 
-	resultsChan <- hostResult{ip: ip, active: false}
+	host := HostResult{IP: ip, Method: result.method, RTT: result.rtt}
+	if result.mac != nil {
+		host.MAC = result.mac.String()
+	}
+	if profile.ResolveNames {
+		hosts := []HostResult{host}
+		s.resolveNames(hosts)
+		host = hosts[0]
+	}
+	return &host, nil
 }
 
 // handleResults collects the results of host probing
-func handleResults(resultsChan <-chan hostResult, activeHosts *[]net.IP, returnError *error, mu *sync.Mutex) {
+func handleResults(resultsChan <-chan hostResult, activeHosts *[]HostResult, scanErrors *[]*ScanError, progress *progressTracker, done chan<- struct{}) {
+	defer close(done)
 	for result := range resultsChan {
-		if result.err != nil && *returnError == nil {
-			*returnError = result.err
+		progress.add(result.active)
+		if result.err != nil {
+			var scanErr *ScanError
+			if !errors.As(result.err, &scanErr) {
+				scanErr = newScanError("", result.ip, result.err)
+			}
+			*scanErrors = append(*scanErrors, scanErr)
 			continue
 		}
 		if result.active {
-			mu.Lock()
-			*activeHosts = append(*activeHosts, result.ip)
-			mu.Unlock()
+			host := HostResult{IP: result.ip, Method: result.method, RTT: result.rtt}
+			if result.mac != nil {
+				host.MAC = result.mac.String()
+			}
+			*activeHosts = append(*activeHosts, host)
 		}
 	}
 }
 
-// ProbeHosts probes hosts on a network interface using multiple methods
-func ProbeHosts(ifaceDetails *InterfaceDetails, initialTimeout time.Duration) ([]net.IP, []net.IP, error) {
-	var wg sync.WaitGroup
-	resultsChan := make(chan hostResult, maxConcurrentScans)
-	var activeHosts []net.IP
-	var allHosts []net.IP // Added to track all scanned hosts
-	var returnError error
-	var mu sync.Mutex
+// checkInterfaceUp returns an interface_down error if the interface has gone
+// away or lost its up flag since it was discovered.
+func (s *Scanner) checkInterfaceUp(name string) error {
+	links, err := s.Transport.Interfaces()
+	if err != nil {
+		return &ScanError{Kind: classifyError(err), Interface: name, Err: err}
+	}
+	for _, link := range links {
+		if link.Name != name {
+			continue
+		}
+		if link.Flags&net.FlagUp == 0 {
+			return &ScanError{Kind: ErrKindInterfaceDown, Interface: name, Err: fmt.Errorf("link is down")}
+		}
+		return nil
+	}
+	return &ScanError{Kind: ErrKindInterfaceDown, Interface: name, Err: fmt.Errorf("interface not found")}
+}
+
+// ProbeHosts probes hosts on a network interface using the methods, timing
+// and concurrency of the given profile. It returns the active hosts, sorted
+// by address, and every address scanned. If some probes failed, the error
+// is an *InterfaceErrors and the results are partial.
+func ProbeHosts(ifaceDetails *InterfaceDetails, profile Profile) ([]HostResult, []net.IP, error) {
+	return DefaultScanner.ProbeHosts(ifaceDetails, profile)
+}
+
+// ProbeHosts probes hosts on a network interface through the scanner's
+// transport, reporting its progress to the scanner's Progress function.
+func (s *Scanner) ProbeHosts(ifaceDetails *InterfaceDetails, profile Profile) ([]HostResult, []net.IP, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := s.checkInterfaceUp(ifaceDetails.Name); err != nil {
+		return nil, nil, err
+	}
 
-	sem := make(chan struct{}, maxConcurrentScans)
+	type target struct {
+		ip      net.IP
+		isLocal bool
+	}
 
-	go handleResults(resultsChan, &activeHosts, &returnError, &mu)
+	var wg sync.WaitGroup
+	resultsChan := make(chan hostResult, profile.Concurrency)
+	done := make(chan struct{})
+	var activeHosts []HostResult
+	var allHosts []net.IP // Added to track all scanned hosts
+	var targets []target
+	var scanErrors []*ScanError
 
 	for i, ip := range ifaceDetails.IPs {
 		subnetBits := ifaceDetails.SubnetBits[i]
@@ -208,23 +369,58 @@ func ProbeHosts(ifaceDetails *InterfaceDetails, initialTimeout time.Duration) ([
 		isLocal := isLocalNetwork(subnetBits)
 
 		// Store all IPs being scanned
-		mu.Lock()
 		allHosts = append(allHosts, ipList...)
-		mu.Unlock()
-
 		for _, targetIP := range ipList {
-			wg.Add(1)
-			sem <- struct{}{}
+			targets = append(targets, target{ip: targetIP, isLocal: isLocal})
+		}
+	}
+
+	if profile.Shuffle {
+		rand.Shuffle(len(targets), func(i, j int) {
+			targets[i], targets[j] = targets[j], targets[i]
+		})
+	}
 
-			go func(ip net.IP, isLocal bool) {
-				defer func() { <-sem }()
-				probeHost(ip, initialTimeout, isLocal, resultsChan, &wg)
-			}(targetIP, isLocal)
+	var throttle <-chan time.Time
+	if profile.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(profile.Rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	sem := make(chan struct{}, profile.Concurrency)
+
+	var progress *progressTracker
+	if s.Progress != nil {
+		progress = newProgressTracker(s.Progress, s.qualifiedName(ifaceDetails.Name), len(targets))
+	}
+	go handleResults(resultsChan, &activeHosts, &scanErrors, progress, done)
+
+	for _, t := range targets {
+		if throttle != nil {
+			<-throttle
 		}
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(ip net.IP, isLocal bool) {
+			defer func() { <-sem }()
+			s.probeHost(ip, profile, isLocal, resultsChan, &wg)
+		}(t.ip, t.isLocal)
 	}
 
 	wg.Wait()
 	close(resultsChan)
+	<-done
+	progress.finish()
+
+	sortHosts(activeHosts)
 
-	return activeHosts, allHosts, returnError
+	if len(scanErrors) > 0 {
+		for _, scanErr := range scanErrors {
+			scanErr.Interface = ifaceDetails.Name
+		}
+		return activeHosts, allHosts, &InterfaceErrors{Interface: ifaceDetails.Name, Errors: scanErrors}
+	}
+	return activeHosts, allHosts, nil
 }
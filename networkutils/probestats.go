@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Process-wide probe counters, by method and result, for monitoring.
+*/
+
+package networkutils
+
+import (
+	"sort"
+	"sync"
+)
+
+// Probe results counted by ProbeCounts.
+const (
+	ProbeReply   = "reply"
+	ProbeNoReply = "no_reply"
+	ProbeError   = "error"
+)
+
+// ProbeCount is the number of probes of a method that ended with a result.
+type ProbeCount struct {
+	Method string
+	Result string
+	Count  uint64
+}
+
+type probeKey struct {
+	method string
+	result string
+}
+
+var (
+	probeCounts   = make(map[probeKey]uint64)
+	probeCountsMu sync.Mutex
+)
+
+// countProbe records the outcome of one probe of a host.
+func countProbe(method string, active bool, err error) {
+	result := ProbeNoReply
+	switch {
+	case active:
+		result = ProbeReply
+	case err != nil:
+		result = ProbeError
+	}
+	probeCountsMu.Lock()
+	probeCounts[probeKey{method, result}]++
+	probeCountsMu.Unlock()
+}
+
+// ProbeCounts returns the number of probes made since the process started,
+// sorted by method and result.
+func ProbeCounts() []ProbeCount {
+	probeCountsMu.Lock()
+	counts := make([]ProbeCount, 0, len(probeCounts))
+	for k, n := range probeCounts {
+		counts = append(counts, ProbeCount{Method: k.method, Result: k.result, Count: n})
+	}
+	probeCountsMu.Unlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Method != counts[j].Method {
+			return counts[i].Method < counts[j].Method
+		}
+		return counts[i].Result < counts[j].Result
+	})
+	return counts
+}
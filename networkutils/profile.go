@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Scan profiles bundle the knobs that trade speed for accuracy and noise:
+   timeout, retries, concurrency, probe methods and probe rate.
+*/
+
+package networkutils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"goscan/config"
+)
+
+// Probe methods a profile can enable.
+const (
+	MethodARP  = "arp"
+	MethodICMP = "icmp"
+	MethodTCP  = "tcp"
+)
+
+// DefaultProfileName is the profile used when none is selected.
+const DefaultProfileName = "default"
+
+// Profile describes how hosts are probed.
+type Profile struct {
+	Name         string        `json:"name"`
+	Timeout      time.Duration `json:"timeout"`     // initial ICMP timeout, halved for ARP
+	Retries      int           `json:"retries"`     // ICMP attempts, doubling the timeout each time
+	Concurrency  int           `json:"concurrency"` // hosts probed in parallel
+	Methods      []string      `json:"methods"`     // subset of arp, icmp, tcp, tried in that order
+	Rate         int           `json:"rate"`        // hosts started per second, 0 for unlimited
+	Jitter       time.Duration `json:"jitter"`      // maximum random delay before each ICMP attempt
+	Shuffle      bool          `json:"shuffle"`     // probe targets in random order
+	Ports        []int         `json:"ports,omitempty"`
+	ResolveNames bool          `json:"resolveNames"` // look up reverse DNS names of the active hosts
+}
+
+// builtinProfiles are always available. A zero timeout in the default
+// profile means the configured server timeout is used.
+var builtinProfiles = map[string]Profile{
+	DefaultProfileName: {
+		Retries:     maxRetries,
+		Concurrency: maxConcurrentScans,
+		Methods:     []string{MethodARP, MethodICMP},
+		Jitter:      100 * time.Millisecond,
+	},
+	"fast": {
+		Timeout:     200 * time.Millisecond,
+		Retries:     1,
+		Concurrency: maxConcurrentScans,
+		Methods:     []string{MethodARP, MethodICMP},
+	},
+	"thorough": {
+		Timeout:     time.Second,
+		Retries:     3,
+		Concurrency: 256,
+		Methods:     []string{MethodARP, MethodICMP, MethodTCP},
+		Jitter:      100 * time.Millisecond,
+	},
+	"stealth": {
+		Timeout:     time.Second,
+		Retries:     1,
+		Concurrency: 8,
+		Methods:     []string{MethodARP, MethodICMP},
+		Rate:        20,
+		Jitter:      500 * time.Millisecond,
+		Shuffle:     true,
+	},
+	"paranoid": {
+		Timeout:     3 * time.Second,
+		Retries:     1,
+		Concurrency: 1,
+		Methods:     []string{MethodARP, MethodICMP},
+		Rate:        1,
+		Jitter:      2 * time.Second,
+		Shuffle:     true,
+	},
+}
+
+// ProfileNames lists the built-in and user-defined profile names.
+func ProfileNames() []string {
+	seen := make(map[string]bool)
+	for name := range builtinProfiles {
+		seen[name] = true
+	}
+	for _, p := range config.GetServerConfig().Profiles {
+		seen[p.Name] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProfile resolves a profile by name. User-defined profiles take
+// precedence over built-in ones of the same name.
+func LookupProfile(name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfileName
+	}
+	cfg := config.GetServerConfig()
+	profile, err := lookupProfile(name, cfg, nil)
+	if err != nil {
+		return Profile{}, err
+	}
+	if profile.Timeout == 0 {
+		profile.Timeout = cfg.Timeout
+	}
+	return profile, nil
+}
+
+// ProfileForInterface returns the profile assigned to an interface in the
+// server configuration, falling back to the configured default profile.
+func ProfileForInterface(ifaceName string) (Profile, error) {
+	return DefaultScanner.ProfileForInterface(ifaceName)
+}
+
+// ProfileForInterface looks the interface up by its namespace-qualified
+// name ("blue/eth0") first, then by its bare name.
+func (s *Scanner) ProfileForInterface(ifaceName string) (Profile, error) {
+	cfg := config.GetServerConfig()
+	if name, ok := cfg.InterfaceProfiles[s.qualifiedName(ifaceName)]; ok {
+		return LookupProfile(name)
+	}
+	if name, ok := cfg.InterfaceProfiles[ifaceName]; ok {
+		return LookupProfile(name)
+	}
+	return LookupProfile(cfg.Profile)
+}
+
+func lookupProfile(name string, cfg config.ServerConfig, visiting []string) (Profile, error) {
+	for _, v := range visiting {
+		if v == name {
+			return Profile{}, fmt.Errorf("scan profile %q extends itself", name)
+		}
+	}
+
+	for _, pc := range cfg.Profiles {
+		if pc.Name != name {
+			continue
+		}
+		base := pc.Extends
+		if base == "" || base == name {
+			// A user profile may shadow a built-in of the same name
+			base = DefaultProfileName
+			if _, ok := builtinProfiles[name]; ok {
+				return mergeProfile(builtinProfiles[name], pc)
+			}
+		}
+		parent, err := lookupProfile(base, cfg, append(visiting, name))
+		if err != nil {
+			return Profile{}, err
+		}
+		return mergeProfile(parent, pc)
+	}
+
+	if p, ok := builtinProfiles[name]; ok {
+		p.Name = name
+		p.Methods = append([]string(nil), p.Methods...)
+		return p, nil
+	}
+	return Profile{}, fmt.Errorf("unknown scan profile %q (available: %s)", name, strings.Join(ProfileNames(), ", "))
+}
+
+// mergeProfile overlays the non-zero fields of a user profile on its parent.
+func mergeProfile(parent Profile, pc config.ProfileConfig) (Profile, error) {
+	p := parent
+	p.Name = pc.Name
+	if pc.Timeout != 0 {
+		p.Timeout = time.Duration(pc.Timeout)
+	}
+	if pc.Retries != 0 {
+		p.Retries = pc.Retries
+	}
+	if pc.Concurrency != 0 {
+		p.Concurrency = pc.Concurrency
+	}
+	if len(pc.Methods) > 0 {
+		p.Methods = pc.Methods
+	}
+	if pc.Rate != 0 {
+		p.Rate = pc.Rate
+	}
+	if pc.Jitter != 0 {
+		p.Jitter = time.Duration(pc.Jitter)
+	}
+	if pc.Shuffle {
+		p.Shuffle = true
+	}
+	if len(pc.Ports) > 0 {
+		p.Ports = pc.Ports
+	}
+	if pc.ResolveNames != nil {
+		p.ResolveNames = *pc.ResolveNames
+	}
+	return p, p.Validate()
+}
+
+// Validate checks that the profile can be used for a scan.
+func (p Profile) Validate() error {
+	if p.Retries < 1 {
+		return fmt.Errorf("scan profile %q: retries must be at least 1", p.Name)
+	}
+	if p.Concurrency < 1 {
+		return fmt.Errorf("scan profile %q: concurrency must be at least 1", p.Name)
+	}
+	if p.Rate < 0 {
+		return fmt.Errorf("scan profile %q: rate must not be negative", p.Name)
+	}
+	if len(p.Methods) == 0 {
+		return fmt.Errorf("scan profile %q: at least one probe method is required", p.Name)
+	}
+	for _, m := range p.Methods {
+		switch m {
+		case MethodARP, MethodICMP, MethodTCP:
+		default:
+			return fmt.Errorf("scan profile %q: unknown probe method %q", p.Name, m)
+		}
+	}
+	return nil
+}
+
+// Uses reports whether the profile enables the given probe method.
+func (p Profile) Uses(method string) bool {
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// ports returns the TCP ports probed by the tcp method.
+func (p Profile) ports() []int {
+	if len(p.Ports) > 0 {
+		return p.Ports
+	}
+	return commonPorts
+}
@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Scan profile inheritance and validation.
+*/
+
+package networkutils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"goscan/config"
+)
+
+func TestMergeProfile(t *testing.T) {
+	off := false
+	on := true
+	parent := Profile{
+		Name:         "parent",
+		Timeout:      time.Second,
+		Retries:      2,
+		Concurrency:  64,
+		Methods:      []string{MethodARP, MethodICMP},
+		Rate:         10,
+		Jitter:       100 * time.Millisecond,
+		Ports:        []int{22},
+		ResolveNames: true,
+	}
+
+	tests := []struct {
+		name string
+		pc   config.ProfileConfig
+		want Profile
+	}{
+		{
+			name: "zero fields are inherited",
+			pc:   config.ProfileConfig{Name: "child"},
+			want: Profile{
+				Name:         "child",
+				Timeout:      time.Second,
+				Retries:      2,
+				Concurrency:  64,
+				Methods:      []string{MethodARP, MethodICMP},
+				Rate:         10,
+				Jitter:       100 * time.Millisecond,
+				Ports:        []int{22},
+				ResolveNames: true,
+			},
+		},
+		{
+			name: "set fields override",
+			pc: config.ProfileConfig{
+				Name:        "child",
+				Timeout:     config.Duration(3 * time.Second),
+				Retries:     1,
+				Concurrency: 4,
+				Methods:     []string{MethodTCP},
+				Rate:        1,
+				Jitter:      config.Duration(time.Second),
+				Shuffle:     true,
+				Ports:       []int{80, 443},
+			},
+			want: Profile{
+				Name:         "child",
+				Timeout:      3 * time.Second,
+				Retries:      1,
+				Concurrency:  4,
+				Methods:      []string{MethodTCP},
+				Rate:         1,
+				Jitter:       time.Second,
+				Shuffle:      true,
+				Ports:        []int{80, 443},
+				ResolveNames: true,
+			},
+		},
+		{
+			name: "resolveNames false turns off the inherited lookups",
+			pc:   config.ProfileConfig{Name: "child", ResolveNames: &off},
+			want: Profile{
+				Name:        "child",
+				Timeout:     time.Second,
+				Retries:     2,
+				Concurrency: 64,
+				Methods:     []string{MethodARP, MethodICMP},
+				Rate:        10,
+				Jitter:      100 * time.Millisecond,
+				Ports:       []int{22},
+			},
+		},
+		{
+			name: "resolveNames true",
+			pc:   config.ProfileConfig{Name: "child", Rate: 5, ResolveNames: &on},
+			want: Profile{
+				Name:         "child",
+				Timeout:      time.Second,
+				Retries:      2,
+				Concurrency:  64,
+				Methods:      []string{MethodARP, MethodICMP},
+				Rate:         5,
+				Jitter:       100 * time.Millisecond,
+				Ports:        []int{22},
+				ResolveNames: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeProfile(parent, tt.pc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeProfile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeProfileInvalid(t *testing.T) {
+	parent := builtinProfiles[DefaultProfileName]
+	_, err := mergeProfile(parent, config.ProfileConfig{Name: "child", Methods: []string{"udp"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown probe method "udp"`) {
+		t.Fatalf("error = %v, want an unknown probe method error", err)
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	valid := Profile{Name: "p", Retries: 1, Concurrency: 1, Methods: []string{MethodARP}}
+
+	tests := []struct {
+		name   string
+		modify func(p *Profile)
+		err    string
+	}{
+		{name: "valid", modify: func(p *Profile) {}},
+		{name: "no retries", modify: func(p *Profile) { p.Retries = 0 }, err: "retries must be at least 1"},
+		{name: "no concurrency", modify: func(p *Profile) { p.Concurrency = 0 }, err: "concurrency must be at least 1"},
+		{name: "negative rate", modify: func(p *Profile) { p.Rate = -1 }, err: "rate must not be negative"},
+		{name: "no methods", modify: func(p *Profile) { p.Methods = nil }, err: "at least one probe method is required"},
+		{name: "unknown method", modify: func(p *Profile) { p.Methods = []string{MethodICMP, "syn"} }, err: `unknown probe method "syn"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			p.Methods = append([]string(nil), valid.Methods...)
+			tt.modify(&p)
+			err := p.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestBuiltinProfilesValid(t *testing.T) {
+	for name, p := range builtinProfiles {
+		p.Name = name
+		if err := p.Validate(); err != nil {
+			t.Errorf("built-in profile %s: %v", name, err)
+		}
+		if p.ResolveNames {
+			t.Errorf("built-in profile %s resolves names", name)
+		}
+	}
+}
+
+func TestLookupProfile(t *testing.T) {
+	saved := config.GetServerConfig()
+	t.Cleanup(func() { config.SetServerConfig(saved) })
+	cfg := saved
+	cfg.Timeout = 50 * time.Millisecond
+	cfg.Profiles = []config.ProfileConfig{
+		{Name: "quick", Extends: "fast", Retries: 2},
+		{Name: "quicker", Extends: "quick", Concurrency: 8},
+		{Name: "fast", Rate: 100},
+		{Name: "loop-a", Extends: "loop-b"},
+		{Name: "loop-b", Extends: "loop-a"},
+		{Name: "plain", Methods: []string{MethodICMP}},
+	}
+	config.SetServerConfig(cfg)
+
+	tests := []struct {
+		name  string
+		check func(t *testing.T, p Profile)
+		err   string
+	}{
+		{
+			// The user profile shadows the built-in one, extending it
+			name: "fast",
+			check: func(t *testing.T, p Profile) {
+				if p.Rate != 100 || p.Timeout != 200*time.Millisecond || p.Retries != 1 {
+					t.Errorf("got %+v", p)
+				}
+			},
+		},
+		{
+			name: "quicker",
+			check: func(t *testing.T, p Profile) {
+				if p.Concurrency != 8 || p.Retries != 2 || p.Rate != 100 {
+					t.Errorf("got %+v", p)
+				}
+			},
+		},
+		{
+			// Extends the default profile, whose zero timeout is the server's
+			name: "plain",
+			check: func(t *testing.T, p Profile) {
+				if p.Timeout != 50*time.Millisecond || p.Retries != maxRetries || !reflect.DeepEqual(p.Methods, []string{MethodICMP}) {
+					t.Errorf("got %+v", p)
+				}
+			},
+		},
+		{name: "loop-a", err: "extends itself"},
+		{name: "missing", err: `unknown scan profile "missing"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := LookupProfile(tt.name)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Name != tt.name {
+				t.Errorf("name = %q, want %q", p.Name, tt.name)
+			}
+			tt.check(t, p)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Scan progress: how many of an interface's addresses were probed, how
+   many hosts answered so far and when the scan should end.
+*/
+
+package networkutils
+
+import "time"
+
+// progressInterval is the least time between two progress reports of a scan.
+const progressInterval = 100 * time.Millisecond
+
+// Progress is a snapshot of the host probing of an interface.
+type Progress struct {
+	Interface string        `json:"interface"`
+	Total     int           `json:"total"`
+	Completed int           `json:"completed"`
+	Found     int           `json:"found"`
+	StartedAt time.Time     `json:"startedAt"`
+	Elapsed   time.Duration `json:"elapsedNs"`
+	// ETA is the estimated time left, zero until a first address is done.
+	ETA  time.Duration `json:"etaNs"`
+	Done bool          `json:"done"`
+}
+
+// Percent returns the share of addresses probed, from 0 to 100.
+func (p Progress) Percent() float64 {
+	if p.Total == 0 {
+		return 100
+	}
+	return float64(p.Completed) / float64(p.Total) * 100
+}
+
+// ProgressFunc receives the progress of the scans of a Scanner. It is
+// called at the start and end of every interface's probing and at most
+// every progressInterval in between, from the scanning goroutines.
+type ProgressFunc func(Progress)
+
+// progressTracker counts the probes of one interface. Its methods are only
+// called by the goroutine collecting the results.
+type progressTracker struct {
+	report   ProgressFunc
+	progress Progress
+	last     time.Time
+}
+
+func newProgressTracker(report ProgressFunc, iface string, total int) *progressTracker {
+	t := &progressTracker{report: report, progress: Progress{Interface: iface, Total: total, StartedAt: time.Now()}}
+	t.send()
+	return t
+}
+
+// add counts a probed address.
+func (t *progressTracker) add(found bool) {
+	if t == nil {
+		return
+	}
+	t.progress.Completed++
+	if found {
+		t.progress.Found++
+	}
+	if time.Since(t.last) >= progressInterval {
+		t.send()
+	}
+}
+
+// finish reports the end of the probing.
+func (t *progressTracker) finish() {
+	if t == nil {
+		return
+	}
+	t.progress.Done = true
+	t.progress.ETA = 0
+	t.send()
+}
+
+func (t *progressTracker) send() {
+	t.last = time.Now()
+	p := &t.progress
+	p.Elapsed = t.last.Sub(p.StartedAt)
+	if p.Completed > 0 && !p.Done {
+		p.ETA = p.Elapsed * time.Duration(p.Total-p.Completed) / time.Duration(p.Completed)
+	}
+	t.report(*p)
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Progress reports and time estimates.
+*/
+
+package networkutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressPercent(t *testing.T) {
+	tests := []struct {
+		p    Progress
+		want float64
+	}{
+		{Progress{Total: 0}, 100},
+		{Progress{Total: 4, Completed: 0}, 0},
+		{Progress{Total: 4, Completed: 1}, 25},
+		{Progress{Total: 4, Completed: 4}, 100},
+	}
+
+	for _, tt := range tests {
+		if got := tt.p.Percent(); got != tt.want {
+			t.Errorf("%+v.Percent() = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestProgressTracker(t *testing.T) {
+	var reports []Progress
+	tracker := newProgressTracker(func(p Progress) { reports = append(reports, p) }, "eth0", 4)
+	if len(reports) != 1 || reports[0].Completed != 0 || reports[0].ETA != 0 {
+		t.Fatalf("start reports = %+v, want one with nothing done and no ETA", reports)
+	}
+
+	// Reports closer than progressInterval are skipped
+	tracker.add(true)
+	if len(reports) != 1 {
+		t.Fatalf("%d reports, want the one at start", len(reports))
+	}
+
+	tracker.progress.StartedAt = time.Now().Add(-10 * time.Second)
+	tracker.last = time.Time{}
+	tracker.add(false)
+	p := reports[len(reports)-1]
+	if p.Completed != 2 || p.Found != 1 {
+		t.Errorf("completed %d, found %d, want 2 and 1", p.Completed, p.Found)
+	}
+	// Two addresses took ten seconds: the two left take about ten more
+	if p.ETA < 9*time.Second || p.ETA > 11*time.Second {
+		t.Errorf("ETA = %s, want about 10s", p.ETA)
+	}
+
+	tracker.finish()
+	p = reports[len(reports)-1]
+	if !p.Done || p.ETA != 0 {
+		t.Errorf("final report = %+v, want done without ETA", p)
+	}
+
+	// Scans without a progress function have no tracker
+	var none *progressTracker
+	none.add(true)
+	none.finish()
+}
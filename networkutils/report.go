@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Typed scan results shared by the CLI, the server and library users.
+
+   The JSON encoding of these types is a public interface described by
+   schema/scan-report.v1.schema.json. Adding optional fields is fine;
+   renaming, removing or changing the meaning of one requires bumping
+   ReportVersion and publishing a new schema.
+*/
+
+package networkutils
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"time"
+)
+
+// ReportVersion is the version of the ScanReport JSON format.
+const ReportVersion = 1
+
+// ReportSchema is the JSON schema of ScanReport, version ReportVersion.
+//
+//go:embed schema/scan-report.v1.schema.json
+var ReportSchema []byte
+
+// ScanReport is the result of scanning a set of interfaces.
+type ScanReport struct {
+	Version         int               `json:"version"`
+	StartedAt       time.Time         `json:"startedAt"`
+	Elapsed         time.Duration     `json:"elapsedNs"`
+	TotalIPsScanned int               `json:"totalIPsScanned"`
+	Interfaces      []InterfaceReport `json:"interfaces"`
+}
+
+// InterfaceReport is the result of scanning one interface.
+type InterfaceReport struct {
+	Name            string         `json:"name"`
+	Namespace       string         `json:"namespace,omitempty"`
+	Type            string         `json:"type,omitempty"`
+	MACAddress      string         `json:"macAddress"`
+	Addresses       []string       `json:"addresses"`
+	Profile         string         `json:"profile"`
+	TotalIPsScanned int            `json:"totalIPsScanned"`
+	Elapsed         time.Duration  `json:"elapsedNs,omitempty"`
+	ActiveHosts     []HostResult   `json:"activeHosts"`
+	Errors          []ErrorSummary `json:"errors,omitempty"`
+	// Unprobed lists the addresses whose probes failed, so that nothing is
+	// known of them. They are not counted in TotalIPsScanned.
+	Unprobed   []net.IP    `json:"unprobed,omitempty"`
+	LeaseCheck *LeaseCheck `json:"leaseCheck,omitempty"`
+
+	// Scanned holds every address probed, but the unprobed ones. It is not
+	// serialized, as it can be derived from Addresses and Unprobed.
+	Scanned []net.IP `json:"-"`
+}
+
+// HostResult describes a host that answered a probe.
+type HostResult struct {
+	IP     net.IP        `json:"ip"`
+	MAC    string        `json:"mac,omitempty"`
+	Method string        `json:"method"`
+	RTT    time.Duration `json:"rttNs,omitempty"`
+	Names  []string      `json:"names,omitempty"`
+	Lease  *Lease        `json:"lease,omitempty"`
+}
+
+// ReadReport decodes a JSON scan report, rejecting versions this package
+// does not understand.
+func ReadReport(r io.Reader) (*ScanReport, error) {
+	var report ScanReport
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		return nil, fmt.Errorf("invalid scan report: %w", err)
+	}
+	if report.Version != ReportVersion {
+		return nil, fmt.Errorf("unsupported scan report version %d (expected %d)", report.Version, ReportVersion)
+	}
+	return &report, nil
+}
+
+// QualifiedName is the interface name prefixed with its network namespace,
+// if any ("blue/eth0").
+func (r *InterfaceReport) QualifiedName() string {
+	if r.Namespace == "" {
+		return r.Name
+	}
+	return r.Namespace + "/" + r.Name
+}
+
+// ActiveIPs returns the addresses of the active hosts.
+func (r *InterfaceReport) ActiveIPs() []net.IP {
+	ips := make([]net.IP, len(r.ActiveHosts))
+	for i, host := range r.ActiveHosts {
+		ips[i] = host.IP
+	}
+	return ips
+}
+
+// InactiveHosts returns the scanned addresses that did not answer, sorted.
+func (r *InterfaceReport) InactiveHosts() []net.IP {
+	active := make(map[string]bool, len(r.ActiveHosts))
+	for _, host := range r.ActiveHosts {
+		active[host.IP.String()] = true
+	}
+
+	var inactive []net.IP
+	for _, ip := range r.scannedIPs() {
+		if !active[ip.String()] {
+			inactive = append(inactive, ip)
+		}
+	}
+	SortIPs(inactive)
+	return inactive
+}
+
+// scannedIPs returns Scanned, or regenerates it from Addresses and Unprobed
+// for reports that were decoded from JSON.
+func (r *InterfaceReport) scannedIPs() []net.IP {
+	if r.Scanned != nil {
+		return r.Scanned
+	}
+	if r.TotalIPsScanned == 0 && len(r.Errors) > 0 {
+		// The scan failed before probing anything
+		return nil
+	}
+	unprobed := r.unprobedSet()
+	var ips []net.IP
+	for _, cidr := range r.Addresses {
+		ip, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil || ip.To4() == nil {
+			continue
+		}
+		ones, _ := ipnet.Mask.Size()
+		for _, scanned := range generateIPs(ip.To4(), ones) {
+			if !unprobed[scanned.String()] {
+				ips = append(ips, scanned)
+			}
+		}
+	}
+	return ips
+}
+
+// unprobedSet returns Unprobed as a set of address strings.
+func (r *InterfaceReport) unprobedSet() map[string]bool {
+	set := make(map[string]bool, len(r.Unprobed))
+	for _, ip := range r.Unprobed {
+		set[ip.String()] = true
+	}
+	return set
+}
+
+// ProbeFailed reports whether the probes of ip failed, so that the scan
+// tells nothing of it: it is neither up nor down.
+func (r *InterfaceReport) ProbeFailed(ip net.IP) bool {
+	for _, unprobed := range r.Unprobed {
+		if unprobed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Host returns the result for ip, if it was found active.
+func (r *InterfaceReport) Host(ip net.IP) (HostResult, bool) {
+	for _, host := range r.ActiveHosts {
+		if host.IP.Equal(ip) {
+			return host, true
+		}
+	}
+	return HostResult{}, false
+}
+
+// Interface returns the report of an interface by qualified name.
+func (r *ScanReport) Interface(qualifiedName string) (*InterfaceReport, bool) {
+	for i := range r.Interfaces {
+		if r.Interfaces[i].QualifiedName() == qualifiedName {
+			return &r.Interfaces[i], true
+		}
+	}
+	return nil, false
+}
+
+// ActiveHostCount returns the number of active hosts over all interfaces.
+func (r *ScanReport) ActiveHostCount() int {
+	total := 0
+	for _, iface := range r.Interfaces {
+		total += len(iface.ActiveHosts)
+	}
+	return total
+}
+
+// sortHosts orders host results by address.
+func sortHosts(hosts []HostResult) {
+	sort.Slice(hosts, func(i, j int) bool {
+		return bytes.Compare(hosts[i].IP.To16(), hosts[j].IP.To16()) < 0
+	})
+}
+
+// sortInterfaces orders interface reports by qualified name.
+func sortInterfaces(ifaces []InterfaceReport) {
+	sort.Slice(ifaces, func(i, j int) bool {
+		return ifaces[i].QualifiedName() < ifaces[j].QualifiedName()
+	})
+}
+
+// MergeReports combines reports of separate scans, such as one per network
+// namespace, into a single report.
+func MergeReports(reports ...*ScanReport) *ScanReport {
+	merged := &ScanReport{Version: ReportVersion, Interfaces: []InterfaceReport{}}
+	var finishedAt time.Time
+	for _, report := range reports {
+		if report == nil {
+			continue
+		}
+		if merged.StartedAt.IsZero() || report.StartedAt.Before(merged.StartedAt) {
+			merged.StartedAt = report.StartedAt
+		}
+		if end := report.StartedAt.Add(report.Elapsed); end.After(finishedAt) {
+			finishedAt = end
+		}
+		merged.TotalIPsScanned += report.TotalIPsScanned
+		merged.Interfaces = append(merged.Interfaces, report.Interfaces...)
+	}
+	if !merged.StartedAt.IsZero() {
+		merged.Elapsed = finishedAt.Sub(merged.StartedAt)
+	}
+	sortInterfaces(merged.Interfaces)
+	return merged
+}
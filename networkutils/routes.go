@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Default gateways, read from the kernel routing tables in procfs. The
+   per-thread view is used so that a thread that joined a network namespace
+   sees the routes of that namespace. Where procfs is not available no
+   gateway is reported.
+*/
+
+package networkutils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"strings"
+)
+
+// Routing tables of the calling thread's network namespace.
+var (
+	ipv4RouteFile = "/proc/thread-self/net/route"
+	ipv6RouteFile = "/proc/thread-self/net/ipv6_route"
+)
+
+// defaultGateways returns the next hops of the default routes by interface
+// name.
+func defaultGateways() map[string][]net.IP {
+	gateways := make(map[string][]net.IP)
+	readRoutes(ipv4RouteFile, func(fields []string) {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		if len(fields) < 8 || fields[0] == "Iface" || fields[1] != "00000000" || fields[7] != "00000000" {
+			return
+		}
+		gw, err := hex.DecodeString(fields[2])
+		if err != nil || len(gw) != net.IPv4len {
+			return
+		}
+		// Printed as a number in host byte order
+		ip := make(net.IP, net.IPv4len)
+		binary.NativeEndian.PutUint32(ip, binary.BigEndian.Uint32(gw))
+		if !ip.IsUnspecified() {
+			gateways[fields[0]] = append(gateways[fields[0]], ip)
+		}
+	})
+	readRoutes(ipv6RouteFile, func(fields []string) {
+		// Destination PrefixLen Source SourcePrefixLen NextHop Metric RefCnt Use Flags Iface
+		if len(fields) < 10 || strings.Trim(fields[0], "0") != "" || fields[1] != "00" {
+			return
+		}
+		gw, err := hex.DecodeString(fields[4])
+		if err != nil || len(gw) != net.IPv6len {
+			return
+		}
+		ip := net.IP(gw)
+		if !ip.IsUnspecified() {
+			gateways[fields[9]] = append(gateways[fields[9]], ip)
+		}
+	})
+	return gateways
+}
+
+// readRoutes calls fn with the fields of every line of a routing table.
+func readRoutes(path string, fn func(fields []string)) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fn(strings.Fields(scanner.Text()))
+	}
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Default gateways from procfs routing tables.
+*/
+
+package networkutils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// routeHex formats an IPv4 address as the kernel prints it in
+// /proc/net/route: the address as a number in host byte order.
+func routeHex(s string) string {
+	return fmt.Sprintf("%08X", binary.NativeEndian.Uint32(net.ParseIP(s).To4()))
+}
+
+// setRouteFiles points the routing tables at files with the given content,
+// or at missing files where it is empty.
+func setRouteFiles(t *testing.T, ipv4, ipv6 string) {
+	t.Helper()
+	dir := t.TempDir()
+	oldIPv4, oldIPv6 := ipv4RouteFile, ipv6RouteFile
+	t.Cleanup(func() { ipv4RouteFile, ipv6RouteFile = oldIPv4, oldIPv6 })
+	ipv4RouteFile = filepath.Join(dir, "route")
+	ipv6RouteFile = filepath.Join(dir, "ipv6_route")
+	for path, content := range map[string]string{ipv4RouteFile: ipv4, ipv6RouteFile: ipv6} {
+		if content == "" {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDefaultGateways(t *testing.T) {
+	ipv4Route := func(iface, dest, gw, mask string) string {
+		return strings.Join([]string{iface, dest, gw, "0003", "0", "0", "100", mask, "0", "0", "0"}, "\t") + "\n"
+	}
+	ipv4Header := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"
+	const zero = "00000000000000000000000000000000"
+	ipv6Route := func(dest, prefix, nexthop, iface string) string {
+		return strings.Join([]string{dest, prefix, zero, "00", nexthop, "00000400", "00000001", "00000000", "00000003", iface}, " ") + "\n"
+	}
+
+	tests := []struct {
+		name       string
+		ipv4, ipv6 string
+		want       map[string][]string
+	}{
+		{
+			name: "ipv4 default route",
+			ipv4: ipv4Header +
+				ipv4Route("eth0", "00000000", routeHex("192.0.2.1"), "00000000") +
+				ipv4Route("eth0", routeHex("192.0.2.0"), "00000000", routeHex("255.255.255.0")),
+			want: map[string][]string{"eth0": {"192.0.2.1"}},
+		},
+		{
+			// A default route without a gateway, such as on a point-to-point
+			// link, and a gateway for another prefix are not default gateways
+			name: "ipv4 routes left out",
+			ipv4: ipv4Header +
+				ipv4Route("wg0", "00000000", "00000000", "00000000") +
+				ipv4Route("eth1", routeHex("198.51.100.0"), routeHex("192.0.2.254"), routeHex("255.255.255.0")) +
+				ipv4Route("eth1", "00000000", "nothex!!", "00000000") +
+				"eth1\t00000000\n",
+			want: map[string][]string{},
+		},
+		{
+			name: "ipv4 on several interfaces",
+			ipv4: ipv4Header +
+				ipv4Route("eth0", "00000000", routeHex("192.0.2.1"), "00000000") +
+				ipv4Route("eth1", "00000000", routeHex("198.51.100.1"), "00000000") +
+				ipv4Route("eth0", "00000000", routeHex("192.0.2.2"), "00000000"),
+			want: map[string][]string{"eth0": {"192.0.2.1", "192.0.2.2"}, "eth1": {"198.51.100.1"}},
+		},
+		{
+			name: "ipv6 default route",
+			ipv6: ipv6Route(zero, "00", "fd000000000000000000000000000001", "eth0") +
+				ipv6Route("fd000000000000000000000000000000", "40", zero, "eth0"),
+			want: map[string][]string{"eth0": {"fd00::1"}},
+		},
+		{
+			// The kernel lists unreachable and reject routes with no next hop
+			name: "ipv6 routes left out",
+			ipv6: ipv6Route(zero, "00", zero, "lo") +
+				ipv6Route("20010db8000000000000000000000000", "20", "fe800000000000000000000000000001", "eth0") +
+				ipv6Route(zero, "00", "fe80", "eth0"),
+			want: map[string][]string{},
+		},
+		{
+			name: "both families",
+			ipv4: ipv4Header + ipv4Route("eth0", "00000000", routeHex("192.0.2.1"), "00000000"),
+			ipv6: ipv6Route(zero, "00", "fe800000000000000000000000000001", "eth0"),
+			want: map[string][]string{"eth0": {"192.0.2.1", "fe80::1"}},
+		},
+		{
+			name: "no routing tables",
+			want: map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRouteFiles(t, tt.ipv4, tt.ipv6)
+			got := map[string][]string{}
+			for iface, ips := range defaultGateways() {
+				for _, ip := range ips {
+					got[iface] = append(got[iface], ip.String())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("defaultGateways() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteHex(t *testing.T) {
+	// As printed on a little-endian machine such as x86-64
+	want := "010200C0"
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		want = "C0000201"
+	}
+	if got := routeHex("192.0.2.1"); got != want {
+		t.Errorf("routeHex(192.0.2.1) = %s, want %s", got, want)
+	}
+}
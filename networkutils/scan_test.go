@@ -0,0 +1,372 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Host probing and interface scanning against a simulated network.
+*/
+
+package networkutils_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"goscan/config"
+	"goscan/networkutils"
+	"goscan/networkutils/simnet"
+)
+
+// testProfile probes quickly, without jitter, so that the simulated hosts
+// that do not answer cost a few milliseconds.
+func testProfile(methods ...string) networkutils.Profile {
+	return networkutils.Profile{
+		Name:        "test",
+		Timeout:     20 * time.Millisecond,
+		Retries:     1,
+		Concurrency: 16,
+		Methods:     methods,
+		Ports:       []int{22},
+	}
+}
+
+// newTestNetwork returns a network with eth0 on 192.0.2.8/29, whose
+// addresses 192.0.2.9-14 are:
+//
+//	.9   answers ARP
+//	.10  eth0 itself, no host
+//	.11  answers ICMP only
+//	.12  answers neither, but refuses TCP connections
+//	.13  every probe fails with a permission error
+//	.14  no host
+func newTestNetwork(t *testing.T) *simnet.Network {
+	t.Helper()
+	n := simnet.New(1)
+	if err := n.AddInterface("eth0", "02:00:00:00:00:01", "192.0.2.10/29"); err != nil {
+		t.Fatal(err)
+	}
+	n.AddHost(simnet.Host{IP: net.ParseIP("192.0.2.9"), MAC: mustMAC(t, "02:00:00:00:00:09"), ARP: true, ICMP: true})
+	n.AddHost(simnet.Host{IP: net.ParseIP("192.0.2.11"), ICMP: true})
+	n.AddHost(simnet.Host{IP: net.ParseIP("192.0.2.12")})
+	n.AddHost(simnet.Host{IP: net.ParseIP("192.0.2.13"), Err: &os.SyscallError{Syscall: "sendto", Err: syscall.EPERM}})
+	return n
+}
+
+func mustMAC(t *testing.T, s string) net.HardwareAddr {
+	t.Helper()
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mac
+}
+
+// setConfig replaces the server configuration for the duration of a test.
+func setConfig(t *testing.T, cfg config.ServerConfig) {
+	t.Helper()
+	saved := config.GetServerConfig()
+	config.SetServerConfig(cfg)
+	t.Cleanup(func() { config.SetServerConfig(saved) })
+}
+
+// activeByMethod maps the address of every active host to its method.
+func activeByMethod(hosts []networkutils.HostResult) map[string]string {
+	m := make(map[string]string, len(hosts))
+	for _, h := range hosts {
+		m[h.IP.String()] = h.Method
+	}
+	return m
+}
+
+func ipStrings(ips []net.IP) []string {
+	s := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		s = append(s, ip.String())
+	}
+	return s
+}
+
+func TestProbeHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		methods []string
+		hosts   func(n *simnet.Network)
+		active  map[string]string
+		failed  []string
+	}{
+		{
+			name:    "arp and icmp",
+			methods: []string{networkutils.MethodARP, networkutils.MethodICMP},
+			active:  map[string]string{"192.0.2.9": "arp", "192.0.2.11": "icmp"},
+			failed:  []string{"192.0.2.13"},
+		},
+		{
+			name:    "refused connection counts as up",
+			methods: []string{networkutils.MethodARP, networkutils.MethodICMP, networkutils.MethodTCP},
+			active:  map[string]string{"192.0.2.9": "arp", "192.0.2.11": "icmp", "192.0.2.12": "tcp"},
+			failed:  []string{"192.0.2.13"},
+		},
+		{
+			name:    "profile without arp",
+			methods: []string{networkutils.MethodICMP},
+			active:  map[string]string{"192.0.2.9": "icmp", "192.0.2.11": "icmp"},
+			failed:  []string{"192.0.2.13"},
+		},
+		{
+			name:    "profile with arp only",
+			methods: []string{networkutils.MethodARP},
+			active:  map[string]string{"192.0.2.9": "arp"},
+			failed:  []string{"192.0.2.13"},
+		},
+		{
+			name:    "no reply",
+			methods: []string{networkutils.MethodARP, networkutils.MethodICMP, networkutils.MethodTCP},
+			hosts: func(n *simnet.Network) {
+				for _, ip := range []string{"192.0.2.9", "192.0.2.11", "192.0.2.12", "192.0.2.13"} {
+					n.RemoveHost(net.ParseIP(ip))
+				}
+			},
+			active: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(t)
+			if tt.hosts != nil {
+				tt.hosts(n)
+			}
+			s := networkutils.NewScanner(n)
+			iface, err := s.GetInterfaceByName("eth0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			active, all, err := s.ProbeHosts(iface, testProfile(tt.methods...))
+
+			if got := activeByMethod(active); !reflect.DeepEqual(got, tt.active) {
+				t.Errorf("active hosts = %v, want %v", got, tt.active)
+			}
+			if len(all) != 6 {
+				t.Errorf("scanned %d addresses, want 6", len(all))
+			}
+			var ifaceErrs *networkutils.InterfaceErrors
+			if tt.failed == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &ifaceErrs) {
+				t.Fatalf("error = %v, want *InterfaceErrors", err)
+			}
+			var failed []net.IP
+			for _, scanErr := range ifaceErrs.Errors {
+				failed = append(failed, scanErr.Host)
+				if !errors.Is(scanErr, networkutils.ErrPermission) {
+					t.Errorf("error for %s = %v, want a permission error", scanErr.Host, scanErr)
+				}
+			}
+			if got := ipStrings(failed); !reflect.DeepEqual(got, tt.failed) {
+				t.Errorf("failed probes = %v, want %v", got, tt.failed)
+			}
+		})
+	}
+}
+
+func TestProbeHostsInterfaceDown(t *testing.T) {
+	n := newTestNetwork(t)
+	s := networkutils.NewScanner(n)
+	iface, err := s.GetInterfaceByName("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.SetLinkUp("eth0", false); err != nil {
+		t.Fatal(err)
+	}
+
+	active, all, err := s.ProbeHosts(iface, testProfile(networkutils.MethodARP))
+	if !errors.Is(err, networkutils.ErrInterfaceDown) {
+		t.Fatalf("error = %v, want an interface_down error", err)
+	}
+	if active != nil || all != nil {
+		t.Errorf("got results %v, %v from a downed interface", active, all)
+	}
+}
+
+func TestProbeHostsInvalidProfile(t *testing.T) {
+	s := networkutils.NewScanner(newTestNetwork(t))
+	iface, err := s.GetInterfaceByName("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.ProbeHosts(iface, testProfile("udp")); err == nil {
+		t.Fatal("expected an error for an unknown probe method")
+	}
+}
+
+func TestFetchAllNetworkData(t *testing.T) {
+	n := newTestNetwork(t)
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(n.AddTypedInterface("wg0", networkutils.TypeWireGuard, "", "198.51.100.1/30"))
+	must(n.AddTypedInterface("docker0", networkutils.TypeBridge, "02:00:00:00:00:02", "203.0.113.1/30"))
+	must(n.AddInterface("eth1", "02:00:00:00:00:03", "10.0.0.1/21"))
+	must(n.AddInterface("eth2", "02:00:00:00:00:04", "10.1.0.1/30"))
+	must(n.SetLinkUp("eth2", false))
+	must(n.AddInterface("eth3", "02:00:00:00:00:05"))
+	n.AddHost(simnet.Host{IP: net.ParseIP("198.51.100.2"), ICMP: true})
+
+	base := config.GetServerConfig()
+	base.Profile = "sim"
+	base.Profiles = []config.ProfileConfig{
+		{Name: "sim", Extends: "fast", Timeout: config.Duration(20 * time.Millisecond)},
+		{Name: "quick", Extends: "sim", Methods: []string{networkutils.MethodICMP}},
+	}
+	base.InterfaceProfiles = map[string]string{"wg0": "quick"}
+	base.IncludeInterfaces = nil
+	base.ExcludeInterfaces = config.DefaultExcludeInterfaces
+	base.InterfaceTypes = nil
+	base.MaxSubnetSize = 1024
+
+	tests := []struct {
+		name    string
+		config  func(cfg *config.ServerConfig)
+		scanned []string
+		total   int
+	}{
+		{
+			// docker0 by the default exclusions, eth1 by its size, eth2
+			// down and eth3 without an IPv4 address
+			name:    "defaults",
+			scanned: []string{"eth0", "wg0"},
+			total:   5 + 2,
+		},
+		{
+			name:    "include",
+			config:  func(cfg *config.ServerConfig) { cfg.IncludeInterfaces = []string{"eth*"} },
+			scanned: []string{"eth0"},
+			total:   5,
+		},
+		{
+			name:    "exclude replaces the defaults",
+			config:  func(cfg *config.ServerConfig) { cfg.ExcludeInterfaces = []string{"wg*"} },
+			scanned: []string{"docker0", "eth0"},
+			total:   5 + 2,
+		},
+		{
+			name:    "type",
+			config:  func(cfg *config.ServerConfig) { cfg.InterfaceTypes = []string{networkutils.TypeWireGuard} },
+			scanned: []string{"wg0"},
+			total:   2,
+		},
+		{
+			name:    "subnet size",
+			config:  func(cfg *config.ServerConfig) { cfg.MaxSubnetSize = 4 },
+			scanned: []string{"wg0"},
+			total:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			if tt.config != nil {
+				tt.config(&cfg)
+			}
+			setConfig(t, cfg)
+
+			report, err := networkutils.NewScanner(n).FetchAllNetworkData()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, iface := range report.Interfaces {
+				names = append(names, iface.Name)
+				want := "sim"
+				if iface.Name == "wg0" {
+					want = "quick"
+				}
+				if iface.Profile != want {
+					t.Errorf("%s scanned with profile %q, want %s", iface.Name, iface.Profile, want)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.scanned) {
+				t.Errorf("scanned interfaces = %v, want %v", names, tt.scanned)
+			}
+			if report.TotalIPsScanned != tt.total {
+				t.Errorf("TotalIPsScanned = %d, want %d", report.TotalIPsScanned, tt.total)
+			}
+		})
+	}
+}
+
+func TestScanInterface(t *testing.T) {
+	s := networkutils.NewScanner(newTestNetwork(t))
+	iface, err := s.GetInterfaceByName("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := s.ScanInterface(iface, testProfile(networkutils.MethodARP, networkutils.MethodICMP))
+
+	if report.Name != "eth0" || report.Profile != "test" || !reflect.DeepEqual(report.Addresses, []string{"192.0.2.10/29"}) {
+		t.Errorf("report = %+v", report)
+	}
+	// 192.0.2.13 could not be probed: it is neither scanned nor inactive
+	if report.TotalIPsScanned != 5 {
+		t.Errorf("TotalIPsScanned = %d, want 5", report.TotalIPsScanned)
+	}
+	if got, want := ipStrings(report.Unprobed), []string{"192.0.2.13"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unprobed = %v, want %v", got, want)
+	}
+	if !report.ProbeFailed(net.ParseIP("192.0.2.13")) {
+		t.Error("ProbeFailed(192.0.2.13) = false, want true")
+	}
+	host, ok := report.Host(net.ParseIP("192.0.2.9"))
+	if !ok || host.MAC != "02:00:00:00:00:09" || host.Method != networkutils.MethodARP {
+		t.Errorf("192.0.2.9 = %+v, %v, want found by ARP with its MAC", host, ok)
+	}
+	want := []string{"192.0.2.10", "192.0.2.12", "192.0.2.14"}
+	if got := ipStrings(report.InactiveHosts()); !reflect.DeepEqual(got, want) {
+		t.Errorf("InactiveHosts = %v, want %v", got, want)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Kind != networkutils.ErrKindPermission || report.Errors[0].Count != 1 {
+		t.Errorf("Errors = %+v, want one permission error", report.Errors)
+	}
+}
+
+func TestScanInterfaceResolveNames(t *testing.T) {
+	n := newTestNetwork(t)
+	n.AddHost(simnet.Host{IP: net.ParseIP("192.0.2.9"), MAC: mustMAC(t, "02:00:00:00:00:09"), ARP: true, Names: []string{"printer.example."}})
+	s := networkutils.NewScanner(n)
+	iface, err := s.GetInterfaceByName("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profile := testProfile(networkutils.MethodARP)
+	for _, resolve := range []bool{false, true} {
+		profile.ResolveNames = resolve
+		report := s.ScanInterface(iface, profile)
+		host, ok := report.Host(net.ParseIP("192.0.2.9"))
+		if !ok {
+			t.Fatalf("192.0.2.9 not found with ResolveNames %v", resolve)
+		}
+		var want []string
+		if resolve {
+			want = []string{"printer.example"}
+		}
+		if !reflect.DeepEqual(host.Names, want) {
+			t.Errorf("names with ResolveNames %v = %v, want %v", resolve, host.Names, want)
+		}
+	}
+}
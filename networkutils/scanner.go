@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Scanner ties interface discovery and host probing to a Transport.
+*/
+
+package networkutils
+
+// Scanner discovers interfaces and probes hosts through its Transport.
+type Scanner struct {
+	Transport Transport
+	// Namespace labels results when the transport runs inside a network
+	// namespace. It is empty for the host namespace.
+	Namespace string
+	// Progress, if set, receives the progress of every interface probed.
+	Progress ProgressFunc
+}
+
+// NewScanner returns a Scanner using the given transport.
+func NewScanner(transport Transport) *Scanner {
+	return &Scanner{Transport: transport}
+}
+
+// DefaultScanner is used by the package-level functions. It scans the real
+// network; replace it to run the package against another Transport.
+var DefaultScanner = NewScanner(SystemTransport{})
+
+func DiscoverInterfaces() ([]InterfaceDetails, error) {
+	return DefaultScanner.DiscoverInterfaces()
+}
+
+func GetInterfaceByName(name string) (*InterfaceDetails, error) {
+	return DefaultScanner.GetInterfaceByName(name)
+}
+
+// qualifiedName prefixes an interface name with the scanner's namespace, as
+// used for result keys and per-interface settings.
+func (s *Scanner) qualifiedName(ifaceName string) string {
+	if s.Namespace == "" {
+		return ifaceName
+	}
+	return s.Namespace + "/" + ifaceName
+}
@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   In-memory simulated network implementing networkutils.Transport.
+
+   Hosts are configured with the probes they answer, a latency and a loss
+   rate. Loss is drawn from a seeded source so that a given seed always
+   produces the same outcome, which makes scans reproducible in tests.
+*/
+
+package simnet
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"goscan/networkutils"
+)
+
+// Host is a simulated machine on the network.
+type Host struct {
+	IP        net.IP
+	MAC       net.HardwareAddr
+	ARP       bool          // answers ARP requests
+	ICMP      bool          // answers ICMP echo requests
+	TTL       int           // TTL reported in ICMP replies, 64 if unset
+	OpenPorts []int         // TCP ports accepting connections
+	Names     []string      // names returned by reverse lookups
+	Latency   time.Duration // round-trip time of every reply
+	Loss      float64       // probability in [0, 1] that a probe is dropped
+	Err       error         // if set, returned by every probe of this host
+}
+
+// Network is a simulated network. The zero value is not usable; use New.
+type Network struct {
+	mu    sync.Mutex
+	links []networkutils.Link
+	hosts map[string]*Host
+	rng   *rand.Rand
+}
+
+var _ networkutils.Transport = (*Network)(nil)
+
+// New returns an empty network whose packet loss is drawn from seed.
+func New(seed int64) *Network {
+	return &Network{
+		hosts: make(map[string]*Host),
+		rng:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// AddInterface adds a local physical interface that is up with a carrier,
+// with the given MAC and CIDR addresses (e.g. "192.168.1.10/24").
+func (n *Network) AddInterface(name, mac string, cidrs ...string) error {
+	return n.AddTypedInterface(name, networkutils.TypePhysical, mac, cidrs...)
+}
+
+// AddTypedInterface is AddInterface for an interface of the given type
+// (bridge, veth, ...).
+func (n *Network) AddTypedInterface(name, ifaceType, mac string, cidrs ...string) error {
+	link := networkutils.Link{
+		Name:  name,
+		Type:  ifaceType,
+		Flags: net.FlagUp | net.FlagRunning | net.FlagBroadcast | net.FlagMulticast,
+	}
+
+	if mac != "" {
+		hw, err := net.ParseMAC(mac)
+		if err != nil {
+			return err
+		}
+		link.HardwareAddr = hw
+	}
+
+	for _, cidr := range cidrs {
+		ip, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		ipnet.IP = ip
+		link.Addrs = append(link.Addrs, ipnet)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	link.Index = len(n.links) + 1
+	n.links = append(n.links, link)
+	return nil
+}
+
+// SetLinkUp brings a simulated interface up or down.
+func (n *Network) SetLinkUp(name string, up bool) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i := range n.links {
+		if n.links[i].Name != name {
+			continue
+		}
+		// As with a real link, the carrier follows the administrative state
+		if up {
+			n.links[i].Flags |= net.FlagUp | net.FlagRunning
+		} else {
+			n.links[i].Flags &^= net.FlagUp | net.FlagRunning
+		}
+		return nil
+	}
+	return fmt.Errorf("interface %s not found", name)
+}
+
+// AddHost adds or replaces a simulated host.
+func (n *Network) AddHost(host Host) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	h := host
+	n.hosts[host.IP.String()] = &h
+}
+
+// RemoveHost takes a host off the network.
+func (n *Network) RemoveHost(ip net.IP) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.hosts, ip.String())
+}
+
+// lookup returns a copy of the host at ip and whether this probe is lost.
+func (n *Network) lookup(ip net.IP) (Host, bool, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	h, ok := n.hosts[ip.String()]
+	if !ok {
+		return Host{}, false, false
+	}
+	lost := h.Loss > 0 && n.rng.Float64() < h.Loss
+	return *h, true, lost
+}
+
+// onLink reports whether ip belongs to the subnet of an interface that is up.
+func (n *Network) onLink(ip net.IP) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, link := range n.links {
+		if link.Flags&net.FlagUp == 0 {
+			continue
+		}
+		for _, addr := range link.Addrs {
+			if addr.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wait sleeps for the reply latency and reports whether it fit in timeout.
+func wait(latency, timeout time.Duration) bool {
+	if latency > timeout {
+		time.Sleep(timeout)
+		return false
+	}
+	time.Sleep(latency)
+	return true
+}
+
+func (n *Network) Interfaces() ([]networkutils.Link, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	links := make([]networkutils.Link, len(n.links))
+	copy(links, n.links)
+	return links, nil
+}
+
+func (n *Network) ARP(ip net.IP, timeout time.Duration) (net.HardwareAddr, time.Duration, error) {
+	if !n.onLink(ip) {
+		return nil, 0, &os.SyscallError{Syscall: "sendto", Err: syscall.ENETDOWN}
+	}
+
+	host, ok, lost := n.lookup(ip)
+	if ok && host.Err != nil {
+		return nil, 0, host.Err
+	}
+	if !ok || !host.ARP || lost || !wait(host.Latency, timeout) {
+		return nil, 0, networkutils.ErrNoReply
+	}
+	return host.MAC, host.Latency, nil
+}
+
+func (n *Network) Ping(ip net.IP, count int, interval, timeout time.Duration) (*networkutils.PingStats, error) {
+	host, ok, _ := n.lookup(ip)
+	if ok && host.Err != nil {
+		return nil, host.Err
+	}
+	if !ok || !host.ICMP || !wait(host.Latency, timeout) {
+		return &networkutils.PingStats{Sent: count}, nil
+	}
+
+	stats := &networkutils.PingStats{}
+	for i := 0; i < count; i++ {
+		stats.Sent++
+		if _, _, lost := n.lookup(ip); lost {
+			continue
+		}
+		ttl := host.TTL
+		if ttl == 0 {
+			ttl = 64
+		}
+		stats.Received++
+		stats.RTTs = append(stats.RTTs, host.Latency)
+		stats.TTLs = append(stats.TTLs, ttl)
+	}
+	return stats, nil
+}
+
+func (n *Network) DialTCP(address string, timeout time.Duration) (net.Conn, error) {
+	hostPart, portPart, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portPart)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(hostPart)
+
+	host, ok, lost := n.lookup(ip)
+	if ok && host.Err != nil {
+		return nil, host.Err
+	}
+	if !ok || lost || !wait(host.Latency, timeout) {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	}
+	for _, open := range host.OpenPorts {
+		if open == port {
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+	}
+	return nil, &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}
+}
+
+func (n *Network) LookupAddr(ip net.IP, timeout time.Duration) ([]string, error) {
+	host, ok, _ := n.lookup(ip)
+	if !ok || len(host.Names) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: ip.String(), IsNotFound: true}
+	}
+	return append([]string(nil), host.Names...), nil
+}
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Transport abstraction over the raw network access used for scanning.
+
+   SystemTransport talks to the real network through arping, go-ping and the
+   net package. Alternative transports (see the simnet package) let the
+   scanner run against an in-memory network without root or a live LAN.
+*/
+
+package networkutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-ping/ping"
+	"github.com/j-keck/arping"
+)
+
+// ErrNoReply is returned by Transport.ARP when the target did not answer in
+// time. It is a negative result rather than a scan error.
+var ErrNoReply = errors.New("no reply")
+
+// Link describes a network interface as seen by a Transport.
+type Link struct {
+	Name         string
+	Type         string // one of the Type* constants
+	Index        int
+	Flags        net.Flags
+	HardwareAddr net.HardwareAddr
+	Addrs        []*net.IPNet
+	Gateways     []net.IP // next hops of the default routes through the link
+}
+
+// PingStats holds the outcome of a series of ICMP echo requests.
+type PingStats struct {
+	Sent     int
+	Received int
+	RTTs     []time.Duration
+	TTLs     []int
+}
+
+// Transport provides the network primitives the scanner is built on.
+type Transport interface {
+	// Interfaces lists the network interfaces and their addresses.
+	Interfaces() ([]Link, error)
+	// ARP resolves ip on the local segment, returning ErrNoReply if nobody answers.
+	ARP(ip net.IP, timeout time.Duration) (net.HardwareAddr, time.Duration, error)
+	// Ping sends count ICMP echo requests spaced by interval.
+	Ping(ip net.IP, count int, interval, timeout time.Duration) (*PingStats, error)
+	// DialTCP opens a TCP connection to address.
+	DialTCP(address string, timeout time.Duration) (net.Conn, error)
+	// LookupAddr returns the names that reverse-resolve to ip.
+	LookupAddr(ip net.IP, timeout time.Duration) ([]string, error)
+}
+
+// SystemTransport is the Transport backed by the host's network stack.
+type SystemTransport struct{}
+
+func (SystemTransport) Interfaces() ([]Link, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	gateways := defaultGateways()
+	links := make([]Link, 0, len(interfaces))
+	for _, iface := range interfaces {
+		link := Link{
+			Name:         iface.Name,
+			Type:         detectInterfaceType(iface.Name),
+			Index:        iface.Index,
+			Flags:        iface.Flags,
+			HardwareAddr: iface.HardwareAddr,
+			Gateways:     gateways[iface.Name],
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			fmt.Printf("Skipping interface %s due to error: %v\n", iface.Name, err)
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				link.Addrs = append(link.Addrs, ipnet)
+			}
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+func (SystemTransport) ARP(ip net.IP, timeout time.Duration) (net.HardwareAddr, time.Duration, error) {
+	arping.SetTimeout(timeout)
+
+	mac, rtt, err := arping.Ping(ip)
+	if errors.Is(err, arping.ErrTimeout) || errors.Is(err, syscall.EAGAIN) {
+		return nil, 0, ErrNoReply
+	}
+	return mac, rtt, err
+}
+
+func (SystemTransport) Ping(ip net.IP, count int, interval, timeout time.Duration) (*PingStats, error) {
+	pinger, err := ping.NewPinger(ip.String())
+	if err != nil {
+		return nil, err
+	}
+
+	pinger.SetPrivileged(true)
+	pinger.Count = count
+	pinger.Interval = interval
+	pinger.Timeout = timeout
+	pinger.Size = 56
+
+	var ttls []int
+	pinger.OnRecv = func(pkt *ping.Packet) {
+		ttls = append(ttls, pkt.Ttl)
+	}
+
+	if err := pinger.Run(); err != nil {
+		return nil, err
+	}
+
+	stats := pinger.Statistics()
+	return &PingStats{
+		Sent:     stats.PacketsSent,
+		Received: stats.PacketsRecv,
+		RTTs:     stats.Rtts,
+		TTLs:     ttls,
+	}, nil
+}
+
+func (SystemTransport) DialTCP(address string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("tcp", address, timeout)
+}
+
+func (SystemTransport) LookupAddr(ip net.IP, timeout time.Duration) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return net.DefaultResolver.LookupAddr(ctx, ip.String())
+}
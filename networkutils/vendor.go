@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   MAC address vendors. The IEEE OUI registry is read from the copies that
+   nmap, arp-scan or the ieee-data package install, when present; a few
+   prefixes common on virtual machines and boards are built in.
+*/
+
+package networkutils
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// OUIFiles are the OUI registries looked up, in order.
+var OUIFiles = []string{
+	"/usr/share/nmap/nmap-mac-prefixes",
+	"/usr/share/arp-scan/ieee-oui.txt",
+	"/usr/share/ieee-data/oui.txt",
+	"/usr/share/misc/oui.txt",
+}
+
+// builtinVendors maps OUIs, as six upper-case hex digits, to vendors.
+var builtinVendors = map[string]string{
+	"000569": "VMware",
+	"000C29": "VMware",
+	"001C14": "VMware",
+	"005056": "VMware",
+	"080027": "VirtualBox",
+	"00163E": "Xen",
+	"00155D": "Microsoft Hyper-V",
+	"525400": "QEMU/KVM",
+	"B827EB": "Raspberry Pi Foundation",
+	"DCA632": "Raspberry Pi Trading",
+	"E45F01": "Raspberry Pi Trading",
+	"28CDC1": "Raspberry Pi Trading",
+	"D83ADD": "Raspberry Pi Trading",
+}
+
+var (
+	vendorsOnce sync.Once
+	vendors     map[string]string
+)
+
+// LookupVendor returns the vendor of a MAC address, "locally administered"
+// for addresses not assigned by a vendor (randomized or virtual), or "" if
+// unknown.
+func LookupVendor(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) < 3 {
+		return ""
+	}
+	vendorsOnce.Do(loadVendors)
+
+	oui := strings.ToUpper(strings.ReplaceAll(hw[:3].String(), ":", ""))
+	if vendor, ok := vendors[oui]; ok {
+		return vendor
+	}
+	if hw[0] == 0x02 && hw[1] == 0x42 {
+		return "Docker"
+	}
+	if hw[0]&0x02 != 0 {
+		return "locally administered"
+	}
+	return ""
+}
+
+// loadVendors reads the first OUI registry found over the built-in table.
+func loadVendors() {
+	vendors = make(map[string]string, len(builtinVendors))
+	for oui, vendor := range builtinVendors {
+		vendors[oui] = vendor
+	}
+	for _, path := range OUIFiles {
+		if err := readOUIFile(path, vendors); err == nil {
+			return
+		}
+	}
+}
+
+// readOUIFile reads a registry in any of the nmap ("001122 Vendor"),
+// arp-scan ("001122<tab>Vendor") or IEEE ("00-11-22   (hex)<tab>Vendor")
+// formats.
+func readOUIFile(path string, into map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		oui := strings.ToUpper(strings.ReplaceAll(fields[0], "-", ""))
+		if len(oui) != 6 || strings.Trim(oui, "0123456789ABCDEF") != "" {
+			continue
+		}
+		rest := fields[1:]
+		if rest[0] == "(hex)" {
+			rest = rest[1:]
+		} else if rest[0] == "(base" {
+			continue
+		}
+		if len(rest) > 0 {
+			into[oui] = strings.Join(rest, " ")
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Home Assistant MQTT discovery: retained configuration messages under
+   <discovery prefix>/<component>/<node>/<object>/config that create a
+   device tracker per host and sensors per interface, all tied to the
+   publisher's status topic for availability.
+*/
+
+package notify
+
+import (
+	"strings"
+)
+
+// discoveryDevice groups entities into a device in Home Assistant.
+type discoveryDevice struct {
+	Identifiers  []string    `json:"identifiers"`
+	Name         string      `json:"name"`
+	Connections  [][2]string `json:"connections,omitempty"`
+	Manufacturer string      `json:"manufacturer,omitempty"`
+	Model        string      `json:"model,omitempty"`
+	ViaDevice    string      `json:"via_device,omitempty"`
+}
+
+// discoveryConfig is the configuration of a device tracker or sensor.
+type discoveryConfig struct {
+	Name                string          `json:"name"`
+	UniqueID            string          `json:"unique_id"`
+	StateTopic          string          `json:"state_topic"`
+	AvailabilityTopic   string          `json:"availability_topic"`
+	JSONAttributesTopic string          `json:"json_attributes_topic,omitempty"`
+	ValueTemplate       string          `json:"value_template,omitempty"`
+	Unit                string          `json:"unit_of_measurement,omitempty"`
+	StateClass          string          `json:"state_class,omitempty"`
+	Icon                string          `json:"icon,omitempty"`
+	SourceType          string          `json:"source_type,omitempty"`
+	PayloadHome         string          `json:"payload_home,omitempty"`
+	PayloadNotHome      string          `json:"payload_not_home,omitempty"`
+	Device              discoveryDevice `json:"device"`
+}
+
+// nodeID identifies this goscan instance in discovery topics and unique
+// IDs, so that several instances can share a broker.
+func (m *MQTT) nodeID() string {
+	return objectID(m.prefix)
+}
+
+// hubDevice is the device standing for goscan itself.
+func (m *MQTT) hubDevice() discoveryDevice {
+	return discoveryDevice{Identifiers: []string{m.nodeID()}, Name: "goscan (" + m.prefix + ")", Manufacturer: "goscan", Model: "Network scanner"}
+}
+
+// announceHost publishes the device tracker of a host once per connection.
+func (m *MQTT) announceHost(h *hostPresence) error {
+	id := m.nodeID() + "_" + objectID(h.Interface+"_"+h.IP)
+	if !m.discovery || m.announced[id] {
+		return nil
+	}
+	name := h.IP
+	if len(h.Names) > 0 {
+		name = h.Names[0]
+	}
+	device := discoveryDevice{Identifiers: []string{id}, Name: name, ViaDevice: m.nodeID()}
+	if h.MAC != "" {
+		device.Connections = [][2]string{{"mac", strings.ToLower(h.MAC)}}
+	}
+	topic := m.hostTopic(h.Interface, h.IP)
+	cfg := discoveryConfig{
+		Name:                name,
+		UniqueID:            id,
+		StateTopic:          topic + "/state",
+		AvailabilityTopic:   m.statusTopic(),
+		JSONAttributesTopic: topic + "/attributes",
+		SourceType:          "router",
+		PayloadHome:         PresenceHome,
+		PayloadNotHome:      PresenceNotHome,
+		Device:              device,
+	}
+	if err := m.publishJSON(m.discoveryTopic("device_tracker", id), cfg); err != nil {
+		return err
+	}
+	m.announced[id] = true
+	return nil
+}
+
+// announceInterface publishes the host count and utilization sensors of an
+// interface once per connection.
+func (m *MQTT) announceInterface(iface string) error {
+	id := m.nodeID() + "_" + objectID(iface)
+	if !m.discovery || m.announced[id] {
+		return nil
+	}
+	sensors := []discoveryConfig{{
+		Name:          iface + " hosts up",
+		UniqueID:      id + "_hosts",
+		ValueTemplate: "{{ value_json.hosts }}",
+		Unit:          "hosts",
+		Icon:          "mdi:lan-connect",
+	}, {
+		Name:          iface + " utilization",
+		UniqueID:      id + "_utilization",
+		ValueTemplate: "{{ (value_json.utilization * 100) | round(1) }}",
+		Unit:          "%",
+		Icon:          "mdi:percent",
+	}}
+	for _, cfg := range sensors {
+		cfg.StateTopic = m.interfaceTopic(iface)
+		cfg.AvailabilityTopic = m.statusTopic()
+		cfg.StateClass = "measurement"
+		cfg.Device = m.hubDevice()
+		if err := m.publishJSON(m.discoveryTopic("sensor", cfg.UniqueID), cfg); err != nil {
+			return err
+		}
+	}
+	m.announced[id] = true
+	return nil
+}
+
+func (m *MQTT) discoveryTopic(component, id string) string {
+	return m.discoveryPrefix + "/" + component + "/" + id + "/config"
+}
+
+// objectID turns a name into the characters allowed in discovery object
+// IDs: "blue/eth0_192.168.1.20" becomes "blue_eth0_192_168_1_20".
+func objectID(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Email alert sink. Alerts raised close together are batched into one
+   email, and the number of emails per hour is capped: alerts over the cap
+   are held and sent together once the hour allows it, so an outage that
+   takes down a whole switch makes one email rather than fifty.
+*/
+
+package notify
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"goscan/config"
+	"goscan/events"
+)
+
+const (
+	defaultBatchWindow = 30 * time.Second
+	defaultMaxPerHour  = 10
+)
+
+// EmailAlerts mails events to the configured recipients.
+type EmailAlerts struct {
+	mailer     *Mailer
+	watch      []*net.IPNet
+	window     time.Duration
+	maxPerHour int
+
+	mu      sync.Mutex
+	pending []events.Event
+	timer   *time.Timer
+	// sent holds the times of the emails sent in the last hour.
+	sent []time.Time
+}
+
+// alertData is passed to the alert templates.
+type alertData struct {
+	Events []events.Event
+	Time   time.Time
+}
+
+// NewEmailAlerts returns the email alert sink described by cfg and the
+// events it wants.
+func NewEmailAlerts(mailer *Mailer, cfg config.EmailConfig) (*EmailAlerts, events.Filter, error) {
+	types, err := events.ValidateTypes(cfg.Events)
+	if err != nil {
+		return nil, events.Filter{}, fmt.Errorf("email: %w", err)
+	}
+	if len(types) == 0 {
+		types = []events.Type{events.HostDown}
+	}
+
+	a := &EmailAlerts{
+		mailer:     mailer,
+		window:     time.Duration(cfg.BatchWindow),
+		maxPerHour: cfg.MaxPerHour,
+	}
+	if a.window <= 0 {
+		a.window = defaultBatchWindow
+	}
+	if a.maxPerHour <= 0 {
+		a.maxPerHour = defaultMaxPerHour
+	}
+	for _, w := range cfg.Watch {
+		ipnet, err := parseWatch(w)
+		if err != nil {
+			return nil, events.Filter{}, err
+		}
+		a.watch = append(a.watch, ipnet)
+	}
+	return a, events.Filter{Types: types, Interfaces: cfg.Interfaces}, nil
+}
+
+// parseWatch reads an address or CIDR block.
+func parseWatch(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid watched address %q", s)
+		}
+		bits := 8 * len(ip.To16())
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid watched network %q", s)
+	}
+	return ipnet, nil
+}
+
+// Name identifies the sink in logs.
+func (a *EmailAlerts) Name() string {
+	return "email to " + strings.Join(a.mailer.to, ", ")
+}
+
+// watched reports whether an event concerns a watched host. Events about
+// no host in particular always are.
+func (a *EmailAlerts) watched(e events.Event) bool {
+	if len(a.watch) == 0 || e.IP == "" {
+		return true
+	}
+	ip := net.ParseIP(e.IP)
+	for _, ipnet := range a.watch {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Send queues an event for the next email.
+func (a *EmailAlerts) Send(e events.Event) error {
+	if !a.watched(e) {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(a.pending, e)
+	if a.timer == nil {
+		a.timer = time.AfterFunc(a.window, a.flush)
+	}
+	return nil
+}
+
+// flush mails the pending events, or waits for the hourly cap to allow it.
+func (a *EmailAlerts) flush() {
+	a.mu.Lock()
+	now := time.Now()
+	for len(a.sent) > 0 && now.Sub(a.sent[0]) >= time.Hour {
+		a.sent = a.sent[1:]
+	}
+	if len(a.sent) >= a.maxPerHour {
+		wait := a.sent[0].Add(time.Hour).Sub(now)
+		log.Printf("Email alert limit of %d per hour reached, holding %d alerts for %s",
+			a.maxPerHour, len(a.pending), wait.Round(time.Second))
+		a.timer = time.AfterFunc(wait, a.flush)
+		a.mu.Unlock()
+		return
+	}
+	batch := a.pending
+	a.pending = nil
+	a.timer = nil
+	a.sent = append(a.sent, now)
+	a.mu.Unlock()
+
+	if err := a.mailer.SendAlert(batch); err != nil {
+		log.Printf("Failed to send email alert: %v", err)
+	}
+}
+
+// SendAlert mails a batch of events at once.
+func (m *Mailer) SendAlert(batch []events.Event) error {
+	sort.SliceStable(batch, func(i, j int) bool { return batch[i].Time.Before(batch[j].Time) })
+	return m.send(alertSubject(batch), "alert", alertData{Events: batch, Time: time.Now()})
+}
+
+// alertSubject is the message of a single event, or counts by type:
+// "3 alerts: 2 host.down, 1 host.up".
+func alertSubject(batch []events.Event) string {
+	if len(batch) == 1 {
+		return batch[0].Message
+	}
+	counts := make(map[events.Type]int)
+	var order []events.Type
+	for _, e := range batch {
+		if counts[e.Type] == 0 {
+			order = append(order, e.Type)
+		}
+		counts[e.Type]++
+	}
+	parts := make([]string, len(order))
+	for i, t := range order {
+		parts[i] = fmt.Sprintf("%d %s", counts[t], t)
+	}
+	return fmt.Sprintf("%d alerts: %s", len(batch), strings.Join(parts, ", "))
+}
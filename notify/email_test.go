@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Email alerts: batching subjects, watched hosts and SMTP delivery.
+*/
+
+package notify
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"goscan/config"
+	"goscan/events"
+)
+
+// smtpSession is what a fake SMTP server received.
+type smtpSession struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP accepts one plain text SMTP session and reports what it received.
+func fakeSMTP(t *testing.T) (string, int, <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		var s smtpSession
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); verb {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL":
+				s.from = strings.Trim(strings.TrimPrefix(cmd, "MAIL FROM:"), "<>")
+				reply("250 OK")
+			case "RCPT":
+				s.to = append(s.to, strings.Trim(strings.TrimPrefix(cmd, "RCPT TO:"), "<>"))
+				reply("250 OK")
+			case "DATA":
+				reply("354 Go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				s.data = data.String()
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				sessions <- s
+				return
+			default:
+				reply("502 Not implemented")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, sessions
+}
+
+func TestNewMailer(t *testing.T) {
+	valid := config.EmailConfig{Host: "mail.example.com", From: "goscan@example.com", To: []string{"ops@example.com"}}
+
+	tests := []struct {
+		name     string
+		change   func(cfg *config.EmailConfig)
+		wantPort int
+		wantErr  bool
+	}{
+		{"defaults", func(cfg *config.EmailConfig) {}, 587, false},
+		{"implicit tls", func(cfg *config.EmailConfig) { cfg.TLS = TLSImplicit }, 465, false},
+		{"explicit port", func(cfg *config.EmailConfig) { cfg.TLS = TLSNone; cfg.Port = 25 }, 25, false},
+		{"invalid tls", func(cfg *config.EmailConfig) { cfg.TLS = "ssl" }, 0, true},
+		{"no recipient", func(cfg *config.EmailConfig) { cfg.To = nil }, 0, true},
+		{"invalid from", func(cfg *config.EmailConfig) { cfg.From = "goscan" }, 0, true},
+		{"missing template dir", func(cfg *config.EmailConfig) { cfg.TemplateDir = t.TempDir() }, 587, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.change(&cfg)
+			m, err := NewMailer(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMailer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && m.cfg.Port != tt.wantPort {
+				t.Errorf("port = %d, want %d", m.cfg.Port, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestAlertSubject(t *testing.T) {
+	down := events.Event{Type: events.HostDown, Message: "192.0.2.9 is down"}
+	up := events.Event{Type: events.HostUp, Message: "192.0.2.10 is up"}
+
+	tests := []struct {
+		name  string
+		batch []events.Event
+		want  string
+	}{
+		{"single", []events.Event{down}, "192.0.2.9 is down"},
+		{"counts in order", []events.Event{down, up, down}, "3 alerts: 2 host.down, 1 host.up"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := alertSubject(tt.batch); got != tt.want {
+				t.Errorf("alertSubject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailWatched(t *testing.T) {
+	a, _, err := NewEmailAlerts(&Mailer{}, config.EmailConfig{Watch: []string{"192.0.2.9", "198.51.100.0/24"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"192.0.2.9", true},
+		{"192.0.2.10", false},
+		{"198.51.100.77", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		if got := a.watched(events.Event{IP: tt.ip}); got != tt.want {
+			t.Errorf("watched(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	if _, _, err := NewEmailAlerts(&Mailer{}, config.EmailConfig{Watch: []string{"192.0.2.300"}}); err == nil {
+		t.Error("NewEmailAlerts() accepted an invalid watched address")
+	}
+}
+
+func TestSendAlert(t *testing.T) {
+	host, port, sessions := fakeSMTP(t)
+	m, err := NewMailer(config.EmailConfig{
+		Host: host,
+		Port: port,
+		TLS:  TLSNone,
+		From: "goscan <goscan@example.com>",
+		To:   []string{"ops@example.com", "Oncall <oncall@example.com>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	batch := []events.Event{
+		{Type: events.HostUp, Time: now, IP: "192.0.2.10", Message: "192.0.2.10 is up"},
+		{Type: events.HostDown, Time: now.Add(-time.Minute), IP: "192.0.2.9", MAC: "02:00:00:00:00:09", Message: "192.0.2.9 is down"},
+	}
+	if err := m.SendAlert(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	var s smtpSession
+	select {
+	case s = <-sessions:
+	case <-time.After(time.Second):
+		t.Fatal("no SMTP session")
+	}
+	if s.from != "goscan@example.com" || strings.Join(s.to, " ") != "ops@example.com oncall@example.com" {
+		t.Errorf("envelope from %s to %v", s.from, s.to)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(s.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msg.Header.Get("Subject"), "[goscan] 2 alerts: 1 host.down, 1 host.up"; got != want {
+		t.Errorf("Subject = %q, want %q", got, want)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q", msg.Header.Get("Content-Type"))
+	}
+
+	var types []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type"))
+		text := string(body)
+		// Events are listed oldest first
+		if down, up := strings.Index(text, "192.0.2.9 is down"), strings.Index(text, "192.0.2.10 is up"); down < 0 || up < down {
+			t.Errorf("%s part does not list the events in order:\n%s", part.Header.Get("Content-Type"), text)
+		}
+	}
+	if got := strings.Join(types, ", "); got != "text/plain; charset=utf-8, text/html; charset=utf-8" {
+		t.Errorf("parts = %s, want plain text and HTML", got)
+	}
+}
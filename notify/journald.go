@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   journald sink: events sent to the systemd journal over its native
+   protocol, with the event fields as journal fields (GOSCAN_EVENT,
+   GOSCAN_IP, ...) so that they can be matched with journalctl:
+
+     journalctl SYSLOG_IDENTIFIER=goscan GOSCAN_EVENT=host.down
+*/
+
+package notify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"goscan/config"
+	"goscan/events"
+)
+
+// DefaultJournalSocket is journald's native protocol socket.
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// Journald delivers events to the systemd journal.
+type Journald struct {
+	socket     string
+	identifier string
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+// NewJournald returns the journald sink described by cfg and the events it
+// wants.
+func NewJournald(cfg config.JournaldConfig) (*Journald, events.Filter, error) {
+	types, err := events.ValidateTypes(cfg.Events)
+	if err != nil {
+		return nil, events.Filter{}, fmt.Errorf("journald: %w", err)
+	}
+	socket := cfg.Socket
+	if socket == "" {
+		socket = DefaultJournalSocket
+	}
+	identifier := cfg.Identifier
+	if identifier == "" {
+		identifier = "goscan"
+	}
+	j := &Journald{socket: socket, identifier: identifier}
+	return j, events.Filter{Types: types, Interfaces: cfg.Interfaces}, nil
+}
+
+// Name identifies the sink in logs.
+func (j *Journald) Name() string {
+	return "journald " + j.socket
+}
+
+// Send writes an event to the journal.
+func (j *Journald) Send(e events.Event) error {
+	entry := journalEntry(j.identifier, e)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: j.socket, Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		j.conn = conn
+	}
+	if _, err := j.conn.Write(entry); err != nil {
+		j.conn.Close()
+		j.conn = nil
+		return err
+	}
+	return nil
+}
+
+// journalEntry serializes an event in the native protocol: one FIELD=value
+// line per field, or, for values spanning lines, the field name, a newline,
+// the value length as a little-endian 64-bit integer, and the value.
+func journalEntry(identifier string, e events.Event) []byte {
+	var b bytes.Buffer
+	write := func(name, value string) {
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&b, "%s=%s\n", name, value)
+			return
+		}
+		b.WriteString(name + "\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(value)))
+		b.WriteString(value + "\n")
+	}
+
+	write("MESSAGE", e.Message)
+	write("PRIORITY", strconv.Itoa(e.Severity()))
+	write("SYSLOG_IDENTIFIER", identifier)
+	for _, field := range eventFields(e) {
+		write("GOSCAN_"+journalFieldName(field.name), field.value)
+	}
+	return b.Bytes()
+}
+
+// journalFieldName converts a camelCase field name to a journal field name
+// ("oldMac" to "OLD_MAC"). The event type is sent as GOSCAN_EVENT.
+func journalFieldName(name string) string {
+	if name == "type" {
+		return "EVENT"
+	}
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToUpper(b.String())
+}
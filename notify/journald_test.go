@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   journald native protocol encoding.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"goscan/events"
+)
+
+func TestJournalEntry(t *testing.T) {
+	e := testEvent()
+	e.OldMAC = "02:00:00:00:00:09"
+	e.Message = "192.0.2.20 is down\nsince 10:00"
+
+	got := journalEntry("goscan", e)
+
+	var want bytes.Buffer
+	want.WriteString("MESSAGE\n")
+	binary.Write(&want, binary.LittleEndian, uint64(len(e.Message)))
+	want.WriteString(e.Message + "\n")
+	want.WriteString("PRIORITY=4\n" +
+		"SYSLOG_IDENTIFIER=goscan\n" +
+		"GOSCAN_ID=0123\n" +
+		"GOSCAN_EVENT=host.down\n" +
+		"GOSCAN_INTERFACE=eth0\n" +
+		"GOSCAN_IP=192.0.2.20\n" +
+		"GOSCAN_OLD_MAC=02:00:00:00:00:09\n" +
+		"GOSCAN_NAMES=nas,a\"b]\n")
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("journalEntry() =\n%q\nwant\n%q", got, want.Bytes())
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"type", "EVENT"},
+		{"ip", "IP"},
+		{"oldMac", "OLD_MAC"},
+		{"threshold", "THRESHOLD"},
+	}
+
+	for _, tt := range tests {
+		if got := journalFieldName(tt.name); got != tt.want {
+			t.Errorf("journalFieldName(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJournaldScanFinished(t *testing.T) {
+	e := events.Event{ID: "1", Type: events.ScanFinished, Interface: "eth0", Hosts: 3, Scanned: 254, Message: "scan finished"}
+
+	got := journalEntry("goscan", e)
+
+	for _, want := range []string{"GOSCAN_HOSTS=3\n", "GOSCAN_SCANNED=254\n", "PRIORITY=6\n"} {
+		if !bytes.Contains(got, []byte(want)) {
+			t.Errorf("entry lacks %q:\n%s", want, got)
+		}
+	}
+}
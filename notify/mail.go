@@ -0,0 +1,285 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   SMTP delivery of multipart (plain text and HTML) emails rendered from
+   templates. The built-in templates can be overridden from a directory.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"goscan/config"
+)
+
+// SMTP TLS modes.
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+)
+
+const smtpTimeout = 30 * time.Second
+
+//go:embed templates
+var builtinTemplates embed.FS
+
+// templateFuncs are available to every email template.
+var templateFuncs = map[string]any{
+	"join":    strings.Join,
+	"percent": func(ratio float64) string { return strconv.FormatFloat(ratio*100, 'f', 1, 64) + "%" },
+	"time":    func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05") },
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Second).String()
+	},
+}
+
+// Mailer sends emails through an SMTP server.
+type Mailer struct {
+	cfg  config.EmailConfig
+	text *texttemplate.Template
+	html *htmltemplate.Template
+	// from and to are the envelope addresses
+	from string
+	to   []string
+}
+
+// NewMailer checks the SMTP settings and loads the templates.
+func NewMailer(cfg config.EmailConfig) (*Mailer, error) {
+	if cfg.TLS == "" {
+		cfg.TLS = TLSStartTLS
+	}
+	switch cfg.TLS {
+	case TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, fmt.Errorf("invalid email TLS mode %q (expected starttls, tls or none)", cfg.TLS)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.TLS == TLSImplicit {
+			cfg.Port = 465
+		}
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("email needs a from address and at least one recipient")
+	}
+	if cfg.SubjectPrefix == "" {
+		cfg.SubjectPrefix = "[goscan]"
+	}
+
+	m := &Mailer{cfg: cfg}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email from address %q: %w", cfg.From, err)
+	}
+	m.from = from.Address
+	for _, to := range cfg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email recipient %q: %w", to, err)
+		}
+		m.to = append(m.to, addr.Address)
+	}
+
+	if m.text, err = loadTextTemplates(cfg.TemplateDir); err != nil {
+		return nil, err
+	}
+	if m.html, err = loadHTMLTemplates(cfg.TemplateDir); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func loadTextTemplates(dir string) (*texttemplate.Template, error) {
+	t := texttemplate.New("").Funcs(templateFuncs)
+	for _, name := range []string{"alert.txt", "summary.txt"} {
+		src, err := templateSource(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.New(name).Parse(src); err != nil {
+			return nil, fmt.Errorf("invalid email template %s: %w", name, err)
+		}
+	}
+	return t, nil
+}
+
+func loadHTMLTemplates(dir string) (*htmltemplate.Template, error) {
+	t := htmltemplate.New("").Funcs(templateFuncs)
+	for _, name := range []string{"alert.html", "summary.html"} {
+		src, err := templateSource(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.New(name).Parse(src); err != nil {
+			return nil, fmt.Errorf("invalid email template %s: %w", name, err)
+		}
+	}
+	return t, nil
+}
+
+// templateSource reads a template from dir if it is there, or the built-in
+// one.
+func templateSource(dir, name string) (string, error) {
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read email template: %w", err)
+		}
+	}
+	data, err := builtinTemplates.ReadFile("templates/" + name)
+	return string(data), err
+}
+
+// render executes the text and HTML templates of an email.
+func (m *Mailer) render(name string, data any) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := m.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return "", "", err
+	}
+	if err := m.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// send renders the named templates and mails the result.
+func (m *Mailer) send(subject, name string, data any) error {
+	text, html, err := m.render(name, data)
+	if err != nil {
+		return err
+	}
+	msg, err := m.compose(m.cfg.SubjectPrefix+" "+subject, text, html)
+	if err != nil {
+		return err
+	}
+	return m.deliver(msg)
+}
+
+// compose builds a multipart/alternative message.
+func (m *Mailer) compose(subject, text, html string) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 12)
+	rand.Read(id)
+	_, domain, _ := strings.Cut(m.from, "@")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(m.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// deliver hands a message to the SMTP server.
+func (m *Mailer) deliver(msg []byte) error {
+	address := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host, InsecureSkipVerify: m.cfg.InsecureSkipVerify}
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if m.cfg.TLS == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if m.cfg.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", address)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if m.cfg.Username != "" {
+		// PlainAuth refuses to send credentials unencrypted, except to
+		// localhost
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	for _, to := range m.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,397 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   MQTT publisher for presence automation. After every scan it publishes,
+   retained so that new subscribers get the current state at once:
+
+     goscan/status                           online, or offline (last will)
+     goscan/host/<interface>/<ip>/state      home or not_home
+     goscan/host/<interface>/<ip>/attributes {"ip":..., "mac":..., "names":[...], ...}
+     goscan/interface/<interface>            {"hosts":..., "scanned":..., "utilization":...}
+     goscan/scan                             {"hosts":..., "scanned":..., "elapsedSeconds":...}
+
+   With discovery enabled, Home Assistant discovery messages make every
+   host a device tracker and every interface a pair of sensors.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"goscan/config"
+	"goscan/networkutils"
+)
+
+// Presence payloads, as Home Assistant device trackers expect them.
+const (
+	PresenceHome    = "home"
+	PresenceNotHome = "not_home"
+)
+
+const mqttKeepAlive = 60 * time.Second
+
+// MQTT publishes presence and scan summaries to a broker.
+type MQTT struct {
+	broker          string
+	address         string
+	tlsConfig       *tls.Config
+	opts            mqttOptions
+	prefix          string
+	qos             int
+	interfaces      []string
+	discovery       bool
+	discoveryPrefix string
+	downAfter       int
+
+	reports chan *networkutils.ScanReport
+
+	// The state below belongs to the publishing goroutine.
+	conn      *mqttConn
+	lastSent  time.Time
+	hosts     map[string]map[string]*hostPresence
+	announced map[string]bool
+}
+
+// hostPresence is what the publisher knows of a host.
+type hostPresence struct {
+	IP        string    `json:"ip"`
+	MAC       string    `json:"mac,omitempty"`
+	Interface string    `json:"interface"`
+	Names     []string  `json:"names,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	RTTMs     float64   `json:"rttMs,omitempty"`
+	LastSeen  time.Time `json:"lastSeen"`
+
+	up     bool
+	missed int
+}
+
+// interfaceSummary is published for every scanned interface.
+type interfaceSummary struct {
+	Interface      string    `json:"interface"`
+	Hosts          int       `json:"hosts"`
+	Scanned        int       `json:"scanned"`
+	Utilization    float64   `json:"utilization"`
+	ElapsedSeconds float64   `json:"elapsedSeconds"`
+	Time           time.Time `json:"time"`
+}
+
+// scanSummary is published after every scan.
+type scanSummary struct {
+	Hosts          int       `json:"hosts"`
+	Scanned        int       `json:"scanned"`
+	Interfaces     []string  `json:"interfaces"`
+	ElapsedSeconds float64   `json:"elapsedSeconds"`
+	Time           time.Time `json:"time"`
+}
+
+// NewMQTT returns the publisher described by cfg. Hosts are reported away
+// after missing downAfter consecutive scans, as for host.down events.
+func NewMQTT(cfg config.MQTTConfig, downAfter int) (*MQTT, error) {
+	address, useTLS, err := parseBroker(cfg.Broker)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.QoS != 0 && cfg.QoS != 1 {
+		return nil, fmt.Errorf("invalid MQTT QoS %d (expected 0 or 1)", cfg.QoS)
+	}
+	prefix := strings.Trim(cfg.TopicPrefix, "/")
+	if prefix == "" {
+		prefix = "goscan"
+	}
+	if strings.ContainsAny(prefix, "+#") {
+		return nil, fmt.Errorf("invalid MQTT topic prefix %q", cfg.TopicPrefix)
+	}
+	clientID := cfg.ClientID
+	if clientID == "" {
+		hostname, _ := os.Hostname()
+		clientID = "goscan-" + hostname
+	}
+	if downAfter <= 0 {
+		downAfter = 1
+	}
+
+	m := &MQTT{
+		broker:          cfg.Broker,
+		address:         address,
+		prefix:          prefix,
+		qos:             cfg.QoS,
+		interfaces:      cfg.Interfaces,
+		discovery:       cfg.Discovery,
+		discoveryPrefix: strings.Trim(cfg.DiscoveryPrefix, "/"),
+		downAfter:       downAfter,
+		reports:         make(chan *networkutils.ScanReport, 16),
+		hosts:           make(map[string]map[string]*hostPresence),
+		announced:       make(map[string]bool),
+	}
+	if m.discoveryPrefix == "" {
+		m.discoveryPrefix = "homeassistant"
+	}
+	if useTLS {
+		host := address[:strings.LastIndex(address, ":")]
+		m.tlsConfig = &tls.Config{ServerName: strings.Trim(host, "[]"), InsecureSkipVerify: cfg.InsecureSkipVerify}
+	}
+	m.opts = mqttOptions{
+		clientID:  clientID,
+		username:  cfg.Username,
+		password:  cfg.Password,
+		keepAlive: mqttKeepAlive,
+		will:      &mqttMessage{topic: m.statusTopic(), payload: []byte("offline"), qos: cfg.QoS, retain: true},
+	}
+	return m, nil
+}
+
+// Name identifies the publisher in logs.
+func (m *MQTT) Name() string {
+	return "MQTT broker " + m.broker
+}
+
+// Start publishes the reports given to Observe in the background.
+func (m *MQTT) Start() {
+	go m.run()
+}
+
+// Observe queues a scan report for publishing without waiting.
+func (m *MQTT) Observe(report *networkutils.ScanReport) {
+	select {
+	case m.reports <- report:
+	default:
+		log.Printf("Dropped scan report for %s: queue full", m.Name())
+	}
+}
+
+func (m *MQTT) run() {
+	ticker := time.NewTicker(mqttKeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case report := <-m.reports:
+			if err := m.publishReport(report); err != nil {
+				log.Printf("Failed to publish to %s: %v", m.Name(), err)
+				m.disconnect()
+			}
+		case <-ticker.C:
+			if m.conn == nil || time.Since(m.lastSent) < mqttKeepAlive/2 {
+				continue
+			}
+			if err := m.conn.ping(); err != nil {
+				log.Printf("Lost connection to %s: %v", m.Name(), err)
+				m.disconnect()
+				continue
+			}
+			m.lastSent = time.Now()
+		}
+	}
+}
+
+// connect logs in, announces the publisher online and forgets which
+// discovery messages were sent, as the broker may have lost them.
+func (m *MQTT) connect() error {
+	if m.conn != nil {
+		return nil
+	}
+	conn, err := dialMQTT(m.address, m.tlsConfig, m.opts)
+	if err != nil {
+		return err
+	}
+	m.conn = conn
+	m.announced = make(map[string]bool)
+	return m.publish(m.statusTopic(), []byte("online"))
+}
+
+func (m *MQTT) disconnect() {
+	if m.conn != nil {
+		m.conn.conn.Close()
+		m.conn = nil
+	}
+}
+
+// publish sends a retained message.
+func (m *MQTT) publish(topic string, payload []byte) error {
+	if err := m.conn.publish(mqttMessage{topic: topic, payload: payload, qos: m.qos, retain: true}); err != nil {
+		return err
+	}
+	m.lastSent = time.Now()
+	return nil
+}
+
+func (m *MQTT) publishJSON(topic string, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return m.publish(topic, payload)
+}
+
+// Test connects and publishes a message to <prefix>/test, disconnecting
+// cleanly so that the status topic is left alone.
+func (m *MQTT) Test() error {
+	opts := m.opts
+	opts.will = nil
+	conn, err := dialMQTT(m.address, m.tlsConfig, opts)
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+	payload := fmt.Sprintf(`{"message":"Test message from goscan","time":%q}`, time.Now().UTC().Format(time.RFC3339))
+	return conn.publish(mqttMessage{topic: m.prefix + "/test", payload: []byte(payload), qos: m.qos})
+}
+
+// publishReport updates the presence of the hosts of the scanned
+// interfaces and publishes it with the scan summaries. Every known host is
+// republished, so a broker that lost its retained messages catches up at
+// the next scan.
+func (m *MQTT) publishReport(report *networkutils.ScanReport) error {
+	if err := m.connect(); err != nil {
+		return err
+	}
+
+	summary := scanSummary{ElapsedSeconds: report.Elapsed.Seconds(), Time: report.StartedAt}
+	for i := range report.Interfaces {
+		iface := &report.Interfaces[i]
+		name := iface.QualifiedName()
+		if iface.TotalIPsScanned == 0 || !m.wants(name) {
+			continue
+		}
+		hosts := m.observe(iface, report.StartedAt)
+
+		for _, h := range hosts {
+			if err := m.publishHost(h); err != nil {
+				return err
+			}
+		}
+
+		usage := interfaceSummary{
+			Interface:      name,
+			Hosts:          len(iface.ActiveHosts),
+			Scanned:        iface.TotalIPsScanned,
+			Utilization:    float64(len(iface.ActiveHosts)) / float64(iface.TotalIPsScanned),
+			ElapsedSeconds: iface.Elapsed.Seconds(),
+			Time:           report.StartedAt,
+		}
+		if err := m.announceInterface(name); err != nil {
+			return err
+		}
+		if err := m.publishJSON(m.interfaceTopic(name), usage); err != nil {
+			return err
+		}
+		summary.Hosts += usage.Hosts
+		summary.Scanned += usage.Scanned
+		summary.Interfaces = append(summary.Interfaces, name)
+	}
+	if len(summary.Interfaces) == 0 {
+		return nil
+	}
+	return m.publishJSON(m.prefix+"/scan", summary)
+}
+
+// wants reports whether an interface is published.
+func (m *MQTT) wants(name string) bool {
+	if len(m.interfaces) == 0 {
+		return true
+	}
+	for _, pattern := range m.interfaces {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// observe updates the presence of an interface's hosts from a scan and
+// returns them sorted by address.
+func (m *MQTT) observe(iface *networkutils.InterfaceReport, at time.Time) []*hostPresence {
+	name := iface.QualifiedName()
+	hosts, ok := m.hosts[name]
+	if !ok {
+		hosts = make(map[string]*hostPresence)
+		m.hosts[name] = hosts
+	}
+
+	active := make(map[string]bool, len(iface.ActiveHosts))
+	for _, host := range iface.ActiveHosts {
+		ip := host.IP.String()
+		active[ip] = true
+		h, ok := hosts[ip]
+		if !ok {
+			h = &hostPresence{IP: ip, Interface: name}
+			hosts[ip] = h
+		}
+		h.up, h.missed = true, 0
+		h.Method, h.LastSeen = host.Method, at
+		h.RTTMs = float64(host.RTT) / float64(time.Millisecond)
+		if host.MAC != "" {
+			h.MAC = host.MAC
+		}
+		if len(host.Names) > 0 {
+			h.Names = host.Names
+		}
+	}
+	for ip, h := range hosts {
+		// A host whose probes failed keeps its presence
+		if active[ip] || !h.up || iface.ProbeFailed(net.ParseIP(ip)) {
+			continue
+		}
+		h.missed++
+		if h.missed >= m.downAfter {
+			h.up = false
+		}
+	}
+
+	result := make([]*hostPresence, 0, len(hosts))
+	for _, h := range hosts {
+		result = append(result, h)
+	}
+	sort.Slice(result, func(i, j int) bool { return compareIPs(result[i].IP, result[j].IP) < 0 })
+	return result
+}
+
+func (m *MQTT) publishHost(h *hostPresence) error {
+	if err := m.announceHost(h); err != nil {
+		return err
+	}
+	topic := m.hostTopic(h.Interface, h.IP)
+	if err := m.publishJSON(topic+"/attributes", h); err != nil {
+		return err
+	}
+	state := PresenceNotHome
+	if h.up {
+		state = PresenceHome
+	}
+	return m.publish(topic+"/state", []byte(state))
+}
+
+func (m *MQTT) statusTopic() string {
+	return m.prefix + "/status"
+}
+
+func (m *MQTT) hostTopic(iface, ip string) string {
+	return m.prefix + "/host/" + topicLevel(iface) + "/" + ip
+}
+
+func (m *MQTT) interfaceTopic(iface string) string {
+	return m.prefix + "/interface/" + topicLevel(iface)
+}
+
+// topicLevel strips the wildcards, which are not allowed in topic names,
+// from an interface name. Namespaced names ("blue/eth0") keep their slash
+// and span two levels.
+func topicLevel(s string) string {
+	return strings.NewReplacer("+", "_", "#", "_").Replace(s)
+}
+
+// compareIPs orders addresses numerically.
+func compareIPs(a, b string) int {
+	return bytes.Compare(net.ParseIP(a).To16(), net.ParseIP(b).To16())
+}
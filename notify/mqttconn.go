@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Minimal MQTT 3.1.1 client: enough to connect with a last will, publish
+   at QoS 0 or 1 and keep the connection alive. goscan only publishes, so
+   the broker never sends anything unasked and packets are read in line.
+*/
+
+package notify
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// MQTT control packet types, in the high nibble of the first byte.
+const (
+	mqttConnect    = 1
+	mqttConnAck    = 2
+	mqttPublish    = 3
+	mqttPubAck     = 4
+	mqttPingReq    = 12
+	mqttPingResp   = 13
+	mqttDisconnect = 14
+)
+
+const mqttTimeout = 10 * time.Second
+
+// mqttConnAckErrors explains the CONNACK return codes.
+var mqttConnAckErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// mqttMessage is an application message.
+type mqttMessage struct {
+	topic   string
+	payload []byte
+	qos     int
+	retain  bool
+}
+
+// mqttConn is a connection to a broker.
+type mqttConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	nextID uint16
+}
+
+// mqttOptions are the CONNECT settings.
+type mqttOptions struct {
+	clientID  string
+	username  string
+	password  string
+	keepAlive time.Duration
+	will      *mqttMessage
+}
+
+// parseBroker returns the network address of a broker URL and whether it
+// uses TLS.
+func parseBroker(broker string) (string, bool, error) {
+	u, err := url.Parse(broker)
+	if err != nil || u.Host == "" {
+		return "", false, fmt.Errorf("invalid MQTT broker %q (expected tcp://host:port or tls://host:port)", broker)
+	}
+	var useTLS bool
+	port := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "tls", "ssl", "mqtts":
+		useTLS, port = true, "8883"
+	default:
+		return "", false, fmt.Errorf("invalid MQTT broker scheme %q (expected tcp or tls)", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// dialMQTT connects and logs in to a broker.
+func dialMQTT(address string, tlsConfig *tls.Config, opts mqttOptions) (*mqttConn, error) {
+	dialer := &net.Dialer{Timeout: mqttTimeout}
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &mqttConn{conn: conn, r: bufio.NewReader(conn)}
+	if err := c.connect(opts); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *mqttConn) connect(opts mqttOptions) error {
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+
+	flags := byte(0x02) // clean session
+	if opts.will != nil {
+		flags |= 0x04 | byte(opts.will.qos)<<3
+		if opts.will.retain {
+			flags |= 0x20
+		}
+	}
+	if opts.username != "" {
+		flags |= 0x80
+		if opts.password != "" {
+			flags |= 0x40
+		}
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.keepAlive/time.Second))
+
+	body = appendString(body, opts.clientID)
+	if opts.will != nil {
+		body = appendString(body, opts.will.topic)
+		body = appendString(body, string(opts.will.payload))
+	}
+	if opts.username != "" {
+		body = appendString(body, opts.username)
+		if opts.password != "" {
+			body = appendString(body, opts.password)
+		}
+	}
+	if err := c.write(mqttConnect<<4, body); err != nil {
+		return err
+	}
+
+	kind, reply, err := c.read()
+	if err != nil {
+		return err
+	}
+	if kind != mqttConnAck || len(reply) != 2 {
+		return fmt.Errorf("unexpected MQTT packet type %d in answer to CONNECT", kind)
+	}
+	if code := reply[1]; code != 0 {
+		reason, ok := mqttConnAckErrors[code]
+		if !ok {
+			reason = fmt.Sprintf("return code %d", code)
+		}
+		return fmt.Errorf("MQTT broker refused the connection: %s", reason)
+	}
+	return nil
+}
+
+// publish sends a message, waiting for the broker's acknowledgement at
+// QoS 1.
+func (c *mqttConn) publish(m mqttMessage) error {
+	header := byte(mqttPublish<<4) | byte(m.qos)<<1
+	if m.retain {
+		header |= 0x01
+	}
+	body := appendString(nil, m.topic)
+	var id uint16
+	if m.qos > 0 {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		id = c.nextID
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	body = append(body, m.payload...)
+	if err := c.write(header, body); err != nil {
+		return err
+	}
+	if m.qos == 0 {
+		return nil
+	}
+
+	kind, reply, err := c.read()
+	if err != nil {
+		return err
+	}
+	if kind != mqttPubAck || len(reply) != 2 || binary.BigEndian.Uint16(reply) != id {
+		return fmt.Errorf("unexpected MQTT packet type %d in answer to PUBLISH", kind)
+	}
+	return nil
+}
+
+// ping keeps the connection alive.
+func (c *mqttConn) ping() error {
+	if err := c.write(mqttPingReq<<4, nil); err != nil {
+		return err
+	}
+	kind, _, err := c.read()
+	if err != nil {
+		return err
+	}
+	if kind != mqttPingResp {
+		return fmt.Errorf("unexpected MQTT packet type %d in answer to PINGREQ", kind)
+	}
+	return nil
+}
+
+// close disconnects cleanly, so that the broker does not publish the will.
+func (c *mqttConn) close() error {
+	c.write(mqttDisconnect<<4, nil)
+	return c.conn.Close()
+}
+
+func (c *mqttConn) write(header byte, body []byte) error {
+	packet := []byte{header}
+	packet = appendLength(packet, len(body))
+	packet = append(packet, body...)
+	c.conn.SetWriteDeadline(time.Now().Add(mqttTimeout))
+	_, err := c.conn.Write(packet)
+	return err
+}
+
+// read returns the type and the rest of the next packet.
+func (c *mqttConn) read() (int, []byte, error) {
+	c.conn.SetReadDeadline(time.Now().Add(mqttTimeout))
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length := 0
+	for shift := 0; ; shift += 7 {
+		if shift > 21 {
+			return 0, nil, errors.New("malformed MQTT packet length")
+		}
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return int(header >> 4), body, nil
+}
+
+// appendLength encodes a remaining length, 7 bits per byte.
+func appendLength(b []byte, n int) []byte {
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+// appendString encodes a length-prefixed UTF-8 string.
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
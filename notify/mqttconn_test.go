@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   MQTT packet encoding, against a scripted broker on an in-memory pipe.
+*/
+
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mqttStr encodes a string as MQTT does, for building expected packets.
+func mqttStr(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// fakeBroker answers each packet the client sends with the next reply,
+// which may be nil for none, and records the raw packets.
+type fakeBroker struct {
+	packets chan []byte
+}
+
+func newFakeBroker(t *testing.T, replies ...[]byte) (*mqttConn, *fakeBroker) {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	b := &fakeBroker{packets: make(chan []byte, 8)}
+	go func() {
+		r := bufio.NewReader(server)
+		for _, reply := range replies {
+			packet, err := readRawPacket(r)
+			if err != nil {
+				return
+			}
+			b.packets <- packet
+			if reply != nil {
+				server.Write(reply)
+			}
+		}
+		close(b.packets)
+	}()
+	return &mqttConn{conn: client, r: bufio.NewReader(client)}, b
+}
+
+// next returns the next packet the client sent.
+func (b *fakeBroker) next(t *testing.T) []byte {
+	t.Helper()
+	select {
+	case p, ok := <-b.packets:
+		if !ok {
+			t.Fatal("the client sent no further packet")
+		}
+		return p
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a packet")
+	}
+	return nil
+}
+
+// readRawPacket reads one packet, header and length included.
+func readRawPacket(r *bufio.Reader) ([]byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	packet := []byte{header}
+	length, multiplier := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		packet = append(packet, b)
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return append(packet, body...), nil
+}
+
+func TestAppendLength(t *testing.T) {
+	// The examples of section 2.2.3 of the MQTT 3.1.1 specification
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097151, []byte{0xff, 0xff, 0x7f}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+		{268435455, []byte{0xff, 0xff, 0xff, 0x7f}},
+	}
+
+	for _, tt := range tests {
+		if got := appendLength(nil, tt.n); !bytes.Equal(got, tt.want) {
+			t.Errorf("appendLength(%d) = % x, want % x", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestAppendString(t *testing.T) {
+	got := appendString([]byte{0xaa}, "goscan/é")
+	want := []byte{0xaa, 0x00, 0x09, 'g', 'o', 's', 'c', 'a', 'n', '/', 0xc3, 0xa9}
+	if !bytes.Equal(got, want) {
+		t.Errorf("appendString() = % x, want % x", got, want)
+	}
+}
+
+func TestMQTTConnect(t *testing.T) {
+	will := &mqttMessage{topic: "goscan/status", payload: []byte("offline"), qos: 1, retain: true}
+
+	tests := []struct {
+		name string
+		opts mqttOptions
+		body []byte
+	}{
+		{
+			name: "anonymous",
+			opts: mqttOptions{clientID: "goscan-1", keepAlive: 60 * time.Second},
+			body: concat(mqttStr("MQTT"), []byte{4, 0x02, 0, 60}, mqttStr("goscan-1")),
+		},
+		{
+			name: "will and login",
+			opts: mqttOptions{clientID: "goscan-1", username: "scan", password: "pw", keepAlive: 300 * time.Second, will: will},
+			body: concat(mqttStr("MQTT"), []byte{4, 0xee, 0x01, 0x2c}, mqttStr("goscan-1"),
+				mqttStr("goscan/status"), mqttStr("offline"), mqttStr("scan"), mqttStr("pw")),
+		},
+		{
+			// A password is only sent with a user name
+			name: "user without password",
+			opts: mqttOptions{clientID: "c", username: "scan", keepAlive: 30 * time.Second},
+			body: concat(mqttStr("MQTT"), []byte{4, 0x82, 0, 30}, mqttStr("c"), mqttStr("scan")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, broker := newFakeBroker(t, []byte{0x20, 0x02, 0x00, 0x00})
+			if err := c.connect(tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			want := concat([]byte{0x10, byte(len(tt.body))}, tt.body)
+			if got := broker.next(t); !bytes.Equal(got, want) {
+				t.Errorf("CONNECT = % x\nwant      % x", got, want)
+			}
+		})
+	}
+}
+
+func TestMQTTConnectRefused(t *testing.T) {
+	tests := []struct {
+		reply []byte
+		err   string
+	}{
+		{[]byte{0x20, 0x02, 0x00, 0x05}, "refused the connection: not authorized"},
+		{[]byte{0x20, 0x02, 0x00, 0x09}, "refused the connection: return code 9"},
+		{[]byte{0xd0, 0x00}, "unexpected MQTT packet type 13"},
+	}
+
+	for _, tt := range tests {
+		c, _ := newFakeBroker(t, tt.reply)
+		err := c.connect(mqttOptions{clientID: "goscan"})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("connect() = %v, want %q", err, tt.err)
+		}
+	}
+}
+
+func TestMQTTPublish(t *testing.T) {
+	t.Run("qos 0", func(t *testing.T) {
+		c, broker := newFakeBroker(t, nil)
+		if err := c.publish(mqttMessage{topic: "a/b", payload: []byte("hi"), retain: true}); err != nil {
+			t.Fatal(err)
+		}
+		want := concat([]byte{0x31, 7}, mqttStr("a/b"), []byte("hi"))
+		if got := broker.next(t); !bytes.Equal(got, want) {
+			t.Errorf("PUBLISH = % x, want % x", got, want)
+		}
+	})
+
+	t.Run("qos 1", func(t *testing.T) {
+		c, broker := newFakeBroker(t, []byte{0x40, 0x02, 0x00, 0x01}, []byte{0x40, 0x02, 0x00, 0x02})
+		for id := byte(1); id <= 2; id++ {
+			if err := c.publish(mqttMessage{topic: "a/b", payload: []byte("hi"), qos: 1}); err != nil {
+				t.Fatal(err)
+			}
+			want := concat([]byte{0x32, 9}, mqttStr("a/b"), []byte{0, id}, []byte("hi"))
+			if got := broker.next(t); !bytes.Equal(got, want) {
+				t.Errorf("PUBLISH = % x, want % x", got, want)
+			}
+		}
+	})
+
+	t.Run("qos 1 with the wrong packet id", func(t *testing.T) {
+		c, _ := newFakeBroker(t, []byte{0x40, 0x02, 0x00, 0x07})
+		if err := c.publish(mqttMessage{topic: "a/b", qos: 1}); err == nil {
+			t.Fatal("a PUBACK for another packet was accepted")
+		}
+	})
+
+	t.Run("packet ids skip zero", func(t *testing.T) {
+		c, broker := newFakeBroker(t, []byte{0x40, 0x02, 0x00, 0x01})
+		c.nextID = 0xffff
+		if err := c.publish(mqttMessage{topic: "t", qos: 1}); err != nil {
+			t.Fatal(err)
+		}
+		want := concat([]byte{0x32, 5}, mqttStr("t"), []byte{0, 1})
+		if got := broker.next(t); !bytes.Equal(got, want) {
+			t.Errorf("PUBLISH = % x, want % x", got, want)
+		}
+	})
+
+	t.Run("long payload", func(t *testing.T) {
+		c, broker := newFakeBroker(t, nil)
+		payload := bytes.Repeat([]byte("x"), 200)
+		if err := c.publish(mqttMessage{topic: "t", payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+		// 3 bytes of topic and 200 of payload need two length bytes
+		want := concat([]byte{0x30, 0xcb, 0x01}, mqttStr("t"), payload)
+		if got := broker.next(t); !bytes.Equal(got, want) {
+			t.Errorf("PUBLISH = % x, want % x", got, want)
+		}
+	})
+}
+
+func TestMQTTPing(t *testing.T) {
+	c, broker := newFakeBroker(t, []byte{0xd0, 0x00})
+	if err := c.ping(); err != nil {
+		t.Fatal(err)
+	}
+	if got := broker.next(t); !bytes.Equal(got, []byte{0xc0, 0x00}) {
+		t.Errorf("PINGREQ = % x, want c0 00", got)
+	}
+}
+
+func TestMQTTReadMalformedLength(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go server.Write([]byte{0x20, 0xff, 0xff, 0xff, 0xff, 0x7f})
+
+	c := &mqttConn{conn: client, r: bufio.NewReader(client)}
+	if _, _, err := c.read(); err == nil || !strings.Contains(err.Error(), "malformed") {
+		t.Fatalf("read() = %v, want a malformed length error", err)
+	}
+}
+
+func TestParseBroker(t *testing.T) {
+	tests := []struct {
+		broker  string
+		address string
+		tls     bool
+		err     bool
+	}{
+		{broker: "tcp://broker.lan", address: "broker.lan:1883"},
+		{broker: "mqtt://broker.lan:1884", address: "broker.lan:1884"},
+		{broker: "tls://broker.lan", address: "broker.lan:8883", tls: true},
+		{broker: "mqtts://[fd00::1]:8884", address: "[fd00::1]:8884", tls: true},
+		{broker: "ws://broker.lan", err: true},
+		{broker: "broker.lan:1883", err: true},
+	}
+
+	for _, tt := range tests {
+		address, useTLS, err := parseBroker(tt.broker)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseBroker(%q) accepted an invalid broker", tt.broker)
+			}
+			continue
+		}
+		if err != nil || address != tt.address || useTLS != tt.tls {
+			t.Errorf("parseBroker(%q) = %q, %v, %v, want %q, %v", tt.broker, address, useTLS, err, tt.address, tt.tls)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Daily summary email: devices first seen, subnet utilization from the
+   latest full scan, and hosts that were not always up, over the last day.
+*/
+
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"goscan/inventory"
+)
+
+// summaryPeriod is the period a summary covers.
+const summaryPeriod = 24 * time.Hour
+
+// Summary is the content of a summary email.
+type Summary struct {
+	Since      time.Time
+	Until      time.Time
+	NewDevices []inventory.Host
+	// ScannedAt is when the scan utilization is taken from started; zero
+	// if no full scan was saved yet.
+	ScannedAt  time.Time
+	Interfaces []InterfaceUsage
+	Outages    []Outage
+}
+
+// InterfaceUsage is the utilization of an interface's subnets.
+type InterfaceUsage struct {
+	Name        string
+	Alive       int
+	Scanned     int
+	Utilization float64
+}
+
+// Outage is a host that was down in some scans of the period.
+type Outage struct {
+	Interface     string
+	IP            string
+	Up            bool
+	Uptime        float64
+	LongestOutage time.Duration
+	Flaps         int
+}
+
+// BuildSummary gathers the day before at from the inventory.
+func BuildSummary(store *inventory.Store, at time.Time) (*Summary, error) {
+	s := &Summary{Since: at.Add(-summaryPeriod), Until: at}
+
+	hosts, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hosts {
+		if h.FirstSeen.After(s.Since) && !h.FirstSeen.After(at) {
+			s.NewDevices = append(s.NewDevices, h)
+		}
+	}
+
+	latest, err := store.LoadReport(inventory.ReportLatest)
+	switch {
+	case errors.Is(err, inventory.ErrNoReport):
+	case err != nil:
+		return nil, err
+	default:
+		s.ScannedAt = latest.StartedAt
+		for _, iface := range latest.Interfaces {
+			usage := InterfaceUsage{Name: iface.QualifiedName(), Alive: len(iface.ActiveHosts), Scanned: iface.TotalIPsScanned}
+			if usage.Scanned > 0 {
+				usage.Utilization = float64(usage.Alive) / float64(usage.Scanned)
+			}
+			s.Interfaces = append(s.Interfaces, usage)
+		}
+	}
+
+	availability, err := store.Availability(at)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range availability {
+		for _, w := range h.Windows {
+			if w.Window != "24h" || w.Samples == 0 || w.Uptime >= 100 {
+				continue
+			}
+			s.Outages = append(s.Outages, Outage{
+				Interface:     h.Interface,
+				IP:            h.IP,
+				Up:            h.Up,
+				Uptime:        w.Uptime,
+				LongestOutage: w.LongestOutage,
+				Flaps:         w.Flaps,
+			})
+		}
+	}
+	sort.SliceStable(s.Outages, func(i, j int) bool { return s.Outages[i].Uptime < s.Outages[j].Uptime })
+	return s, nil
+}
+
+// SendSummary mails a summary.
+func (m *Mailer) SendSummary(s *Summary) error {
+	subject := fmt.Sprintf("Daily summary: %d new devices, %d hosts with outages", len(s.NewDevices), len(s.Outages))
+	return m.send(subject, "summary", s)
+}
+
+// RenderSummary returns the plain-text version of a summary email.
+func (m *Mailer) RenderSummary(s *Summary) (string, error) {
+	text, _, err := m.render("summary", s)
+	return text, err
+}
+
+// ScheduleSummary mails the summary of the inventory every day at the
+// local time of day given as "15:04". It does not return.
+func ScheduleSummary(m *Mailer, store *inventory.Store, timeOfDay string) {
+	for {
+		next, err := nextDailyTime(time.Now(), timeOfDay)
+		if err != nil {
+			log.Printf("Daily summary disabled: %v", err)
+			return
+		}
+		time.Sleep(time.Until(next))
+
+		summary, err := BuildSummary(store, time.Now())
+		if err != nil {
+			log.Printf("Failed to build daily summary: %v", err)
+			continue
+		}
+		if err := m.SendSummary(summary); err != nil {
+			log.Printf("Failed to send daily summary: %v", err)
+		}
+	}
+}
+
+// nextDailyTime returns the first time after now at the local time of day
+// given as "15:04".
+func nextDailyTime(now time.Time, timeOfDay string) (time.Time, error) {
+	t, err := time.Parse("15:04", timeOfDay)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time of day %q (expected HH:MM)", timeOfDay)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// ValidateTimeOfDay checks a "15:04" time of day.
+func ValidateTimeOfDay(timeOfDay string) error {
+	_, err := nextDailyTime(time.Now(), timeOfDay)
+	return err
+}
@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Syslog sink: events as RFC 5424 messages over UDP, TCP (with RFC 6587
+   octet counting) or a unix socket, with the event fields as structured
+   data:
+
+     <28>1 2024-05-01T10:00:00.000000Z gw goscan 4242 host.down
+       [goscan@32473 id="..." interface="eth0" ip="192.168.1.20"] 192.168.1.20 is down
+*/
+
+package notify
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goscan/config"
+	"goscan/events"
+)
+
+// syslogSDID is the structured data ID of event fields. 32473 is the
+// private enterprise number reserved for documentation and examples.
+const syslogSDID = "goscan@32473"
+
+// syslogFacilities maps facility names to their codes.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+const syslogDialTimeout = 5 * time.Second
+
+// Syslog delivers events to a syslog server.
+type Syslog struct {
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslog returns the syslog sink described by cfg and the events it
+// wants.
+func NewSyslog(cfg config.SyslogConfig) (*Syslog, events.Filter, error) {
+	network := cfg.Network
+	if network == "" {
+		network = "udp"
+	}
+	switch network {
+	case "udp", "tcp", "unix", "unixgram":
+	default:
+		return nil, events.Filter{}, fmt.Errorf("invalid syslog network %q (expected udp, tcp, unix or unixgram)", cfg.Network)
+	}
+	address := cfg.Address
+	if network == "udp" || network == "tcp" {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, "514")
+		}
+	}
+
+	facilityName := cfg.Facility
+	if facilityName == "" {
+		facilityName = "daemon"
+	}
+	facility, ok := syslogFacilities[facilityName]
+	if !ok {
+		return nil, events.Filter{}, fmt.Errorf("invalid syslog facility %q", cfg.Facility)
+	}
+
+	types, err := events.ValidateTypes(cfg.Events)
+	if err != nil {
+		return nil, events.Filter{}, fmt.Errorf("syslog: %w", err)
+	}
+
+	appName := cfg.Tag
+	if appName == "" {
+		appName = "goscan"
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &Syslog{
+		network:  network,
+		address:  address,
+		facility: facility,
+		appName:  appName,
+		hostname: hostname,
+	}
+	return s, events.Filter{Types: types, Interfaces: cfg.Interfaces}, nil
+}
+
+// Name identifies the sink in logs.
+func (s *Syslog) Name() string {
+	return fmt.Sprintf("syslog %s://%s", s.network, s.address)
+}
+
+// Send writes an event, reconnecting once if the connection was lost or
+// the write timed out.
+func (s *Syslog) Send(e events.Event) error {
+	msg := s.format(e)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = s.dial(); err != nil {
+				continue
+			}
+		}
+		// A stalled server times the write out, which reconnects as any
+		// other write error does
+		s.conn.SetWriteDeadline(time.Now().Add(syslogDialTimeout))
+		if _, err = s.conn.Write(s.frame(msg)); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *Syslog) dial() (net.Conn, error) {
+	if s.network != "unix" {
+		return net.DialTimeout(s.network, s.address, syslogDialTimeout)
+	}
+	// Local syslog sockets such as /dev/log are usually datagram sockets
+	conn, err := net.DialTimeout("unixgram", s.address, syslogDialTimeout)
+	if err != nil {
+		conn, err = net.DialTimeout("unix", s.address, syslogDialTimeout)
+	}
+	return conn, err
+}
+
+// frame delimits a message on a stream transport by prefixing its length
+// (RFC 6587 octet counting).
+func (s *Syslog) frame(msg string) []byte {
+	if s.network == "tcp" {
+		return []byte(strconv.Itoa(len(msg)) + " " + msg)
+	}
+	return []byte(msg)
+}
+
+// format renders an event as an RFC 5424 message.
+func (s *Syslog) format(e events.Event) string {
+	pri := s.facility*8 + e.Severity()
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		pri, e.Time.UTC().Format("2006-01-02T15:04:05.000000Z"), s.hostname, s.appName,
+		os.Getpid(), e.Type, structuredData(e), e.Message)
+}
+
+// sdEscape escapes structured data parameter values (RFC 5424 section 6.3.3).
+var sdEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+func structuredData(e events.Event) string {
+	var b strings.Builder
+	b.WriteString("[" + syslogSDID)
+	for _, field := range eventFields(e) {
+		fmt.Fprintf(&b, ` %s="%s"`, field.name, sdEscape.Replace(field.value))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+type eventField struct {
+	name  string
+	value string
+}
+
+// eventFields lists the fields of an event that are set, for sinks that
+// carry them as key-value pairs.
+func eventFields(e events.Event) []eventField {
+	fields := []eventField{{"id", e.ID}, {"type", string(e.Type)}}
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, eventField{name, value})
+		}
+	}
+	add("interface", e.Interface)
+	add("ip", e.IP)
+	add("mac", e.MAC)
+	add("oldMac", e.OldMAC)
+	add("names", strings.Join(e.Names, ","))
+	add("threshold", e.Threshold)
+	if e.Threshold != "" {
+		add("value", strconv.FormatFloat(e.Value, 'g', -1, 64))
+		add("limit", strconv.FormatFloat(e.Limit, 'g', -1, 64))
+	}
+	if e.Type == events.ScanFinished {
+		add("hosts", strconv.Itoa(e.Hosts))
+		add("scanned", strconv.Itoa(e.Scanned))
+	}
+	add("error", e.Error)
+	return fields
+}
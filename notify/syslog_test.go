@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Syslog message formatting and framing.
+*/
+
+package notify
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"goscan/config"
+	"goscan/events"
+)
+
+func testEvent() events.Event {
+	return events.Event{
+		ID:        "0123",
+		Type:      events.HostDown,
+		Time:      time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Interface: "eth0",
+		IP:        "192.0.2.20",
+		Names:     []string{"nas", `a"b]`},
+		Message:   "192.0.2.20 is down",
+	}
+}
+
+func TestNewSyslog(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.SyslogConfig
+		address string
+		wantErr bool
+	}{
+		{"default port", config.SyslogConfig{Address: "192.0.2.1"}, "192.0.2.1:514", false},
+		{"explicit port", config.SyslogConfig{Network: "tcp", Address: "192.0.2.1:6514"}, "192.0.2.1:6514", false},
+		{"unix socket", config.SyslogConfig{Network: "unix", Address: "/dev/log"}, "/dev/log", false},
+		{"invalid network", config.SyslogConfig{Network: "sctp", Address: "192.0.2.1"}, "", true},
+		{"invalid facility", config.SyslogConfig{Address: "192.0.2.1", Facility: "local9"}, "", true},
+		{"invalid event", config.SyslogConfig{Address: "192.0.2.1", Events: []string{"host.gone"}}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, err := NewSyslog(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSyslog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && s.address != tt.address {
+				t.Errorf("address = %s, want %s", s.address, tt.address)
+			}
+		})
+	}
+}
+
+func TestSyslogFormat(t *testing.T) {
+	s, _, err := NewSyslog(config.SyslogConfig{Address: "192.0.2.1", Facility: "local0", Tag: "scan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.hostname = "gw"
+
+	got := s.format(testEvent())
+
+	// local0 (16) * 8 + warning (4)
+	want := fmt.Sprintf(`<132>1 2024-05-01T10:00:00.000000Z gw scan %d host.down `+
+		`[goscan@32473 id="0123" type="host.down" interface="eth0" ip="192.0.2.20" names="nas,a\"b\]"] 192.0.2.20 is down`,
+		os.Getpid())
+	if got != want {
+		t.Errorf("format() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// syslogServer accepts one TCP connection and reports the octet-counted
+// messages it receives.
+func syslogServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		length, err := r.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(length))
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err == nil {
+			received <- string(msg)
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSyslogSendTCP(t *testing.T) {
+	addr, received := syslogServer(t)
+	s, _, err := NewSyslog(config.SyslogConfig{Network: "tcp", Address: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		if want := s.format(testEvent()); msg != want {
+			t.Errorf("received %q, want %q", msg, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+// stalledConn is a connection to a server that stopped reading: writes
+// time out once their deadline is reached, or, without a deadline, hang a
+// while before they seem to succeed.
+type stalledConn struct {
+	net.Conn
+	deadline time.Time
+	closed   bool
+}
+
+func (c *stalledConn) SetWriteDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func (c *stalledConn) Write(b []byte) (int, error) {
+	if c.deadline.IsZero() {
+		time.Sleep(time.Second)
+		return len(b), nil
+	}
+	return 0, os.ErrDeadlineExceeded
+}
+
+func (c *stalledConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestSyslogWriteTimeout(t *testing.T) {
+	addr, received := syslogServer(t)
+	s, _, err := NewSyslog(config.SyslogConfig{Network: "tcp", Address: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stalled := &stalledConn{}
+	s.conn = stalled
+
+	if err := s.Send(testEvent()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !stalled.closed {
+		t.Error("stalled connection not closed")
+	}
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("no message received after reconnecting")
+	}
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Webhook sink: each event is POSTed as JSON to an HTTP endpoint.
+
+   With a secret, the body is signed with HMAC-SHA256 and the signature sent
+   as "X-Goscan-Signature: sha256=<hex>". Failed deliveries (network errors,
+   5xx and 429 responses) are retried with exponential backoff; other 4xx
+   responses are final. A Retry-After longer than the maximum backoff drops
+   the event rather than holding its delivery.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"goscan/config"
+	"goscan/events"
+)
+
+// Webhook request headers.
+const (
+	HeaderEvent     = "X-Goscan-Event"
+	HeaderDelivery  = "X-Goscan-Delivery"
+	HeaderSignature = "X-Goscan-Signature"
+)
+
+const (
+	defaultWebhookTimeout    = 10 * time.Second
+	defaultWebhookMaxRetries = 5
+	initialBackoff           = time.Second
+	maxBackoff               = 5 * time.Minute
+)
+
+// Webhook delivers events to an HTTP endpoint.
+type Webhook struct {
+	url        string
+	secret     string
+	maxRetries int
+	client     *http.Client
+	// backoff is the delay before the first retry, doubled for each
+	// further one up to maxBackoff.
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// NewWebhook returns the webhook described by cfg and the events it wants.
+func NewWebhook(cfg config.WebhookConfig) (*Webhook, events.Filter, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, events.Filter{}, fmt.Errorf("invalid webhook URL %q", cfg.URL)
+	}
+	types, err := events.ValidateTypes(cfg.Events)
+	if err != nil {
+		return nil, events.Filter{}, fmt.Errorf("webhook %s: %w", cfg.URL, err)
+	}
+	if len(types) == 0 {
+		types = events.NotificationTypes
+	}
+
+	timeout := time.Duration(cfg.Timeout)
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	retries := cfg.MaxRetries
+	switch {
+	case retries == 0:
+		retries = defaultWebhookMaxRetries
+	case retries < 0:
+		retries = 0
+	}
+
+	w := &Webhook{
+		url:        cfg.URL,
+		secret:     cfg.Secret,
+		maxRetries: retries,
+		client:     &http.Client{Timeout: timeout},
+		backoff:    initialBackoff,
+		maxBackoff: maxBackoff,
+	}
+	return w, events.Filter{Types: types, Interfaces: cfg.Interfaces}, nil
+}
+
+// Name identifies the webhook in logs.
+func (w *Webhook) Name() string {
+	return "webhook " + w.url
+}
+
+// Send posts an event, retrying until it is accepted or the retries run out.
+func (w *Webhook) Send(e events.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	delay := w.backoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := w.post(e, body)
+		if err == nil {
+			return nil
+		}
+		if retryAfter < 0 || attempt >= w.maxRetries {
+			return err
+		}
+		if retryAfter > w.maxBackoff {
+			return fmt.Errorf("%w, retry after %s is over the %s limit", err, retryAfter, w.maxBackoff)
+		}
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		time.Sleep(delay)
+		delay = min(delay*2, w.maxBackoff)
+	}
+}
+
+// post makes one delivery attempt. On failure it returns how long the
+// receiver asked to wait (zero if it did not say), or a negative duration if
+// retrying is pointless.
+func (w *Webhook) post(e events.Event, body []byte) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "goscan")
+	req.Header.Set(HeaderEvent, string(e.Type))
+	req.Header.Set(HeaderDelivery, e.ID)
+	if w.secret != "" {
+		req.Header.Set(HeaderSignature, Sign(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		wait := time.Duration(0)
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		return wait, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return -1, fmt.Errorf("receiver answered %s", resp.Status)
+}
+
+// Sign returns the signature header value of a webhook body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a signature header value against a body.
+func VerifySignature(secret string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: MIT
+
+/*
+   Webhook signatures and delivery.
+*/
+
+package notify
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"goscan/config"
+	"goscan/events"
+)
+
+func TestSign(t *testing.T) {
+	// A published HMAC-SHA256 example
+	got := Sign("key", []byte("The quick brown fox jumps over the lazy dog"))
+	want := "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"type":"host.new","ip":"192.0.2.9"}`)
+	valid := Sign("s3cret", body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid", "s3cret", body, valid, true},
+		{"wrong secret", "secret", body, valid, false},
+		{"altered body", "s3cret", []byte(`{"type":"host.new","ip":"192.0.2.10"}`), valid, false},
+		{"missing prefix", "s3cret", body, strings.TrimPrefix(valid, "sha256="), false},
+		{"other algorithm", "s3cret", body, "sha1=" + strings.TrimPrefix(valid, "sha256="), false},
+		{"truncated", "s3cret", body, valid[:len(valid)-2], false},
+		{"empty", "s3cret", body, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySignature(tt.secret, tt.body, tt.signature); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		statuses []int // answered in turn, the last one from then on
+		// retryAfter is sent as Retry-After with every error
+		retryAfter string
+		retries    int
+		attempts   int32
+		ok         bool
+	}{
+		{name: "accepted", secret: "s3cret", statuses: []int{http.StatusNoContent}, retries: 2, attempts: 1, ok: true},
+		{name: "unsigned", statuses: []int{http.StatusOK}, retries: 2, attempts: 1, ok: true},
+		{name: "retried after 503", secret: "s3cret", statuses: []int{http.StatusServiceUnavailable, http.StatusOK}, retries: 2, attempts: 2, ok: true},
+		{name: "retried after 429", statuses: []int{http.StatusTooManyRequests, http.StatusOK}, retries: 2, attempts: 2, ok: true},
+		{name: "retries run out", statuses: []int{http.StatusBadGateway}, retries: 2, attempts: 3},
+		{name: "retries disabled", statuses: []int{http.StatusBadGateway}, retries: -1, attempts: 1},
+		{name: "4xx is final", statuses: []int{http.StatusBadRequest}, retries: 2, attempts: 1},
+		{name: "retry after over the maximum backoff", statuses: []int{http.StatusTooManyRequests, http.StatusOK}, retryAfter: "3600", retries: 2, attempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1))
+				body, _ := io.ReadAll(r.Body)
+				if r.Header.Get(HeaderEvent) != string(events.HostNew) || r.Header.Get(HeaderDelivery) == "" {
+					t.Errorf("event headers = %q, %q", r.Header.Get(HeaderEvent), r.Header.Get(HeaderDelivery))
+				}
+				signature := r.Header.Get(HeaderSignature)
+				switch {
+				case tt.secret == "" && signature != "":
+					t.Errorf("unsigned webhook sent signature %q", signature)
+				case tt.secret != "" && !VerifySignature(tt.secret, body, signature):
+					t.Errorf("signature %q does not match the body", signature)
+				}
+				status := tt.statuses[min(n, len(tt.statuses))-1]
+				if tt.retryAfter != "" && status >= 400 {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			webhook, _, err := NewWebhook(config.WebhookConfig{URL: srv.URL, Secret: tt.secret, MaxRetries: tt.retries})
+			if err != nil {
+				t.Fatal(err)
+			}
+			webhook.backoff = time.Millisecond
+			webhook.maxBackoff = 10 * time.Millisecond
+
+			err = webhook.Send(events.New(events.HostNew, "New host 192.0.2.9"))
+			if (err == nil) != tt.ok {
+				t.Errorf("Send() = %v, want success %v", err, tt.ok)
+			}
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("%d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestNewWebhookInvalid(t *testing.T) {
+	for _, cfg := range []config.WebhookConfig{
+		{URL: "ftp://example.com/hook"},
+		{URL: "http:///hook"},
+		{URL: "https://example.com/hook", Events: []string{"host.gone"}},
+	} {
+		if _, _, err := NewWebhook(cfg); err == nil {
+			t.Errorf("NewWebhook(%+v) accepted an invalid configuration", cfg)
+		}
+	}
+}
@@ -37,6 +37,7 @@ func GetStats() Stats {
 }
 
 func MonitorRuntimeStats() {
+	UpdateStats()
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 